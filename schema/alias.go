@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Alias is a named type, ie. `ID = uint64` or `Names = []string`, and can be
+// referenced anywhere a type is expected, including map keys.
+type Alias struct {
+	Name VarName  `json:"name"`
+	Type *VarType `json:"type"`
+
+	// resolving is set while the alias is being expanded, to detect cycles
+	resolving bool
+}
+
+func (a *Alias) Parse(schema *WebRPCSchema) error {
+	aliasName := string(a.Name)
+	if aliasName == "" {
+		return fmt.Errorf("schema error: alias name cannot be empty")
+	}
+
+	// Ensure we don't have dupe aliases (w/ normalization)
+	name := strings.ToLower(aliasName)
+	for _, alias := range schema.Aliases {
+		if alias != a && name == strings.ToLower(string(alias.Name)) {
+			return fmt.Errorf("schema error: duplicate alias detected, '%s'", aliasName)
+		}
+	}
+
+	if a.Type == nil || a.Type.Expr == "" {
+		return fmt.Errorf("schema error: type expr cannot be empty for alias '%s'", aliasName)
+	}
+
+	err := parseAliasExpr(schema, a, a.Type)
+	if err != nil {
+		return err
+	}
+	a.Type.Expr = buildVarTypeExpr(a.Type, "", schema.parseOptions())
+	return nil
+}
+
+func getAliasType(schema *WebRPCSchema, name string) (*Alias, bool) {
+	if schema == nil {
+		return nil, false
+	}
+	for _, alias := range schema.Aliases {
+		if name == string(alias.Name) {
+			return alias, true
+		}
+	}
+	return nil, false
+}
+
+// parseAliasExpr expands the alias type into vt
+func parseAliasExpr(schema *WebRPCSchema, alias *Alias, vt *VarType) error {
+	if alias.resolving {
+		return newParseError(ErrCyclicType, string(alias.Name), "schema error: type alias '%s' is self-referential", alias.Name)
+	}
+	if alias.Type == nil {
+		return newParseError(ErrInvalidSyntax, string(alias.Name), "schema error: type expr cannot be empty for alias '%s'", alias.Name)
+	}
+
+	alias.resolving = true
+	defer func() { alias.resolving = false }()
+
+	return ParseVarTypeExpr(schema, alias.Type.Expr, vt)
+}
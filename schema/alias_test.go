@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAlias(name, expr string) *Alias {
+	return &Alias{Name: VarName(name), Type: &VarType{Expr: expr}}
+}
+
+func TestAlias(t *testing.T) {
+	s := newTestSchema("User")
+	s.Aliases = []*Alias{
+		newTestAlias("ID", "uint64"),
+		newTestAlias("Users", "map<ID,User>"),
+	}
+	assert.NoError(t, s.Validate())
+
+	vt := &VarType{Expr: "[]Users"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Map, vt.List.Elem.Type)
+	assert.Equal(t, T_Uint64, vt.List.Elem.Map.Key)
+	assert.Equal(t, "[]map<uint64,User>", vt.Expr)
+}
+
+func TestAliasContainer(t *testing.T) {
+	s := newTestSchema("User")
+	s.Aliases = []*Alias{
+		newTestAlias("Names", "[]string"),
+		newTestAlias("Friends", "map<string,[]User>"),
+		newTestAlias("Nickname", "string?"),
+	}
+	assert.NoError(t, s.Validate())
+
+	tt := []struct {
+		Expr      string
+		Expanded  string
+		Preserved string
+	}{
+		{"map<string,Names>", "map<string,[]string>", "map<string,Names>"},
+		{"[]Names", "[][]string", "[]Names"},
+		{"map<uint64,[]Names?>", "map<uint64,[]optional<[]string>>", "map<uint64,[]Names?>"},
+		{"map<string,Friends>", "map<string,map<string,[]User>>", "map<string,Friends>"},
+		{"[]Nickname", "[]string?", "[]Nickname"},
+		{"nullable<Names>", "nullable<[]string>", "nullable<Names>"},
+	}
+
+	for _, tc := range tt {
+		expanded := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, expanded.Parse(s), tc.Expr) {
+			continue
+		}
+		assert.Equal(t, tc.Expanded, expanded.Expr, tc.Expr)
+
+		// the expansion is the same type as the alias target spelled out
+		spelled := &VarType{Expr: tc.Expanded}
+		assert.NoError(t, spelled.Parse(s), tc.Expanded)
+		assert.True(t, expanded.Equal(spelled), tc.Expr)
+
+		preserved := &VarType{Expr: tc.Expr}
+		s.ParseOptions = ParseOptions{PreserveAliases: true}
+		assert.NoError(t, preserved.Parse(s), tc.Expr)
+		s.ParseOptions = ParseOptions{}
+		assert.Equal(t, tc.Preserved, preserved.Expr, tc.Expr)
+		assert.True(t, preserved.Equal(expanded), tc.Expr)
+	}
+
+	vt := &VarType{Expr: "map<string,Names>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "Names", vt.Map.Value.Alias)
+	assert.Equal(t, T_List, vt.Map.Value.Type)
+	assert.Equal(t, T_String, vt.Map.Value.List.Elem.Type)
+	assert.Equal(t, "map[string][]string", vt.GoType())
+}
+
+func TestAliasInvalid(t *testing.T) {
+	tt := []struct {
+		Aliases []*Alias
+		Expr    string
+		Error   string
+	}{
+		{
+			[]*Alias{newTestAlias("A", "A")},
+			"A",
+			"type alias 'A' is self-referential",
+		},
+		{
+			[]*Alias{newTestAlias("A", "[]B"), newTestAlias("B", "map<string,A>")},
+			"B",
+			"type alias 'B' is self-referential",
+		},
+		{
+			[]*Alias{newTestAlias("Flag", "bool")},
+			"map<Flag,string>",
+			"map key alias 'Flag' resolves to 'bool', which is not a valid map key type",
+		},
+		{
+			[]*Alias{newTestAlias("Profile", "User")},
+			"map<Profile,string>",
+			"map key alias 'Profile' resolves to 'User', which is not a valid map key type",
+		},
+		{
+			[]*Alias{newTestAlias("ID", "uint64?")},
+			"map<ID,string>",
+			"map key alias 'ID' resolves to optional type 'uint64?'",
+		},
+		{
+			nil,
+			"map<string?,int>",
+			"map key 'string?' cannot be optional",
+		},
+	}
+
+	for _, tc := range tt {
+		s := newTestSchema("User")
+		s.Aliases = tc.Aliases
+
+		vt := &VarType{Expr: tc.Expr}
+		err := vt.Parse(s)
+		if assert.Error(t, err, tc.Expr) {
+			assert.Contains(t, err.Error(), tc.Error)
+		}
+	}
+
+	s := newTestSchema("User")
+	s.Aliases = []*Alias{newTestAlias("A", "[]B"), newTestAlias("B", "map<string,A>")}
+	assert.Error(t, s.Validate())
+}
@@ -0,0 +1,279 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ToAvro returns the schema messages as an Avro schema, a JSON array of
+// named types in message order. Structs become records and enums become Avro
+// enums of their member names. A message is defined in full where it is first
+// referenced, and referenced by name after, as Avro requires names to be
+// defined before use.
+//
+// Optional and nullable types become ["null", T] unions, with a null default
+// for optional fields. Timestamps, dates, times, uuids and decimal<p,s> use
+// the Avro logical types, and other string-encoded types, ie. bigints, plain
+// strings. Avro maps only have string keys, so maps with non-string keys
+// become arrays of key/value records. Types without an Avro equivalent, ie.
+// money or any, are an error, as are unions with two different branches of
+// one Avro type, ie. union<timestamp|int64>. The schema must be parsed.
+func (s *WebRPCSchema) ToAvro() ([]byte, error) {
+	c := &avroConverter{defined: map[string]bool{}}
+	types := []interface{}{}
+	for _, msg := range s.Messages {
+		if c.defined[string(msg.Name)] {
+			continue
+		}
+		avroType, err := c.messageType(msg)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, avroType)
+	}
+	return json.MarshalIndent(types, "", "  ")
+}
+
+type avroConverter struct {
+	// defined holds the names of the named types emitted so far
+	defined map[string]bool
+
+	// scope names the field being converted, for the key/value records of
+	// maps with non-string keys, ie. UserScoresEntry
+	scope string
+}
+
+func (c *avroConverter) messageType(msg *Message) (interface{}, error) {
+	name := string(msg.Name)
+	if c.defined[name] {
+		return name, nil
+	}
+	c.defined[name] = true
+
+	if msg.Type == "enum" {
+		symbols := make([]string, 0, len(msg.Fields))
+		for _, field := range msg.Fields {
+			symbols = append(symbols, string(field.Name))
+		}
+		enum := map[string]interface{}{"type": "enum", "name": name, "symbols": symbols}
+		if msg.Description != "" {
+			enum["doc"] = msg.Description
+		}
+		return enum, nil
+	}
+
+	fields := []interface{}{}
+	for _, field := range msg.Fields {
+		if field.JSONOmitted {
+			continue
+		}
+		scope := c.scope
+		c.scope = name + strings.ToUpper(string(field.Name[:1])) + string(field.Name[1:])
+		fieldType, err := c.varType(field.Type)
+		c.scope = scope
+		if err != nil {
+			return nil, fmt.Errorf("message '%s' field '%s': %w", msg.Name, field.Name, err)
+		}
+
+		avroField := map[string]interface{}{"name": field.WireName()}
+		if field.Optional && !field.Type.Optional {
+			fieldType = avroNullable(fieldType)
+		}
+		avroField["type"] = fieldType
+		if field.Optional || field.Type.Optional {
+			avroField["default"] = nil
+		}
+		if field.Description != "" {
+			avroField["doc"] = field.Description
+		}
+		fields = append(fields, avroField)
+	}
+
+	record := map[string]interface{}{"type": "record", "name": name, "fields": fields}
+	if msg.Description != "" {
+		record["doc"] = msg.Description
+	}
+	return record, nil
+}
+
+// avroPrimitives maps data types to Avro primitive or logical types. Integers
+// wider than int32 are longs, including uint64, which Avro has no unsigned
+// equivalent of.
+var avroPrimitives = map[DataType]interface{}{
+	T_Null:      "null",
+	T_Bool:      "boolean",
+	T_Byte:      "int",
+	T_Uint8:     "int",
+	T_Uint16:    "int",
+	T_Int8:      "int",
+	T_Int16:     "int",
+	T_Int32:     "int",
+	T_Uint32:    "long",
+	T_Uint64:    "long",
+	T_Uint:      "long",
+	T_Int64:     "long",
+	T_Int:       "long",
+	T_Float32:   "float",
+	T_Float64:   "double",
+	T_Ratio:     "double",
+	T_String:    "string",
+	T_BigInt:    "string",
+	T_Rational:  "string",
+	T_Decimal:   "string",
+	T_IPAddr:    "string",
+	T_CIDR:      "string",
+	T_Timestamp: map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"},
+	T_DateTime:  map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"},
+	T_Date:      map[string]interface{}{"type": "int", "logicalType": "date"},
+	T_Time:      map[string]interface{}{"type": "int", "logicalType": "time-millis"},
+	T_UUID:      map[string]interface{}{"type": "string", "logicalType": "uuid"},
+}
+
+func (c *avroConverter) varType(t *VarType) (interface{}, error) {
+	if t.Optional || t.Nullable {
+		base := *t
+		base.Optional = false
+		base.Nullable = false
+		avroType, err := c.varType(&base)
+		if err != nil {
+			return nil, err
+		}
+		return avroNullable(avroType), nil
+	}
+
+	switch t.Type {
+	case T_Unknown:
+		return nil, fmt.Errorf("type '%s' is not parsed, validate the schema first", t.Expr)
+	case T_Decimal:
+		if t.Decimal != nil {
+			return map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": t.Decimal.Precision, "scale": t.Decimal.Scale}, nil
+		}
+	case T_List:
+		if t.IsBytes() {
+			return "bytes", nil
+		}
+		items, err := c.varType(t.List.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case T_Map:
+		values, err := c.varType(t.Map.Value)
+		if err != nil {
+			return nil, err
+		}
+		if isStringWireKey(t.Map.Key) {
+			return map[string]interface{}{"type": "map", "values": values}, nil
+		}
+		key, ok := avroPrimitives[t.Map.Key]
+		if !ok {
+			return nil, fmt.Errorf("map key '%s' has no Avro equivalent", t.Map.Key)
+		}
+		name := c.scope + "Entry"
+		for i := 2; c.defined[name]; i++ {
+			name = fmt.Sprintf("%sEntry%d", c.scope, i)
+		}
+		c.defined[name] = true
+		entry := map[string]interface{}{
+			"type": "record",
+			"name": name,
+			"fields": []interface{}{
+				map[string]interface{}{"name": "key", "type": key},
+				map[string]interface{}{"name": "value", "type": values},
+			},
+		}
+		return map[string]interface{}{"type": "array", "items": entry}, nil
+	case T_Result, T_Union:
+		variants := []*VarType{}
+		if t.Type == T_Result {
+			variants = append(variants, t.Result.Ok, t.Result.Err)
+		} else {
+			variants = append(variants, t.Union.Variants...)
+		}
+		union := []interface{}{}
+		for _, variant := range variants {
+			avroType, err := c.varType(variant)
+			if err != nil {
+				return nil, err
+			}
+			union = append(union, avroUnionMembers(avroType)...)
+		}
+		return avroUnion(union)
+	case T_Struct:
+		if t.Struct.Message == nil {
+			return nil, fmt.Errorf("type '%s' is not resolved, validate the schema first", t.Expr)
+		}
+		return c.messageType(t.Struct.Message)
+	}
+
+	if avroType, ok := avroPrimitives[t.Type]; ok {
+		return avroType, nil
+	}
+	return nil, fmt.Errorf("type '%s' has no Avro equivalent", t.Expr)
+}
+
+// avroNullable unions the type with null, flattening unions, as Avro unions
+// can't be nested
+func avroNullable(avroType interface{}) interface{} {
+	union := []interface{}{"null"}
+	for _, member := range avroUnionMembers(avroType) {
+		if member != "null" {
+			union = append(union, member)
+		}
+	}
+	return union
+}
+
+// avroUnion checks the union members are valid Avro union branches. Avro
+// rejects two branches of the same type, unless they're named types of
+// different names, so repeats of a branch are dropped, ie. the nulls of two
+// nullable variants or the longs of union<int64|uint64>, and different types
+// of a kind are an error, ie. union<timestamp|int64>, which are both longs.
+func avroUnion(members []interface{}) ([]interface{}, error) {
+	union := []interface{}{}
+	seen := map[string]interface{}{}
+	for _, member := range members {
+		key, named := avroUnionBranch(member)
+		if prev, ok := seen[key]; ok {
+			// named types are defined once, and referenced by name after
+			if named || reflect.DeepEqual(prev, member) {
+				continue
+			}
+			return nil, fmt.Errorf("union has more than one Avro '%s' branch", key)
+		}
+		seen[key] = member
+		union = append(union, member)
+	}
+	return union, nil
+}
+
+// avroUnionBranch returns what tells union branches apart, the name of named
+// types and the type of others, ie. "long" for a timestamp-millis long
+func avroUnionBranch(avroType interface{}) (key string, named bool) {
+	switch t := avroType.(type) {
+	case string:
+		return t, !avroTypeNames[t]
+	case map[string]interface{}:
+		switch t["type"] {
+		case "record", "enum", "fixed":
+			return fmt.Sprint(t["name"]), true
+		}
+		return fmt.Sprint(t["type"]), false
+	}
+	return fmt.Sprint(avroType), false
+}
+
+// avroTypeNames are the unnamed Avro types, which can be given by name
+var avroTypeNames = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true,
+	"double": true, "bytes": true, "string": true,
+}
+
+func avroUnionMembers(avroType interface{}) []interface{} {
+	if union, ok := avroType.([]interface{}); ok {
+		return union
+	}
+	return []interface{}{avroType}
+}
@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToAvro(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Kind",
+				"type": "enum",
+				"fields": [
+					{ "name": "USER", "type": "uint32" },
+					{ "name": "ADMIN", "type": "uint32" }
+				]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "manager", "type": "User?" },
+					{ "name": "address", "type": "Address" },
+					{ "name": "tags", "type": "[]string", "optional": true },
+					{ "name": "scores", "type": "map<uint32,float64>" },
+					{ "name": "labels", "type": "map<string,string>" },
+					{ "name": "balance", "type": "decimal<10,2>" },
+					{ "name": "createdAt", "type": "timestamp", "meta": [{ "json": "created_at" }] }
+				]
+			},
+			{
+				"name": "Address",
+				"type": "struct",
+				"fields": [{ "name": "city", "type": "string" }]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	out, err := s.ToAvro()
+	assert.NoError(t, err)
+
+	golden := `[
+  {
+    "name": "Kind",
+    "symbols": [
+      "USER",
+      "ADMIN"
+    ],
+    "type": "enum"
+  },
+  {
+    "fields": [
+      {
+        "name": "id",
+        "type": "long"
+      },
+      {
+        "name": "kind",
+        "type": "Kind"
+      },
+      {
+        "default": null,
+        "name": "manager",
+        "type": [
+          "null",
+          "User"
+        ]
+      },
+      {
+        "name": "address",
+        "type": {
+          "fields": [
+            {
+              "name": "city",
+              "type": "string"
+            }
+          ],
+          "name": "Address",
+          "type": "record"
+        }
+      },
+      {
+        "default": null,
+        "name": "tags",
+        "type": [
+          "null",
+          {
+            "items": "string",
+            "type": "array"
+          }
+        ]
+      },
+      {
+        "name": "scores",
+        "type": {
+          "items": {
+            "fields": [
+              {
+                "name": "key",
+                "type": "long"
+              },
+              {
+                "name": "value",
+                "type": "double"
+              }
+            ],
+            "name": "UserScoresEntry",
+            "type": "record"
+          },
+          "type": "array"
+        }
+      },
+      {
+        "name": "labels",
+        "type": {
+          "type": "map",
+          "values": "string"
+        }
+      },
+      {
+        "name": "balance",
+        "type": {
+          "logicalType": "decimal",
+          "precision": 10,
+          "scale": 2,
+          "type": "bytes"
+        }
+      },
+      {
+        "name": "created_at",
+        "type": {
+          "logicalType": "timestamp-millis",
+          "type": "long"
+        }
+      }
+    ],
+    "name": "User",
+    "type": "record"
+  }
+]`
+	assert.Equal(t, golden, string(out))
+
+	s.Messages[2].Fields = append(s.Messages[2].Fields, &MessageField{Name: "price", Type: &VarType{Expr: "money<USD>", Type: T_Money, Money: &VarMoneyType{Currency: "USD"}}})
+	_, err = s.ToAvro()
+	assert.EqualError(t, err, "message 'User' field 'address': message 'Address' field 'price': type 'money<USD>' has no Avro equivalent")
+}
+
+func TestToAvroUnionBranches(t *testing.T) {
+	s := newTestSchema("User", "Error")
+	c := &avroConverter{defined: map[string]bool{}}
+
+	tt := []struct {
+		Expr  string
+		Union []interface{}
+	}{
+		{"union<int32|int64>", []interface{}{"int", "long"}},
+		{"union<int64|uint64>", []interface{}{"long"}},
+		{"union<User?|string?>", []interface{}{"null", "User", "string"}},
+		{"union<timestamp|string>?", []interface{}{"null", avroPrimitives[T_Timestamp], "string"}},
+		{"result<User,User>", []interface{}{"User"}},
+		{"result<string,string>", []interface{}{"string"}},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		c.defined = map[string]bool{"User": true}
+		union, err := c.varType(vt)
+		if assert.NoError(t, err, tc.Expr) {
+			assert.Equal(t, tc.Union, union, tc.Expr)
+		}
+	}
+
+	errs := []struct {
+		Expr  string
+		Error string
+	}{
+		{"union<timestamp|int64>", "union has more than one Avro 'long' branch"},
+		{"union<[]string|[]User>", "union has more than one Avro 'array' branch"},
+	}
+	for _, tc := range errs {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		c.defined = map[string]bool{"User": true}
+		_, err := c.varType(vt)
+		assert.EqualError(t, err, tc.Error, tc.Expr)
+	}
+}
@@ -0,0 +1,197 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	ErrDuplicateName ErrorCode = "duplicate-name"
+	ErrCyclicType    ErrorCode = "cyclic-type"
+)
+
+// CheckError is a single problem reported by Check
+type CheckError struct {
+	Code     ErrorCode
+	Location string // ie. "message 'User' field 'ID'"
+	Msg      string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s: %s [%s]", e.Location, e.Msg, e.Code)
+}
+
+// Check runs a full pass over the schema type graph, reporting all duplicate
+// definitions, unresolvable types, invalid map keys and cyclic message
+// references it finds. Unlike Validate, it doesn't stop at the first error
+// and doesn't modify the schema. Errors are reported in declaration order,
+// grouped by kind.
+func (s *WebRPCSchema) Check() []error {
+	c := &schemaChecker{schema: s, refs: map[*Message][]structRef{}}
+
+	c.checkDuplicates()
+	c.checkTypes()
+	c.checkCycles()
+
+	return c.errs
+}
+
+type schemaChecker struct {
+	schema *WebRPCSchema
+	errs   []error
+
+	// required, direct struct references of each message, see checkCycles
+	refs map[*Message][]structRef
+}
+
+type structRef struct {
+	field  VarName
+	target *Message
+}
+
+func (c *schemaChecker) report(code ErrorCode, location string, format string, args ...interface{}) {
+	c.errs = append(c.errs, &CheckError{Code: code, Location: location, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *schemaChecker) checkDuplicates() {
+	seen := map[string]bool{}
+	for _, msg := range c.schema.Messages {
+		name := strings.ToLower(string(msg.Name))
+		if seen[name] {
+			c.report(ErrDuplicateName, fmt.Sprintf("message '%s'", msg.Name), "duplicate message type")
+		}
+		seen[name] = true
+
+		fields := map[string]bool{}
+		for _, field := range msg.Fields {
+			name := strings.ToLower(string(field.Name))
+			if fields[name] {
+				c.report(ErrDuplicateName, fmt.Sprintf("message '%s' field '%s'", msg.Name, field.Name), "duplicate field name")
+			}
+			fields[name] = true
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, svc := range c.schema.Services {
+		name := strings.ToLower(string(svc.Name))
+		if seen[name] {
+			c.report(ErrDuplicateName, fmt.Sprintf("service '%s'", svc.Name), "duplicate service name")
+		}
+		seen[name] = true
+
+		methods := map[string]bool{}
+		for _, method := range svc.Methods {
+			name := strings.ToLower(string(method.Name))
+			if methods[name] {
+				c.report(ErrDuplicateName, fmt.Sprintf("service '%s' method '%s'", svc.Name, method.Name), "duplicate method name")
+			}
+			methods[name] = true
+		}
+	}
+}
+
+// checkType parses a copy of the given type, so the schema is left untouched
+func (c *schemaChecker) checkType(location string, t *VarType) *VarType {
+	if t == nil || t.Expr == "" {
+		c.report(ErrInvalidSyntax, location, "type expr cannot be empty")
+		return nil
+	}
+
+	vt := &VarType{}
+	err := ParseVarTypeExpr(c.schema, t.Expr, vt)
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			c.report(perr.Code, location, "%v", err)
+		} else {
+			c.report(ErrInvalidSyntax, location, "%v", err)
+		}
+		return nil
+	}
+	return vt
+}
+
+func (c *schemaChecker) checkTypes() {
+	for _, alias := range c.schema.Aliases {
+		c.checkType(fmt.Sprintf("alias '%s'", alias.Name), alias.Type)
+	}
+
+	for _, msg := range c.schema.Messages {
+		for _, field := range msg.Fields {
+			location := fmt.Sprintf("message '%s' field '%s'", msg.Name, field.Name)
+			if field.Type != nil {
+				// inline enums only become a type once materialized by Validate
+				values, _, err := parseInlineEnumExpr(field.Type.Expr, c.schema.parseOptions())
+				if err != nil {
+					c.report(ErrInvalidSyntax, location, "%v", err)
+				}
+				if err != nil || values != nil {
+					continue
+				}
+			}
+			vt := c.checkType(location, field.Type)
+			if vt != nil && vt.Type == T_Struct && !field.Optional && !vt.Optional && !vt.Nullable {
+				c.refs[msg] = append(c.refs[msg], structRef{field: field.Name, target: vt.Struct.Message})
+			}
+		}
+	}
+
+	for _, svc := range c.schema.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				c.checkType(fmt.Sprintf("service '%s' method '%s' input '%s'", svc.Name, method.Name, input.Name), input.Type)
+			}
+			for _, output := range method.Outputs {
+				c.checkType(fmt.Sprintf("service '%s' method '%s' output '%s'", svc.Name, method.Name, output.Name), output.Type)
+			}
+		}
+	}
+}
+
+// checkCycles reports messages which reference themselves through a chain of
+// required struct fields, as such a value could never be fully constructed.
+// References through optional or nullable fields, lists or maps are allowed.
+func (c *schemaChecker) checkCycles() {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[*Message]int{}
+	path := []string{}
+
+	var visit func(msg *Message)
+	visit = func(msg *Message) {
+		state[msg] = visiting
+		path = append(path, string(msg.Name))
+
+		for _, r := range c.refs[msg] {
+			ref := r.target
+			switch state[ref] {
+			case unvisited:
+				visit(ref)
+			case visiting:
+				cycle := []string{}
+				for i := range path {
+					if path[i] == string(ref.Name) {
+						cycle = append(cycle, path[i:]...)
+						break
+					}
+				}
+				cycle = append(cycle, string(ref.Name))
+				c.report(ErrCyclicType, fmt.Sprintf("message '%s' field '%s'", msg.Name, r.field), "cyclic type reference %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[msg] = visited
+	}
+
+	for _, msg := range c.schema.Messages {
+		if state[msg] == unvisited {
+			visit(msg)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "check",
+		"version": "v0.1.0",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "id", "type": "uint64" },
+					{ "name": "profile", "type": "Profile" },
+					{ "name": "friends", "type": "[]User" },
+					{ "name": "address", "type": "Address" }
+				]
+			},
+			{
+				"name": "Profile",
+				"type": "struct",
+				"fields": [
+					{ "name": "owner", "type": "User" },
+					{ "name": "flags", "type": "map<bool,string>" }
+				]
+			},
+			{
+				"name": "user",
+				"type": "struct",
+				"fields": []
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "req", "type": "map<string" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	var s *WebRPCSchema
+	assert.NoError(t, json.Unmarshal([]byte(input), &s))
+
+	errs := s.Check()
+	codes := []ErrorCode{}
+	locations := []string{}
+	for _, err := range errs {
+		cerr, ok := err.(*CheckError)
+		assert.True(t, ok)
+		codes = append(codes, cerr.Code)
+		locations = append(locations, cerr.Location)
+	}
+
+	assert.Equal(t, []ErrorCode{
+		ErrDuplicateName,
+		ErrDuplicateName,
+		ErrUnknownType,
+		ErrInvalidMapKey,
+		ErrInvalidSyntax,
+		ErrCyclicType,
+	}, codes)
+	assert.Equal(t, []string{
+		"message 'User' field 'id'",
+		"message 'user'",
+		"message 'User' field 'address'",
+		"message 'Profile' field 'flags'",
+		"service 'UserService' method 'GetUser' input 'req'",
+		"message 'Profile' field 'owner'",
+	}, locations)
+	assert.Contains(t, errs[5].Error(), "User -> Profile -> User")
+
+	// deterministic across runs
+	assert.Equal(t, errs, s.Check())
+}
+
+func TestCheckCycles(t *testing.T) {
+	check := func(fieldType string) []error {
+		var s *WebRPCSchema
+		assert.NoError(t, json.Unmarshal([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [{ "name": "manager", "type": "`+fieldType+`" }] }]
+		}`), &s))
+		return s.Check()
+	}
+
+	// a nil manager ends the chain
+	for _, fieldType := range []string{"User?", "optional<User>", "nullable<User>", "[]User"} {
+		assert.Empty(t, check(fieldType), fieldType)
+
+		_, err := ParseSchema(strings.NewReader(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [{ "name": "manager", "type": "` + fieldType + `" }] }]
+		}`))
+		assert.NoError(t, err, fieldType)
+	}
+
+	errs := check("User")
+	if assert.Equal(t, 1, len(errs)) {
+		assert.Equal(t, ErrCyclicType, errs[0].(*CheckError).Code)
+		assert.Contains(t, errs[0].Error(), "User -> User")
+	}
+}
@@ -0,0 +1,269 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Coerce converts a loosely-typed JSON value, as decoded into an
+// interface{}, to the shape of the type, for gateways bridging untyped
+// requests. Numeric strings and integral floats become integers, ie. "42" or
+// 42.0 to uint64(42) for uint32, and numbers become floats. Signed integers
+// are returned as int64, unsigned ones as uint64. Lists, maps and struct
+// fields are coerced element by element, unknown struct fields are kept as
+// is. Values that can't be converted are an error.
+func (t *VarType) Coerce(v interface{}) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		v = string(n)
+	}
+	if v == nil {
+		switch {
+		case t.Optional, t.Nullable, t.Type == T_Null, t.Type == T_Any, t.Type == T_List, t.Type == T_Map:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot coerce null to required type '%s'", t.Expr)
+	}
+
+	invalid := func() error {
+		return fmt.Errorf("cannot coerce %T value '%v' to type '%s'", v, v, t.Expr)
+	}
+
+	switch t.Type {
+	case T_Any:
+		return v, nil
+
+	case T_Bool:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return nil, invalid()
+			}
+			return parsed, nil
+		}
+		return nil, invalid()
+
+	case T_Ratio:
+		f, err := (&VarType{Expr: t.Expr, Type: T_Float64}).Coerce(v)
+		if err != nil {
+			return nil, invalid()
+		}
+		if err := t.Range.check(f.(float64)); err != nil {
+			return nil, fmt.Errorf("%T value '%v' is not a valid '%s': %v", v, v, t.Expr, err)
+		}
+		return f, nil
+
+	case T_Float32, T_Float64:
+		switch f := v.(type) {
+		case float64:
+			return f, nil
+		case int64:
+			return float64(f), nil
+		case uint64:
+			return float64(f), nil
+		case string:
+			parsed, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, invalid()
+			}
+			return parsed, nil
+		}
+		return nil, invalid()
+
+	case T_String, T_Timestamp, T_Date, T_Time, T_DateTime, T_UUID, T_Rational:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, invalid()
+
+	case T_IPAddr, T_CIDR:
+		if s, ok := v.(string); ok && isValidIPValue(s, t.Type) {
+			return s, nil
+		}
+		return nil, invalid()
+
+	case T_Decimal:
+		switch n := v.(type) {
+		case string:
+			if _, err := strconv.ParseFloat(n, 64); err != nil {
+				return nil, invalid()
+			}
+			return n, nil
+		case float64:
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		}
+		return nil, invalid()
+
+	case T_BigInt:
+		switch n := v.(type) {
+		case string:
+			if !isDecimalDigits(n) {
+				return nil, invalid()
+			}
+			return n, nil
+		case float64:
+			if n != math.Trunc(n) || math.IsInf(n, 0) {
+				return nil, invalid()
+			}
+			return strconv.FormatFloat(n, 'f', 0, 64), nil
+		}
+		return nil, invalid()
+
+	case T_List:
+		if s, ok := v.(string); ok && t.IsBytes() {
+			if !isBase64(s) {
+				return nil, invalid()
+			}
+			return s, nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+		if t.IsBytes() {
+			// a list of numbers, encoded to its base64 wire form
+			b := make([]byte, 0, len(list))
+			byteType := &VarType{Expr: t.List.Elem.Expr, Type: T_Uint8}
+			for i, elem := range list {
+				coerced, err := byteType.Coerce(elem)
+				if err != nil {
+					return nil, fmt.Errorf("[%d]: %w", i, err)
+				}
+				b = append(b, byte(coerced.(uint64)))
+			}
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+		out := make([]interface{}, 0, len(list))
+		for i, elem := range list {
+			coerced, err := t.List.Elem.Coerce(elem)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out = append(out, coerced)
+		}
+		return out, nil
+
+	case T_Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+		out := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			if isIntegerType(t.Map.Key) {
+				if _, err := parseIntLiteral(key, t.Map.Key); err != nil {
+					return nil, fmt.Errorf("map key '%s': %w", key, err)
+				}
+			}
+			if (t.Map.Key == T_IPAddr || t.Map.Key == T_CIDR) && !isValidIPValue(key, t.Map.Key) {
+				return nil, fmt.Errorf("map key '%s' is not a valid %s", key, t.Map.Key)
+			}
+			coerced, err := t.Map.Value.Coerce(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%q]: %w", key, err)
+			}
+			out[key] = coerced
+		}
+		return out, nil
+
+	case T_Union:
+		for _, variant := range t.Union.Variants {
+			if coerced, err := variant.Coerce(v); err == nil {
+				return coerced, nil
+			}
+		}
+		return nil, invalid()
+
+	case T_Struct:
+		msg := t.Struct.Message
+		if msg == nil {
+			return nil, invalid()
+		}
+		if msg.Type == "enum" {
+			if name, ok := v.(string); ok && msg.getField(VarName(name)) != nil {
+				return name, nil
+			}
+			return msg.EnumType.Coerce(v)
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+		out := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			out[key] = value
+		}
+		for _, field := range msg.Fields {
+			value, ok := obj[field.WireName()]
+			if !ok || field.JSONOmitted {
+				continue
+			}
+			if value == nil && field.Optional {
+				continue
+			}
+			coerced, err := field.Type.Coerce(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.WireName(), err)
+			}
+			out[field.WireName()] = coerced
+		}
+		return out, nil
+
+	default:
+		if isIntegerType(t.Type) {
+			return coerceInt(v, t.Type, invalid)
+		}
+		// results, money and geo points are kept as is
+		return v, nil
+	}
+}
+
+// coerceInt converts a float or numeric string to the integer type, as an
+// int64 for signed types and an uint64 for unsigned ones
+func coerceInt(v interface{}, dt DataType, invalid func() error) (interface{}, error) {
+	var lit string
+	switch n := v.(type) {
+	case string:
+		lit = n
+	case float64:
+		if n != math.Trunc(n) || math.IsInf(n, 0) || math.Abs(n) > 1<<53 {
+			return nil, invalid()
+		}
+		lit = strconv.FormatFloat(n, 'f', 0, 64)
+	case int64:
+		lit = strconv.FormatInt(n, 10)
+	case uint64:
+		lit = strconv.FormatUint(n, 10)
+	default:
+		return nil, invalid()
+	}
+
+	value, err := parseIntLiteral(lit, dt)
+	if err != nil {
+		return nil, fmt.Errorf("cannot coerce '%v': %w", v, err)
+	}
+	if isUnsignedType(dt) {
+		return strconv.ParseUint(value, 10, 64)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func isDecimalDigits(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
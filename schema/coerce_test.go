@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeCoerce(t *testing.T) {
+	s, err := ParseSchemaJSON([]byte(`{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "score", "type": "float64" },
+					{ "name": "tags", "type": "[]string" }
+				]
+			}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	tt := []struct {
+		Expr  string
+		Value interface{}
+		Want  interface{}
+	}{
+		{"uint32", "42", uint64(42)},
+		{"uint32", 42.0, uint64(42)},
+		{"int64", "-7", int64(-7)},
+		{"int8", json.Number("0x10"), int64(16)},
+		{"float64", "1.5", 1.5},
+		{"bool", "true", true},
+		{"bigint", 1e20, "100000000000000000000"},
+		{"string?", nil, nil},
+		{"map<uint64,[]int32>", map[string]interface{}{"1": []interface{}{"2", 3.0}}, map[string]interface{}{"1": []interface{}{int64(2), int64(3)}}},
+		{
+			"User",
+			map[string]interface{}{"id": "9", "score": "0.5", "tags": []interface{}{"a"}, "extra": true},
+			map[string]interface{}{"id": uint64(9), "score": 0.5, "tags": []interface{}{"a"}, "extra": true},
+		},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		got, err := vt.Coerce(tc.Value)
+		if assert.NoError(t, err, tc.Expr) {
+			assert.Equal(t, tc.Want, got, tc.Expr)
+		}
+	}
+
+	errs := []struct {
+		Expr  string
+		Value interface{}
+		Error string
+	}{
+		{"uint32", "-1", "cannot coerce '-1': integer literal '-1' is negative, but uint32 is unsigned"},
+		{"uint8", 300.0, "cannot coerce '300': integer literal '300' is out of range for uint8"},
+		{"int64", 1.5, "cannot coerce float64 value '1.5' to type 'int64'"},
+		{"uint32", true, "cannot coerce bool value 'true' to type 'uint32'"},
+		{"string", 42.0, "cannot coerce float64 value '42' to type 'string'"},
+		{"uint64", nil, "cannot coerce null to required type 'uint64'"},
+		{"[]uint64", []interface{}{"1", "x"}, "[1]: cannot coerce 'x': invalid integer literal 'x'"},
+		{"User", map[string]interface{}{"id": "abc"}, "id: cannot coerce 'abc': invalid integer literal 'abc'"},
+	}
+	for _, tc := range errs {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		_, err := vt.Coerce(tc.Value)
+		assert.EqualError(t, err, tc.Error, tc.Expr)
+	}
+}
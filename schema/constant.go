@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constant is a named integer value, ie. `const MAX = 100`, which can be
+// referenced by integer field defaults to avoid magic numbers.
+type Constant struct {
+	Name  VarName `json:"name"`
+	Value string  `json:"value"`
+}
+
+func (c *Constant) Parse(schema *WebRPCSchema) error {
+	constName := string(c.Name)
+	if !IsValidArgName(constName) {
+		return fmt.Errorf("schema error: invalid constant name '%s'", constName)
+	}
+
+	// Ensure we don't have dupe constants (w/ normalization)
+	name := strings.ToLower(constName)
+	for _, constant := range schema.Constants {
+		if constant != c && name == strings.ToLower(string(constant.Name)) {
+			return fmt.Errorf("schema error: duplicate constant detected, '%s'", constName)
+		}
+	}
+
+	// constants are untyped, so only check the value is an integer of some
+	// width, and normalize it to decimal
+	value, err := parseIntLiteral(c.Value, T_Int64)
+	if err != nil {
+		value, err = parseIntLiteral(c.Value, T_Uint64)
+	}
+	if err != nil {
+		return fmt.Errorf("schema error: constant '%s' has invalid value: %v", constName, err)
+	}
+	c.Value = value
+	return nil
+}
+
+func getConstant(schema *WebRPCSchema, name string) (*Constant, bool) {
+	if schema == nil {
+		return nil, false
+	}
+	for _, constant := range schema.Constants {
+		if name == string(constant.Name) {
+			return constant, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantDefaults(t *testing.T) {
+	parse := func(constants, fields string) (*WebRPCSchema, error) {
+		return ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"constants": [` + constants + `],
+			"messages": [{ "name": "Query", "type": "struct", "fields": [` + fields + `] }]
+		}`))
+	}
+
+	s, err := parse(
+		`{ "name": "MAX", "value": "100" }, { "name": "MASK", "value": "0b1111" }`,
+		`{ "name": "limit", "type": "uint32", "meta": [{ "default": "MAX" }] },
+		 { "name": "mask", "type": "uint8", "meta": [{ "default": "MASK" }] },
+		 { "name": "offset", "type": "uint32", "meta": [{ "default": "0x10" }] },
+		 { "name": "sort", "type": "string", "meta": [{ "default": "asc" }] }`,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "15", s.Constants[1].Value)
+
+	fields := s.GetMessageByName("Query").Fields
+	assert.Equal(t, "100", fields[0].Default)
+	assert.Equal(t, "15", fields[1].Default)
+	assert.Equal(t, "16", fields[2].Default)
+	assert.Equal(t, "asc", fields[3].Default)
+
+	tt := []struct {
+		Constants string
+		Fields    string
+		Error     string
+	}{
+		{
+			`{ "name": "MAX", "value": "1000" }`,
+			`{ "name": "limit", "type": "uint8", "meta": [{ "default": "MAX" }] }`,
+			"schema error: invalid default for field 'limit' in message 'Query': integer literal '1000' is out of range for uint8",
+		},
+		{
+			`{ "name": "MAX", "value": "100" }`,
+			`{ "name": "sort", "type": "string", "meta": [{ "default": "MAX" }] }`,
+			"schema error: default for field 'sort' in message 'Query' references integer constant 'MAX', but the field is of type 'string'",
+		},
+		{
+			`{ "name": "MAX", "value": "100" }`,
+			`{ "name": "limit", "type": "uint32", "meta": [{ "default": "MIN" }] }`,
+			"schema error: invalid default for field 'limit' in message 'Query': invalid integer literal 'MIN'",
+		},
+		{
+			`{ "name": "MAX", "value": "lots" }`,
+			``,
+			"schema error: constant 'MAX' has invalid value: invalid integer literal 'lots'",
+		},
+		{
+			`{ "name": "MAX", "value": "1" }, { "name": "max", "value": "2" }`,
+			``,
+			"schema error: duplicate constant detected, 'MAX'",
+		},
+	}
+	for _, tc := range tt {
+		_, err := parse(tc.Constants, tc.Fields)
+		if assert.Error(t, err) {
+			assert.Equal(t, tc.Error, err.Error())
+		}
+	}
+}
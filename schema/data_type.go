@@ -38,10 +38,61 @@ const (
 	T_Map
 
 	T_Struct // aka, a reference to our own webrpc proto struct/message
+
+	// Data types below were added later. New ones are appended, so the
+	// values of existing data types never change.
+
+	T_Result
+	T_BigInt
+	T_UUID
+	T_Void
+
+	T_Date
+	T_Time
+	T_DateTime
+
+	T_Money
+	T_GeoPoint
+	T_Union
+	T_Rational
+	T_Decimal
+	T_Blob
+
+	T_IPAddr
+	T_CIDR
+
+	T_Ratio
+
+	numDataTypes // keep last, used to check allDataTypes is complete
 )
 
+// allDataTypes lists every data type, in declaration order
+var allDataTypes = []DataType{
+	T_Null, T_Any, T_Byte, T_Bool,
+	T_Uint, T_Uint8, T_Uint16, T_Uint32, T_Uint64,
+	T_Int, T_Int8, T_Int16, T_Int32, T_Int64,
+	T_Float32, T_Float64,
+	T_String,
+	T_Timestamp,
+	T_List, T_Map,
+	T_Struct,
+	T_Result, T_BigInt, T_UUID, T_Void,
+	T_Date, T_Time, T_DateTime,
+	T_Money, T_GeoPoint, T_Union, T_Rational, T_Decimal, T_Blob,
+	T_IPAddr, T_CIDR,
+	T_Ratio,
+}
+
+// AllDataTypes returns every data type except T_Unknown, so generators can
+// check their type mapping tables handle each of them. T_Struct is the only
+// type without a String name, as it's named by its message.
+func AllDataTypes() []DataType {
+	return append([]DataType{}, allDataTypes...)
+}
+
 var DataTypeToString = map[DataType]string{
 	T_Null: "null",
+	T_Void: "void",
 	T_Any:  "any",
 	T_Byte: "byte",
 	T_Bool: "bool",
@@ -61,16 +112,40 @@ var DataTypeToString = map[DataType]string{
 	T_Float32: "float32",
 	T_Float64: "float64",
 
+	T_Ratio: "ratio",
+
 	T_String: "string",
 
 	T_Timestamp: "timestamp",
 
-	T_Map:  "map",
-	T_List: "[]",
+	T_Date:     "date",
+	T_Time:     "time",
+	T_DateTime: "datetime",
+
+	T_BigInt:   "bigint",
+	T_Rational: "rational",
+	T_Decimal:  "decimal",
+
+	T_UUID: "uuid",
+
+	T_IPAddr: "ipaddr",
+	T_CIDR:   "cidr",
+
+	T_GeoPoint: "geopoint",
+
+	T_Blob: "blob",
+
+	T_Map:    "map",
+	T_List:   "[]",
+	T_Result: "result",
+	T_Union:  "union",
+
+	T_Money: "money",
 }
 
 var DataTypeFromString = map[string]DataType{
 	"null": T_Null,
+	"void": T_Void,
 	"any":  T_Any,
 	"byte": T_Byte,
 	"bool": T_Bool,
@@ -90,18 +165,49 @@ var DataTypeFromString = map[string]DataType{
 	"float32": T_Float32,
 	"float64": T_Float64,
 
+	"ratio": T_Ratio,
+
 	"string": T_String,
 
 	"timestamp": T_Timestamp,
 
-	"map": T_Map,
-	"[]":  T_List,
+	"date":     T_Date,
+	"time":     T_Time,
+	"datetime": T_DateTime,
+
+	"bigint":   T_BigInt,
+	"rational": T_Rational,
+	"decimal":  T_Decimal,
+
+	"uuid": T_UUID,
+
+	"ipaddr": T_IPAddr,
+	"cidr":   T_CIDR,
+
+	"geopoint": T_GeoPoint,
+
+	"blob": T_Blob,
+
+	"map":    T_Map,
+	"[]":     T_List,
+	"result": T_Result,
+	"union":  T_Union,
+
+	"money": T_Money,
 }
 
 func (t DataType) String() string {
 	return DataTypeToString[t]
 }
 
+// containerName returns a readable name for container types, ie. "list"
+func (t DataType) containerName() string {
+	if t == T_List {
+		return "list"
+	}
+	return t.String()
+}
+
 func (t DataType) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBufferString(`"`)
 	buf.WriteString(DataTypeToString[t])
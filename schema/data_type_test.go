@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllDataTypes(t *testing.T) {
+	all := AllDataTypes()
+
+	// fails when a new data type isn't added to allDataTypes
+	assert.Equal(t, int(numDataTypes)-1, len(all))
+
+	for i, dt := range all {
+		assert.Equal(t, DataType(i+1), dt, "allDataTypes must be in declaration order")
+		if dt == T_Struct {
+			assert.Equal(t, "", dt.String())
+			continue
+		}
+
+		// fails when a new data type has no String mapping
+		name := dt.String()
+		if assert.NotEmpty(t, name, "data type %d has no name", dt) {
+			assert.Equal(t, dt, DataTypeFromString[name], name)
+		}
+	}
+
+	// the returned slice is a copy
+	all[0] = T_Unknown
+	assert.Equal(t, T_Null, AllDataTypes()[0])
+}
+
+func TestDataTypeValues(t *testing.T) {
+	// values of the original data types are part of the API, and must not
+	// change when new data types are added
+	assert.Equal(t, DataType(1), T_Null)
+	assert.Equal(t, DataType(2), T_Any)
+	assert.Equal(t, DataType(3), T_Byte)
+	assert.Equal(t, DataType(17), T_String)
+	assert.Equal(t, DataType(18), T_Timestamp)
+	assert.Equal(t, DataType(19), T_List)
+	assert.Equal(t, DataType(20), T_Map)
+	assert.Equal(t, DataType(21), T_Struct)
+}
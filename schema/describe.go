@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// describeDataTypes names basic data types in prose, see Describe
+var describeDataTypes = map[DataType]string{
+	T_Null:   "null",
+	T_Any:    "value of any type",
+	T_Byte:   "byte",
+	T_Bool:   "boolean",
+	T_String: "string",
+
+	T_Timestamp: "timestamp",
+	T_Date:      "date",
+	T_Time:      "time of day",
+	T_DateTime:  "date and time",
+
+	T_Ratio:    "bounded float",
+	T_BigInt:   "big integer",
+	T_Rational: "rational number",
+	T_Decimal:  "decimal number",
+	T_UUID:     "UUID",
+	T_IPAddr:   "IP address",
+	T_CIDR:     "CIDR block",
+	T_GeoPoint: "geo point",
+	T_Blob:     "blob",
+}
+
+// Describe returns a human-readable description of the type for generated
+// documentation, ie. "a map from string to a list of User objects" for
+// map<string,[]User>. Struct and enum names appear verbatim.
+func (t *VarType) Describe() string {
+	return withArticle(t.describe(false))
+}
+
+// describe returns the noun phrase for the type, without an article
+func (t *VarType) describe(plural bool) string {
+	if t.Optional {
+		base := *t
+		base.Optional = false
+		return "optional " + base.describe(plural)
+	}
+	if t.Nullable {
+		base := *t
+		base.Nullable = false
+		return "nullable " + base.describe(plural)
+	}
+
+	switch t.Type {
+	case T_List:
+		if t.List.Unique {
+			return pluralize("list", plural) + " of unique " + t.List.Elem.describe(true)
+		}
+		return pluralize("list", plural) + " of " + t.List.Elem.describe(true)
+	case T_Map:
+		key := describeDataType(t.Map.Key, false)
+		return fmt.Sprintf("%s from %s to %s", pluralize("map", plural), key, withArticle(t.Map.Value.describe(false)))
+	case T_Result:
+		return fmt.Sprintf("%s of %s or %s", pluralize("result", plural), withArticle(t.Result.Ok.describe(false)), withArticle(t.Result.Err.describe(false)))
+	case T_Union:
+		variants := make([]string, 0, len(t.Union.Variants))
+		for _, variant := range t.Union.Variants {
+			variants = append(variants, withArticle(variant.describe(false)))
+		}
+		last := len(variants) - 1
+		union := pluralize("union", plural)
+		if t.Union.Discriminator != "" {
+			union += fmt.Sprintf(" tagged by '%s'", t.Union.Discriminator)
+		}
+		return fmt.Sprintf("%s of %s or %s", union, strings.Join(variants[:last], ", "), variants[last])
+	case T_Money:
+		return t.Money.Currency + " " + pluralize("amount", plural)
+	case T_Struct:
+		if t.Struct.Message != nil && t.Struct.Message.Type == "enum" {
+			return t.Struct.Name + " " + pluralize("value", plural)
+		}
+		return t.Struct.Name + " " + pluralize("object", plural)
+	default:
+		return describeDataType(t.Type, plural)
+	}
+}
+
+func describeDataType(dt DataType, plural bool) string {
+	name, ok := describeDataTypes[dt]
+	if !ok {
+		// integers and floats read fine as is, ie. "uint64"
+		name = dt.String()
+	}
+	switch {
+	case !plural || dt == T_Null:
+		return name
+	case dt == T_Any:
+		return "values of any type"
+	case dt == T_DateTime:
+		return "dates and times"
+	case dt == T_Time:
+		return "times of day"
+	case !ok:
+		return name + " values"
+	default:
+		return name + "s"
+	}
+}
+
+func pluralize(noun string, plural bool) string {
+	if plural {
+		return noun + "s"
+	}
+	return noun
+}
+
+// withArticle prefixes the singular noun phrase with "a" or "an". Words like
+// "uint64", "union" or "UUID" start with a consonant sound, so only a, e, i
+// and o take "an".
+func withArticle(phrase string) string {
+	if phrase == "null" {
+		return phrase
+	}
+	if strings.ContainsRune("aeioAEIO", rune(phrase[0])) {
+		return "an " + phrase
+	}
+	return "a " + phrase
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeDescribe(t *testing.T) {
+	s := newTestSchema("User", "Error")
+	s.Messages = append(s.Messages, &Message{Name: "Kind", Type: "enum"})
+
+	tt := []struct {
+		Expr     string
+		Describe string
+	}{
+		{"string", "a string"},
+		{"int64?", "an optional int64"},
+		{"map<string,[]User>", "a map from string to a list of User objects"},
+		{"[]map<uint64,User?>", "a list of maps from uint64 to an optional User object"},
+		{"[][]int32?", "a list of lists of optional int32 values"},
+		{"map<uuid,timestamp>?", "an optional map from UUID to a timestamp"},
+		{"[]Kind", "a list of Kind values"},
+		{"result<[]User,Error>", "a result of a list of User objects or an Error object"},
+		{"union<User|Error|string>", "a union of a User object, an Error object or a string"},
+		{"[]any", "a list of values of any type"},
+		{"map<string,any>", "a map from string to a value of any type"},
+		{"money<USD>", "a USD amount"},
+		{"[]uuid", "a list of UUIDs"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.Describe, vt.Describe(), tc.Expr)
+		}
+	}
+}
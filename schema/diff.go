@@ -0,0 +1,201 @@
+package schema
+
+// FieldChange is a field whose type changed between two schema versions
+type FieldChange struct {
+	Message string
+	Field   string
+	Old     *VarType
+	New     *VarType
+
+	// Breaking is set when values of the old type can't be decoded as the
+	// new type, see VarType.JSONCompatible
+	Breaking bool
+}
+
+// ChangedFields lists the fields present in both s and the old version of
+// the schema whose types differ, in the declaration order of s. Both schemas
+// must be parsed.
+func (s *WebRPCSchema) ChangedFields(old *WebRPCSchema) []FieldChange {
+	changes := []FieldChange{}
+	for _, msg := range s.Messages {
+		oldMsg := old.GetMessageByName(string(msg.Name))
+		if oldMsg == nil || oldMsg.Type != msg.Type {
+			continue
+		}
+		for _, field := range msg.Fields {
+			oldField := oldMsg.getField(field.Name)
+			if oldField == nil || field.Type.Equal(oldField.Type) {
+				continue
+			}
+			changes = append(changes, FieldChange{
+				Message:  string(msg.Name),
+				Field:    string(field.Name),
+				Old:      oldField.Type,
+				New:      field.Type,
+				Breaking: !field.Type.JSONCompatible(oldField.Type),
+			})
+		}
+	}
+	return changes
+}
+
+func (m *Message) getField(name VarName) *MessageField {
+	for _, field := range m.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// JSONCompatible reports whether every JSON value of the old type is also a
+// valid value of t, so that changing a type from old to t is non-breaking
+// for existing payloads. This holds when t is equal to old or widens it:
+// required to optional, integers to a wider integer of the same or larger
+// range, float32 to float64, string-encoded types to string, a type to a
+// union accepting it, and containers of compatible types. Struct types are compatible by name, as their fields
+// are compared separately, see ChangedFields.
+func (t *VarType) JSONCompatible(old *VarType) bool {
+	if t == nil || old == nil {
+		return t == old
+	}
+	if (old.Optional && !t.Optional) || (old.Nullable && !t.Nullable) {
+		return false
+	}
+
+	switch {
+	case t.Type == old.Type:
+	case t.Type == T_String && isStringWireType(old.Type):
+		return true
+	case isIntegerType(t.Type) && isIntegerType(old.Type):
+		return isWiderInteger(t.Type, old.Type)
+	case t.Type == T_Float64 && (old.Type == T_Float32 || old.Type == T_Ratio || (isIntegerType(old.Type) && intDataTypeBits[old.Type] <= 32)):
+		return true
+	case t.Type == T_Any:
+		return true
+	case t.Type == T_Union:
+		// widening a type into a union of it
+		for _, variant := range t.Union.Variants {
+			if variant.JSONCompatible(old) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+
+	switch t.Type {
+	case T_List:
+		return t.List.Elem.JSONCompatible(old.List.Elem)
+	case T_Map:
+		keyType := &VarType{Type: t.Map.Key}
+		return keyType.JSONCompatible(&VarType{Type: old.Map.Key}) && t.Map.Value.JSONCompatible(old.Map.Value)
+	case T_Result:
+		return t.Result.Ok.JSONCompatible(old.Result.Ok) && t.Result.Err.JSONCompatible(old.Result.Err)
+	case T_Union:
+		// every old variant must be accepted by one of the new variants
+		for _, oldVariant := range old.Union.Variants {
+			compatible := false
+			for _, variant := range t.Union.Variants {
+				if variant.JSONCompatible(oldVariant) {
+					compatible = true
+					break
+				}
+			}
+			if !compatible {
+				return false
+			}
+		}
+		return true
+	case T_Money:
+		return t.Money.Currency == old.Money.Currency
+	case T_Ratio:
+		// a range holding the old one still accepts every old value
+		min, max := t.Range.bounds()
+		oldMin, oldMax := old.Range.bounds()
+		return min <= oldMin && max >= oldMax
+	case T_Decimal:
+		// values of a smaller scale and fewer integer digits still fit
+		if t.Decimal == nil || old.Decimal == nil {
+			return t.Decimal == nil
+		}
+		return t.Decimal.Scale >= old.Decimal.Scale && t.Decimal.Precision-t.Decimal.Scale >= old.Decimal.Precision-old.Decimal.Scale
+	case T_Struct:
+		return t.Struct.Name == old.Struct.Name
+	default:
+		return true
+	}
+}
+
+// isStringWireType reports whether values of the data type are JSON strings
+func isStringWireType(dt DataType) bool {
+	switch dt {
+	case T_String, T_Timestamp, T_Date, T_Time, T_DateTime, T_BigInt, T_Rational, T_Decimal, T_UUID, T_IPAddr, T_CIDR:
+		return true
+	}
+	return false
+}
+
+// isWiderInteger reports whether every value of the integer type old fits
+// the integer type t
+func isWiderInteger(t, old DataType) bool {
+	bits, oldBits := intDataTypeBits[t], intDataTypeBits[old]
+	switch {
+	case isUnsignedType(t) == isUnsignedType(old):
+		return bits >= oldBits
+	case isUnsignedType(old):
+		// signed t needs a spare bit for the unsigned range
+		return bits > oldBits
+	default:
+		// unsigned t can't hold negative values
+		return false
+	}
+}
+
+// EnumChange lists the differences between two versions of an enum
+type EnumChange struct {
+	Enum string
+
+	Added      []string // values only in the new enum, which is non-breaking
+	Removed    []string // values only in the old enum
+	Renumbered []string // values in both enums, with a different number
+
+	// OldType and NewType are set when the underlying integer type changed
+	OldType *VarType
+	NewType *VarType
+
+	// Breaking is set for removed or renumbered values and type changes, as
+	// payloads or clients of the old enum may then hold invalid values
+	Breaking bool
+}
+
+// EnumDiff compares the old and new versions of an enum, returning nil when
+// they have the same values and type. Values are matched by name, so a
+// renamed value is reported as removed and added. Both enums must be parsed.
+func EnumDiff(old, new *Message) *EnumChange {
+	change := &EnumChange{Enum: string(new.Name)}
+
+	for _, field := range new.Fields {
+		oldField := old.getField(field.Name)
+		if oldField == nil {
+			change.Added = append(change.Added, string(field.Name))
+		} else if oldField.Value != field.Value {
+			change.Renumbered = append(change.Renumbered, string(field.Name))
+		}
+	}
+	for _, field := range old.Fields {
+		if new.getField(field.Name) == nil {
+			change.Removed = append(change.Removed, string(field.Name))
+		}
+	}
+	if !old.EnumType.Equal(new.EnumType) {
+		change.OldType, change.NewType = old.EnumType, new.EnumType
+	}
+
+	change.Breaking = len(change.Removed) > 0 || len(change.Renumbered) > 0 || change.NewType != nil
+	if !change.Breaking && len(change.Added) == 0 {
+		return nil
+	}
+	return change
+}
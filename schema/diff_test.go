@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeJSONCompatible(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		New, Old   string
+		Compatible bool
+	}{
+		{"uint64", "uint32", true},
+		{"uint32", "uint64", false},
+		{"int64", "uint32", true},
+		{"int32", "uint32", false},
+		{"uint64", "int32", false},
+		{"float64", "float32", true},
+		{"float64", "int32", true},
+		{"float32", "float64", false},
+		{"string", "uuid", true},
+		{"uuid", "string", false},
+		{"uint32?", "uint32", true},
+		{"uint32", "uint32?", false},
+		{"[]uint64", "[]uint32", true},
+		{"map<int64,[]string>", "map<int32,[]uuid>", true},
+		{"map<string,User>", "map<string,User?>", false},
+		{"any", "User", true},
+		{"User", "string", false},
+		{"union<User|string>", "User", true},
+		{"union<User|string|uint64>", "union<User|uint32>", true},
+		{"User", "union<User|string>", false},
+	}
+	for _, tc := range tt {
+		a, b := &VarType{Expr: tc.New}, &VarType{Expr: tc.Old}
+		assert.NoError(t, a.Parse(s))
+		assert.NoError(t, b.Parse(s))
+		assert.Equal(t, tc.Compatible, a.JSONCompatible(b), "%s from %s", tc.New, tc.Old)
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	parse := func(fields string) *WebRPCSchema {
+		s, err := ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [
+				{ "name": "User", "type": "struct", "fields": [` + fields + `] },
+				{ "name": "Other", "type": "struct", "fields": [] }
+			]
+		}`))
+		assert.NoError(t, err)
+		return s
+	}
+
+	old := parse(`
+		{ "name": "id", "type": "uint32" },
+		{ "name": "score", "type": "float64" },
+		{ "name": "name", "type": "string" },
+		{ "name": "removed", "type": "string" }`)
+	s := parse(`
+		{ "name": "id", "type": "uint64" },
+		{ "name": "score", "type": "float32" },
+		{ "name": "name", "type": "string" },
+		{ "name": "added", "type": "string" }`)
+
+	changes := s.ChangedFields(old)
+	if assert.Equal(t, 2, len(changes)) {
+		assert.Equal(t, "User", changes[0].Message)
+		assert.Equal(t, "id", changes[0].Field)
+		assert.Equal(t, "uint32", changes[0].Old.String())
+		assert.Equal(t, "uint64", changes[0].New.String())
+		assert.False(t, changes[0].Breaking)
+
+		assert.Equal(t, "score", changes[1].Field)
+		assert.True(t, changes[1].Breaking)
+	}
+
+	assert.Empty(t, s.ChangedFields(s))
+}
+
+func TestEnumDiff(t *testing.T) {
+	parse := func(values string) *Message {
+		s, err := ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "Kind", "type": "enum", "fields": [` + values + `] }]
+		}`))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return s.GetMessageByName("Kind")
+	}
+	value := func(name, enumType, v string) string {
+		return `{ "name": "` + name + `", "type": "` + enumType + `", "value": "` + v + `" }`
+	}
+
+	old := parse(value("USER", "uint32", "0") + "," + value("ADMIN", "uint32", "1"))
+
+	assert.Nil(t, EnumDiff(old, old))
+
+	added := EnumDiff(old, parse(value("USER", "uint32", "0")+","+value("ADMIN", "uint32", "1")+","+value("GUEST", "uint32", "2")))
+	if assert.NotNil(t, added) {
+		assert.Equal(t, []string{"GUEST"}, added.Added)
+		assert.Empty(t, added.Removed)
+		assert.False(t, added.Breaking)
+	}
+
+	removed := EnumDiff(old, parse(value("USER", "uint32", "0")))
+	if assert.NotNil(t, removed) {
+		assert.Equal(t, []string{"ADMIN"}, removed.Removed)
+		assert.True(t, removed.Breaking)
+	}
+
+	renumbered := EnumDiff(old, parse(value("USER", "uint32", "0")+","+value("ADMIN", "uint32", "0x2")))
+	if assert.NotNil(t, renumbered) {
+		assert.Equal(t, []string{"ADMIN"}, renumbered.Renumbered)
+		assert.True(t, renumbered.Breaking)
+	}
+
+	retyped := EnumDiff(old, parse(value("USER", "uint8", "0")+","+value("ADMIN", "uint8", "1")))
+	if assert.NotNil(t, retyped) {
+		assert.Equal(t, T_Uint32, retyped.OldType.Type)
+		assert.Equal(t, T_Uint8, retyped.NewType.Type)
+		assert.True(t, retyped.Breaking)
+	}
+}
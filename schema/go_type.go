@@ -0,0 +1,243 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// goDataTypes maps basic data types to their Go type
+var goDataTypes = map[DataType]string{
+	T_Null: "struct{}",
+	T_Void: "struct{}",
+	T_Any:  "interface{}",
+	T_Byte: "byte",
+	T_Bool: "bool",
+
+	T_Uint:   "uint",
+	T_Uint8:  "uint8",
+	T_Uint16: "uint16",
+	T_Uint32: "uint32",
+	T_Uint64: "uint64",
+
+	T_Int:   "int",
+	T_Int8:  "int8",
+	T_Int16: "int16",
+	T_Int32: "int32",
+	T_Int64: "int64",
+
+	T_Float32: "float32",
+	T_Float64: "float64",
+	T_Ratio:   "float64",
+
+	T_String: "string",
+
+	T_Timestamp: "time.Time",
+
+	// datetime is an RFC 3339 date and time with timezone, while date
+	// ("2006-01-02") and time ("15:04:05") have no timezone and don't
+	// round-trip through time.Time's JSON encoding, so are kept as strings
+	T_Date:     "string",
+	T_Time:     "string",
+	T_DateTime: "time.Time",
+
+	// bigint is encoded as a decimal string on the wire
+	T_BigInt: "*big.Int",
+
+	// rational is encoded as a "num/den" string on the wire, the text form of
+	// big.Rat
+	T_Rational: "*big.Rat",
+
+	// decimal is encoded as a decimal string on the wire, ie. "12.50", and
+	// there's no standard library type keeping its exact value
+	T_Decimal: "string",
+
+	// uuid is kept in its canonical string form, ie.
+	// "123e4567-e89b-12d3-a456-426614174000", so it works as a map key and
+	// needs no third-party package
+	T_UUID: "string",
+
+	// ipaddr and cidr are strings on the wire, ie. "10.0.0.1" and
+	// "10.0.0.0/8". net.IPNet has no text form of its own, so cidr needs a
+	// custom (un)marshaler.
+	T_IPAddr: "net.IP",
+	T_CIDR:   "net.IPNet",
+
+	// generators emit a GeoPoint struct with Lat and Lng float64 fields
+	T_GeoPoint: "GeoPoint",
+
+	// blob is streamed rather than held in memory, unlike []byte
+	T_Blob: "io.ReadCloser",
+}
+
+// goDataTypeImports maps basic data types to the Go packages their type needs
+var goDataTypeImports = map[DataType]string{
+	T_Timestamp: "time",
+	T_DateTime:  "time",
+	T_BigInt:    "math/big",
+	T_Rational:  "math/big",
+	T_Blob:      "io",
+	T_IPAddr:    "net",
+	T_CIDR:      "net",
+}
+
+// RequiredImports returns the sorted standard library imports needed by the
+// type for the given target, ie. "time" for timestamp in Go. Only the "go"
+// target needs imports so far, as `any` maps to interface{} and TypeScript
+// types are all built-in.
+func (t *VarType) RequiredImports(target string) []string {
+	if target != "go" && target != "golang" {
+		return []string{}
+	}
+	_, imports := t.GoTypeInfo()
+	return imports
+}
+
+// GoType returns the Go type expression for the type, ie. map[string][]*User
+// for map<string,[]User>. Structs and optional types are mapped to pointers,
+// while enums are used by value.
+func (t *VarType) GoType() string {
+	typeExpr, _ := t.GoTypeInfo()
+	return typeExpr
+}
+
+// GoTypeInfo returns both the Go type expression of the type and the sorted
+// imports it needs, ie. "*big.Int" and ["math/big"] for bigint, in a single
+// pass so the two always agree.
+func (t *VarType) GoTypeInfo() (typeExpr string, imports []string) {
+	seen := map[string]bool{}
+	typeExpr = t.goType(seen)
+
+	imports = []string{}
+	for pkg := range seen {
+		imports = append(imports, pkg)
+	}
+	sort.Strings(imports)
+
+	return typeExpr, imports
+}
+
+// goType returns the Go type expression for the type, adding the packages it
+// uses to imports
+func (t *VarType) goType(imports map[string]bool) string {
+	if t.Optional || t.Nullable {
+		base := *t
+		base.Optional = false
+		base.Nullable = false
+		goType := base.goType(imports)
+		if goType == "" || isNilableGoType(goType) {
+			return goType
+		}
+		return "*" + goType
+	}
+
+	switch t.Type {
+	case T_List:
+		return "[]" + t.List.Elem.goType(imports)
+	case T_Map:
+		key := goDataTypes[t.Map.Key]
+		if t.Map.Key == T_BigInt || t.Map.Key == T_IPAddr || t.Map.Key == T_CIDR {
+			// pointers, slices and net.IPNet make poor map keys, use the wire
+			// string form instead
+			key = "string"
+		} else if pkg, ok := goDataTypeImports[t.Map.Key]; ok {
+			imports[pkg] = true
+		}
+		return fmt.Sprintf("map[%s]%s", key, t.Map.Value.goType(imports))
+	case T_Result, T_Union:
+		// no native Go equivalent, values are of any of the member types,
+		// whose imports are still needed to (un)marshal them
+		members := []*VarType{}
+		if t.Type == T_Result {
+			members = append(members, t.Result.Ok, t.Result.Err)
+		} else {
+			members = append(members, t.Union.Variants...)
+		}
+		for _, member := range members {
+			member.goType(imports)
+		}
+		return "interface{}"
+	case T_Money:
+		// generators emit a Money struct with Amount and Currency fields, as
+		// there's no standard library equivalent
+		return "Money"
+	case T_Struct:
+		if t.Struct.Message != nil && t.Struct.Message.Type == "enum" {
+			// enums are named integer types, used by value
+			return t.Struct.Name
+		}
+		return "*" + t.Struct.Name
+	default:
+		if pkg, ok := goDataTypeImports[t.Type]; ok {
+			imports[pkg] = true
+		}
+		return goDataTypes[t.Type]
+	}
+}
+
+// isNilableGoType reports whether the Go type expression can already hold
+// nil, ie. pointers, slices, maps and interfaces, so optional types don't
+// need another pointer. It's used for both GoType and RequiredImports,
+// through GoTypeInfo.
+func isNilableGoType(goType string) bool {
+	for _, prefix := range []string{"*", "[]", "map["} {
+		if strings.HasPrefix(goType, prefix) {
+			return true
+		}
+	}
+	switch goType {
+	case "interface{}", "io.ReadCloser", "net.IP":
+		return true
+	}
+	return false
+}
+
+// goTagMetaPrefix is the meta key prefix for Go struct tags, ie. "go.tag.db"
+const goTagMetaPrefix = "go.tag."
+
+// GoTag returns the Go struct tag for the field including its backticks, ie.
+// `json:"user_id,omitempty" db:"user_id"`. The json tag uses the field's
+// wire name and the "json" meta options, adding omitempty for optional
+// fields, and is "-" for fields left off the wire, unless overridden with a
+// "go.tag.json" meta. Other "go.tag.*" metas follow in declaration order.
+func (f *MessageField) GoTag() string {
+	options := append([]string{}, f.JSONOptions...)
+	if f.Optional || (f.Type != nil && f.Type.Optional) {
+		omitEmpty := false
+		for _, option := range options {
+			omitEmpty = omitEmpty || option == "omitempty"
+		}
+		if !omitEmpty {
+			options = append(options, "omitempty")
+		}
+	}
+	jsonTag := strings.Join(append([]string{f.WireName()}, options...), ",")
+	if f.JSONOmitted {
+		jsonTag = "-"
+	}
+
+	tags := []string{}
+	for _, meta := range f.Meta {
+		keys := make([]string, 0, len(meta))
+		for key := range meta {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, goTagMetaPrefix) {
+				continue
+			}
+			name := strings.TrimPrefix(key, goTagMetaPrefix)
+			value := metaValueString(meta[key])
+			if name == "json" {
+				jsonTag = value
+				continue
+			}
+			tags = append(tags, fmt.Sprintf("%s:%q", name, value))
+		}
+	}
+
+	tags = append([]string{fmt.Sprintf("json:%q", jsonTag)}, tags...)
+	return "`" + strings.Join(tags, " ") + "`"
+}
@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeGoType(t *testing.T) {
+	s := newTestSchema("User")
+	s.Messages = append(s.Messages, &Message{Name: "Kind", Type: "enum"})
+
+	tt := []struct {
+		Expr   string
+		GoType string
+	}{
+		{"bigint", "*big.Int"},
+		{"bigint?", "*big.Int"},
+		{"[]bigint", "[]*big.Int"},
+		{"map<string,bigint>", "map[string]*big.Int"},
+		{"map<bigint,[]User>", "map[string][]*User"},
+		{"map<string,timestamp>", "map[string]time.Time"},
+		{"uint32?", "*uint32"},
+		{"User?", "*User"},
+		{"[]string?", "[]*string"},
+		{"optional<[]string>", "[]string"},
+		{"map<string,User>?", "map[string]*User"},
+		{"any?", "interface{}"},
+		{"union<User|string>?", "interface{}"},
+		{"blob?", "io.ReadCloser"},
+		{"ipaddr?", "net.IP"},
+		{"cidr?", "*net.IPNet"},
+		{"timestamp?", "*time.Time"},
+		{"Kind", "Kind"},
+		{"Kind?", "*Kind"},
+		{"[]Kind", "[]Kind"},
+		{"map<string,Kind>", "map[string]Kind"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.Equal(t, tc.GoType, vt.GoType(), tc.Expr)
+	}
+
+	assert.Equal(t, "struct{}", (&VarType{Type: T_Void}).GoType())
+
+	// types without a Go type don't get a pointer to nothing
+	assert.Equal(t, "", (&VarType{Expr: "x", Optional: true}).GoType())
+}
+
+func TestVarTypeRequiredImports(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr    string
+		Target  string
+		Imports []string
+	}{
+		{"map<string,[]timestamp>", "go", []string{"time"}},
+		{"map<string,result<bigint,timestamp>>", "go", []string{"math/big", "time"}},
+		{"result<bigint,[]bigint>", "go", []string{"math/big"}},
+		{"map<bigint,string>", "go", []string{}},
+		{"[]User", "go", []string{}},
+		{"timestamp", "typescript", []string{}},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.Equal(t, tc.Imports, vt.RequiredImports(tc.Target), tc.Expr)
+	}
+}
+
+func TestVarTypeGoTypeInfo(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr    string
+		GoType  string
+		Imports []string
+	}{
+		{"bigint", "*big.Int", []string{"math/big"}},
+		{"timestamp?", "*time.Time", []string{"time"}},
+		{"map<string,map<uint64,[]timestamp>>", "map[string]map[uint64][]time.Time", []string{"time"}},
+		{"map<string,[]rational>", "map[string][]*big.Rat", []string{"math/big"}},
+		{"map<bigint,User>", "map[string]*User", []string{}},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		goType, imports := vt.GoTypeInfo()
+		assert.Equal(t, tc.GoType, goType, tc.Expr)
+		assert.Equal(t, tc.Imports, imports, tc.Expr)
+		assert.Equal(t, vt.GoType(), goType, tc.Expr)
+		assert.Equal(t, vt.RequiredImports("go"), imports, tc.Expr)
+	}
+}
+
+func TestVarTypeUUID(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<uuid,User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_UUID, vt.Map.Key)
+	assert.Equal(t, "map<uuid,User>", vt.Expr)
+	assert.Equal(t, "map[string]*User", vt.GoType())
+	assert.Equal(t, `{"<uuid>": {User}}`, vt.WireShape())
+
+	vt = &VarType{Expr: "[]uuid"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_UUID, vt.List.Elem.Type)
+	assert.Equal(t, "[]string", vt.GoType())
+	assert.Equal(t, `[ "<uuid>" ]`, vt.WireShape())
+
+	// valid wherever a string key is
+	assert.NoError(t, ValidateMapKey(T_UUID))
+}
+
+func TestMessageFieldGoTag(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "userID", "type": "uint64", "meta": [{ "json": "user_id" }] },
+					{ "name": "nickname", "type": "string", "optional": true },
+					{ "name": "email", "type": "string?", "meta": [{ "json": "email_address" }] },
+					{ "name": "username", "type": "string", "meta": [{ "go.tag.db": "username" }] },
+					{ "name": "createdAt", "type": "timestamp", "meta": [{ "go.tag.json": "created_at,omitempty" }, { "go.tag.db": "created_at" }] },
+					{ "name": "updatedAt", "type": "timestamp?", "meta": [{ "json": "updated_at,omitempty" }] },
+					{ "name": "score", "type": "uint32", "meta": [{ "json": "score,string" }] },
+					{ "name": "password", "type": "string", "meta": [{ "json": "-" }] }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, "`json:\"id\"`", fields[0].GoTag())
+	assert.Equal(t, "`json:\"user_id\"`", fields[1].GoTag())
+	assert.Equal(t, "`json:\"nickname,omitempty\"`", fields[2].GoTag())
+	assert.Equal(t, "`json:\"email_address,omitempty\"`", fields[3].GoTag())
+	assert.Equal(t, "`json:\"username\" db:\"username\"`", fields[4].GoTag())
+	assert.Equal(t, "`json:\"created_at,omitempty\" db:\"created_at\"`", fields[5].GoTag())
+	assert.Equal(t, "`json:\"updated_at,omitempty\"`", fields[6].GoTag())
+	assert.Equal(t, "`json:\"score,string\"`", fields[7].GoTag())
+	assert.Equal(t, "`json:\"-\"`", fields[8].GoTag())
+}
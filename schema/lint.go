@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"fmt"
+)
+
+// platformSizedIntegers maps non-portable integer types to their fixed-width
+// alternative, which should be used for values going over the wire.
+var platformSizedIntegers = map[DataType]DataType{
+	T_Int:  T_Int64,
+	T_Uint: T_Uint64,
+}
+
+// LintIntegerUsage reports every use of the platform-sized `int` and `uint`
+// types across messages and service methods. The results are advisory, and
+// suggest a fixed-width alternative for each occurrence.
+func (s *WebRPCSchema) LintIntegerUsage() []string {
+	warnings := []string{}
+
+	check := func(location string, t *VarType) {
+		report := func(dt DataType) {
+			if alt, ok := platformSizedIntegers[dt]; ok {
+				warnings = append(warnings, fmt.Sprintf("%s uses platform-sized type '%s' in '%s', use '%s' instead", location, dt, t.Expr, alt))
+			}
+		}
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type == T_Map && vt.Map != nil {
+				report(vt.Map.Key)
+			}
+			report(vt.Type)
+			return true
+		})
+	}
+
+	s.lintTypes(check)
+
+	return warnings
+}
+
+// LintOptionalContainers reports optional lists and maps, ie. map<K,V>? or
+// optional<[]T>, which are redundant as containers are already nullable in
+// most targets. Optional elements, ie. []T?, are fine. The results are
+// advisory, see ParseOptions.RejectOptionalContainers to make them errors.
+func (s *WebRPCSchema) LintOptionalContainers() []string {
+	warnings := []string{}
+
+	s.lintTypes(func(location string, t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			if vt.Optional && (vt.Type == T_List || vt.Type == T_Map) {
+				warnings = append(warnings, fmt.Sprintf("%s has redundant optional %s '%s' in '%s'", location, vt.Type.containerName(), buildVarTypeExpr(vt, "", s.parseOptions()), t.Expr))
+			}
+			return true
+		})
+	})
+
+	return warnings
+}
+
+// LintSimplifiableTypes reports container types that could be expressed
+// more simply, ie. maps of maps, which are usually meant as a struct, and
+// lists of single-field structs, which could be lists of the field type. The
+// results are advisory, and include the suggested simplification when there
+// is one.
+func (s *WebRPCSchema) LintSimplifiableTypes() []string {
+	suggestions := []string{}
+
+	s.lintTypes(func(location string, t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			expr := buildVarTypeExpr(vt, "", s.parseOptions())
+			switch {
+			case vt.Type == T_Map && vt.Map != nil && vt.Map.Value.Type == T_Map:
+				suggestions = append(suggestions, fmt.Sprintf("%s has nested map '%s' in '%s', consider a struct for the inner map", location, expr, t.Expr))
+			case vt.Type == T_List && vt.List != nil && vt.List.Elem.Type == T_Struct:
+				msg := vt.List.Elem.Struct.Message
+				if msg == nil || msg.Type != "struct" || len(msg.Fields) != 1 || msg.Fields[0].Type == nil {
+					break
+				}
+				simplified := &VarType{Type: T_List, Optional: vt.Optional, List: &VarListType{Elem: msg.Fields[0].Type}}
+				suggestions = append(suggestions, fmt.Sprintf("%s has list of single-field struct '%s' in '%s', consider '%s' instead", location, expr, t.Expr, buildVarTypeExpr(simplified, "", s.parseOptions())))
+			}
+			return true
+		})
+	})
+
+	return suggestions
+}
+
+// EmptyMessages returns the names of struct messages without fields, in
+// declaration order. An empty message may be intentional, ie. a marker or a
+// placeholder request, so the results are advisory and meant for review.
+func (s *WebRPCSchema) EmptyMessages() []string {
+	names := []string{}
+	for _, msg := range s.Messages {
+		if msg.Type == "struct" && len(msg.Fields) == 0 {
+			names = append(names, string(msg.Name))
+		}
+	}
+	return names
+}
+
+// lintTypes calls fn for each message field and method argument type. Enums
+// are reported once through their enum type.
+func (s *WebRPCSchema) lintTypes(fn func(location string, t *VarType)) {
+	for _, msg := range s.Messages {
+		if msg.Type == "enum" {
+			if msg.EnumType != nil {
+				fn(fmt.Sprintf("enum '%s'", msg.Name), msg.EnumType)
+			}
+			continue
+		}
+		for _, field := range msg.Fields {
+			if field.Type != nil {
+				fn(fmt.Sprintf("message '%s' field '%s'", msg.Name, field.Name), field.Type)
+			}
+		}
+	}
+
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				if input.Type != nil {
+					fn(fmt.Sprintf("service '%s' method '%s' input '%s'", svc.Name, method.Name, input.Name), input.Type)
+				}
+			}
+			for _, output := range method.Outputs {
+				if output.Type != nil {
+					fn(fmt.Sprintf("service '%s' method '%s' output '%s'", svc.Name, method.Name, output.Name), output.Type)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintIntegerUsage(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "lint",
+		"version": "v0.1.0",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "age", "type": "int" },
+					{ "name": "scores", "type": "map<uint,[]int32>" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "ListUsers",
+						"inputs": [{ "name": "page", "type": "uint32" }],
+						"outputs": [{ "name": "ages", "type": "[]int" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"message 'User' field 'age' uses platform-sized type 'int' in 'int', use 'int64' instead",
+		"message 'User' field 'scores' uses platform-sized type 'uint' in 'map<uint,[]int32>', use 'uint64' instead",
+		"service 'UserService' method 'ListUsers' output 'ages' uses platform-sized type 'int' in '[]int', use 'int64' instead",
+	}, s.LintIntegerUsage())
+}
+
+func TestLintOptionalContainers(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "lint",
+		"version": "v0.1.0",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "tags", "type": "map<string,string>?" },
+					{ "name": "friends", "type": "optional<[]string>" },
+					{ "name": "nicknames", "type": "[]string?" },
+					{ "name": "scores", "type": "map<string,uint32?>" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"message 'User' field 'tags' has redundant optional map 'map<string,string>?' in 'map<string,string>?'",
+		"message 'User' field 'friends' has redundant optional list 'optional<[]string>' in 'optional<[]string>'",
+	}, s.LintOptionalContainers())
+
+	// container-level optionals are rejected when configured, elements are fine
+	s = newTestSchema()
+	s.ParseOptions.RejectOptionalContainers = true
+	for _, expr := range []string{"map<string,string>?", "optional<[]string>", "[]map<string,int>?", "optional<map<string,int>>"} {
+		vt := &VarType{Expr: expr}
+		err := vt.Parse(s)
+		if assert.Error(t, err, expr) {
+			assert.Equal(t, ErrRedundantOptional, err.(*ParseError).Code)
+		}
+	}
+	for _, expr := range []string{"[]string?", "map<string,uint32?>", "string?"} {
+		vt := &VarType{Expr: expr}
+		assert.NoError(t, vt.Parse(s), expr)
+	}
+}
+
+func TestLintSimplifiableTypes(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "lint",
+		"version": "v0.1.0",
+		"messages": [
+			{
+				"name": "Tag",
+				"type": "struct",
+				"fields": [{ "name": "name", "type": "string" }]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "settings", "type": "map<string,map<string,string>>" },
+					{ "name": "tags", "type": "[]Tag" },
+					{ "name": "friends", "type": "[]User" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"message 'User' field 'settings' has nested map 'map<string,map<string,string>>' in 'map<string,map<string,string>>', consider a struct for the inner map",
+		"message 'User' field 'tags' has list of single-field struct '[]Tag' in '[]Tag', consider '[]string' instead",
+	}, s.LintSimplifiableTypes())
+}
+
+func TestEmptyMessages(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "lint",
+		"version": "v0.1.0",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{ "name": "PingRequest", "type": "struct", "fields": [] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "ID", "type": "uint64" }] }
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"PingRequest"}, s.EmptyMessages())
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// intDataTypeBits is the bit width of each integer data type, where the
+// platform-sized int and uint are treated as 64 bits. byte is only its own
+// data type with the PreserveByte parse option, and is a uint8.
+var intDataTypeBits = map[DataType]int{
+	T_Uint: 64, T_Uint8: 8, T_Uint16: 16, T_Uint32: 32, T_Uint64: 64,
+	T_Int: 64, T_Int8: 8, T_Int16: 16, T_Int32: 32, T_Int64: 64,
+	T_Byte: 8,
+}
+
+func isIntegerType(dt DataType) bool {
+	_, ok := intDataTypeBits[dt]
+	return ok
+}
+
+func isUnsignedType(dt DataType) bool {
+	return dt == T_Byte || strings.HasPrefix(dt.String(), "uint")
+}
+
+// parseIntLiteral parses an integer literal in decimal, hex (0x1F), binary
+// (0b1010) or octal (0o17) form, and returns it normalized to decimal. The
+// literal must fit the width and signedness of dt.
+func parseIntLiteral(lit string, dt DataType) (string, error) {
+	bits, ok := intDataTypeBits[dt]
+	if !ok {
+		return "", fmt.Errorf("'%s' is not an integer type", dt)
+	}
+
+	digits := strings.TrimPrefix(lit, "-")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	if digits == "" || strings.ContainsAny(digits, "+-_") {
+		return "", fmt.Errorf("invalid integer literal '%s'", lit)
+	}
+	negative := strings.HasPrefix(lit, "-")
+
+	if isUnsignedType(dt) {
+		if negative {
+			return "", fmt.Errorf("integer literal '%s' is negative, but %s is unsigned", lit, dt)
+		}
+		n, err := strconv.ParseUint(digits, base, bits)
+		if err != nil {
+			return "", intLiteralError(lit, dt, err)
+		}
+		return strconv.FormatUint(n, 10), nil
+	}
+
+	if negative {
+		digits = "-" + digits
+	}
+	n, err := strconv.ParseInt(digits, base, bits)
+	if err != nil {
+		return "", intLiteralError(lit, dt, err)
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+func intLiteralError(lit string, dt DataType, err error) error {
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return fmt.Errorf("integer literal '%s' is out of range for %s", lit, dt)
+	}
+	return fmt.Errorf("invalid integer literal '%s'", lit)
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIntLiteral(t *testing.T) {
+	tt := []struct {
+		Lit   string
+		Type  DataType
+		Value string
+	}{
+		{"42", T_Uint32, "42"},
+		{"-42", T_Int32, "-42"},
+		{"0x1F", T_Uint8, "31"},
+		{"0XfF", T_Uint8, "255"},
+		{"0b1010", T_Int, "10"},
+		{"0o17", T_Uint16, "15"},
+		{"-0x80", T_Int8, "-128"},
+		{"0", T_Uint64, "0"},
+		{"0xFF", T_Byte, "255"},
+	}
+	for _, tc := range tt {
+		value, err := parseIntLiteral(tc.Lit, tc.Type)
+		assert.NoError(t, err, tc.Lit)
+		assert.Equal(t, tc.Value, value, tc.Lit)
+	}
+
+	errs := []struct {
+		Lit   string
+		Type  DataType
+		Error string
+	}{
+		{"0x100", T_Uint8, "integer literal '0x100' is out of range for uint8"},
+		{"0b100000000", T_Uint8, "integer literal '0b100000000' is out of range for uint8"},
+		{"0x80", T_Int8, "integer literal '0x80' is out of range for int8"},
+		{"-1", T_Uint32, "integer literal '-1' is negative, but uint32 is unsigned"},
+		{"-0x1", T_Uint8, "integer literal '-0x1' is negative, but uint8 is unsigned"},
+		{"256", T_Byte, "integer literal '256' is out of range for byte"},
+		{"-1", T_Byte, "integer literal '-1' is negative, but byte is unsigned"},
+		{"0x", T_Uint32, "invalid integer literal '0x'"},
+		{"0b102", T_Uint32, "invalid integer literal '0b102'"},
+		{"1_000", T_Uint32, "invalid integer literal '1_000'"},
+		{"", T_Uint32, "invalid integer literal ''"},
+		{"1", T_String, "'string' is not an integer type"},
+	}
+	for _, tc := range errs {
+		_, err := parseIntLiteral(tc.Lit, tc.Type)
+		if assert.Error(t, err, tc.Lit) {
+			assert.Equal(t, tc.Error, err.Error())
+		}
+	}
+}
+
+func TestEnumIntLiterals(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Flags",
+				"type": "enum",
+				"fields": [
+					{ "name": "READ", "type": "uint8", "value": "0b0001" },
+					{ "name": "WRITE", "type": "uint8", "value": "0x02" },
+					{ "name": "EXEC", "type": "uint8", "value": "0o4" },
+					{ "name": "ALL", "type": "uint8", "value": "7" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	values := []string{}
+	for _, field := range s.GetMessageByName("Flags").Fields {
+		values = append(values, field.Value)
+	}
+	assert.Equal(t, []string{"1", "2", "4", "7"}, values)
+
+	_, err = ParseSchemaJSON([]byte(`{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Flags", "type": "enum", "fields": [{ "name": "BIG", "type": "uint8", "value": "0x1FF" }] }
+		]
+	}`))
+	if assert.Error(t, err) {
+		assert.Equal(t, "schema error: enum message 'Flags' field 'BIG' has invalid value: integer literal '0x1FF' is out of range for uint8", err.Error())
+	}
+}
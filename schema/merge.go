@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Merge returns a new schema with the aliases, constants, messages and
+// services of both a and b, with the name and version of a. Definitions
+// declared in both with an identical shape are included once, and differing
+// ones are a conflict. Services of the same name are merged when their
+// methods are disjoint. Both schemas must be parsed, and the merged schema
+// shares its definitions with them.
+func Merge(a, b *WebRPCSchema) (*WebRPCSchema, error) {
+	merged := &WebRPCSchema{
+		WebrpcVersion: a.WebrpcVersion,
+		SchemaName:    a.SchemaName,
+		SchemaVersion: a.SchemaVersion,
+		Imports:       append(append([]*Import{}, a.Imports...), b.Imports...),
+		Constants:     append([]*Constant{}, a.Constants...),
+		Aliases:       append([]*Alias{}, a.Aliases...),
+		Messages:      append([]*Message{}, a.Messages...),
+		Services:      []*Service{},
+		ParseOptions:  a.ParseOptions,
+	}
+
+	for _, constant := range b.Constants {
+		existing, ok := getConstant(a, string(constant.Name))
+		if !ok {
+			merged.Constants = append(merged.Constants, constant)
+			continue
+		}
+		if existing.Value != constant.Value {
+			return nil, fmt.Errorf("merge error: conflicting definitions of constant '%s'", constant.Name)
+		}
+	}
+
+	for _, alias := range b.Aliases {
+		existing, ok := getAliasType(a, string(alias.Name))
+		if !ok {
+			merged.Aliases = append(merged.Aliases, alias)
+			continue
+		}
+		if !existing.Type.Equal(alias.Type) {
+			return nil, fmt.Errorf("merge error: conflicting definitions of alias '%s'", alias.Name)
+		}
+	}
+
+	for _, msg := range b.Messages {
+		existing := a.GetMessageByName(string(msg.Name))
+		if existing == nil {
+			merged.Messages = append(merged.Messages, msg)
+			continue
+		}
+		if !messagesEqual(existing, msg) {
+			return nil, fmt.Errorf("merge error: conflicting definitions of %s '%s'", msg.Type, msg.Name)
+		}
+	}
+
+	for _, svc := range a.Services {
+		merged.Services = append(merged.Services, &Service{
+			Name:    svc.Name,
+			Methods: append([]*Method{}, svc.Methods...),
+			Schema:  merged,
+		})
+	}
+	for _, svc := range b.Services {
+		existing := merged.GetServiceByName(string(svc.Name))
+		if existing == nil {
+			merged.Services = append(merged.Services, &Service{
+				Name:    svc.Name,
+				Methods: append([]*Method{}, svc.Methods...),
+				Schema:  merged,
+			})
+			continue
+		}
+		for _, method := range svc.Methods {
+			for _, m := range existing.Methods {
+				if m.Name == method.Name {
+					return nil, fmt.Errorf("merge error: method '%s' is declared in both definitions of service '%s'", method.Name, svc.Name)
+				}
+			}
+			existing.Methods = append(existing.Methods, method)
+		}
+	}
+
+	return merged, nil
+}
+
+// messagesEqual reports whether two messages have the same shape
+func messagesEqual(a, b *Message) bool {
+	if a.Type != b.Type || len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i, field := range a.Fields {
+		other := b.Fields[i]
+		if field.Name != other.Name || field.Optional != other.Optional || field.Value != other.Value {
+			return false
+		}
+		if !field.Type.Equal(other.Type) || !reflect.DeepEqual(field.Meta, other.Meta) {
+			return false
+		}
+	}
+	return true
+}
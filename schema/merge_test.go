@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	parse := func(input string) *WebRPCSchema {
+		s, err := ParseSchemaJSON([]byte(input))
+		assert.NoError(t, err)
+		return s
+	}
+
+	a := parse(`{
+		"webrpc": "v1",
+		"name": "users",
+		"version": "v1.0.0",
+		"messages": [
+			{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] }
+		],
+		"services": [
+			{ "name": "API", "methods": [{ "name": "GetUser", "inputs": [], "outputs": [{ "name": "user", "type": "User" }] }] }
+		]
+	}`)
+	b := parse(`{
+		"webrpc": "v1",
+		"name": "orders",
+		"version": "v2.0.0",
+		"messages": [
+			{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] },
+			{ "name": "Order", "type": "struct", "fields": [{ "name": "user", "type": "User" }] }
+		],
+		"services": [
+			{ "name": "API", "methods": [{ "name": "GetOrder", "inputs": [], "outputs": [{ "name": "order", "type": "Order" }] }] },
+			{ "name": "Admin", "methods": [{ "name": "Ping", "inputs": [], "outputs": [] }] }
+		]
+	}`)
+
+	merged, err := Merge(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, "users", merged.SchemaName)
+	assert.Equal(t, "v1.0.0", merged.SchemaVersion)
+
+	names := []string{}
+	for _, msg := range merged.Messages {
+		names = append(names, string(msg.Name))
+	}
+	assert.Equal(t, []string{"User", "Order"}, names)
+
+	assert.Equal(t, 2, len(merged.Services))
+	api := merged.GetServiceByName("API")
+	assert.Equal(t, 2, len(api.Methods))
+	assert.Equal(t, "GetUser", string(api.Methods[0].Name))
+	assert.Equal(t, "GetOrder", string(api.Methods[1].Name))
+
+	// inputs are untouched
+	assert.Equal(t, 1, len(a.Services[0].Methods))
+	assert.Equal(t, 1, len(a.Messages))
+}
+
+func TestMergeConflicts(t *testing.T) {
+	parse := func(messages, methods string) *WebRPCSchema {
+		s, err := ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [` + messages + `],
+			"services": [{ "name": "API", "methods": [` + methods + `] }]
+		}`))
+		assert.NoError(t, err)
+		return s
+	}
+
+	a := parse(`{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] }`, `{ "name": "Ping", "inputs": [], "outputs": [] }`)
+
+	b := parse(`{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "string" }] }`, `{ "name": "Other", "inputs": [], "outputs": [] }`)
+	_, err := Merge(a, b)
+	assert.EqualError(t, err, "merge error: conflicting definitions of struct 'User'")
+
+	b = parse(``, `{ "name": "Ping", "inputs": [], "outputs": [] }`)
+	_, err = Merge(a, b)
+	assert.EqualError(t, err, "merge error: method 'Ping' is declared in both definitions of service 'API'")
+}
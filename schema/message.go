@@ -1,15 +1,27 @@
 package schema
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 type Message struct {
-	Name   VarName         `json:"name"`
-	Type   MessageType     `json:"type"`
+	Name VarName     `json:"name"`
+	Type MessageType `json:"type"`
+
+	// Fields are kept in declaration order, which is the order of generated
+	// struct fields and of the object keys on the wire. Parsing, marshaling,
+	// Merge, Subset and CanonicalJSON all preserve it.
 	Fields []*MessageField `json:"fields"`
 
+	Description string `json:"description,omitempty"`
+
 	// EnumType determined for enum types during parsing time
 	EnumType *VarType `json:"-"`
 }
@@ -25,10 +37,372 @@ type MessageField struct {
 
 	// Meta store extra metadata on a field for plugins
 	Meta []MessageFieldMeta `json:"meta"`
+
+	// Description, Deprecated and Examples document the field. They may also
+	// be given in a type block, see UnmarshalJSON. Examples must be valid
+	// values of the field type.
+	Description string        `json:"description,omitempty"`
+	Deprecated  bool          `json:"deprecated,omitempty"`
+	Examples    []interface{} `json:"examples,omitempty"`
+
+	// JSONName overrides the wire name of the field, set from the "json" meta,
+	// ie. "created_at" for `json = created_at,omitempty`. The options after
+	// the name are kept in JSONOptions, ie. ["omitempty"].
+	JSONName    string   `json:"-"`
+	JSONOptions []string `json:"-"`
+
+	// JSONOmitted marks a field left off the wire, set by a "json" meta of
+	// "-", as in Go struct tags
+	JSONOmitted bool `json:"-"`
+
+	// Format constrains the contents of a string field, set from the "format"
+	// meta, ie. "email". See StringFormats for the known formats.
+	Format string `json:"-"`
+
+	// Pattern constrains a string field to match a Go regexp, set from the
+	// "pattern" meta, ie. "^[a-z]+$". It's compiled at parse time.
+	Pattern string `json:"-"`
+
+	// Default is the value of the field when omitted, set from the "default"
+	// meta. Integer defaults may reference a schema constant, and are
+	// normalized to decimal.
+	Default string `json:"-"`
+
+	// Required marks the field as explicitly required, set from the
+	// "required" meta, for generators that treat fields as optional unless
+	// told otherwise. A required field cannot be optional.
+	Required bool `json:"-"`
+
+	// RequiredIf names another field of the message, set from the
+	// "requiredIf" meta, for validators to require this field whenever the
+	// other one is present. It's metadata only, the type is left as is.
+	RequiredIf string `json:"-"`
+
+	// MinLength and MaxLength bound the length of a string or list field, set
+	// from the "minLength" and "maxLength" metas, ie. 1 and 64. String
+	// lengths count characters, not bytes. Nil when not set.
+	MinLength *int `json:"-"`
+	MaxLength *int `json:"-"`
+}
+
+// fieldTypeBlock is the object form of a field type, carrying the field
+// metadata along with the type expr, ie.
+// { "expr": "uint32", "default": 10, "examples": [10, 25] }
+type fieldTypeBlock struct {
+	Expr        string        `json:"expr"`
+	Description string        `json:"description"`
+	Deprecated  bool          `json:"deprecated"`
+	Default     interface{}   `json:"default"`
+	Examples    []interface{} `json:"examples"`
+}
+
+// UnmarshalJSON decodes a field whose type is either a type expr string, or
+// a type block object, in which only the expr is required. A block default
+// is stored as a "default" meta, so it's handled like one. Numbers in metas
+// and defaults are kept as json.Number literals, so large integer defaults
+// don't lose precision to float64.
+func (f *MessageField) UnmarshalJSON(b []byte) error {
+	type messageField MessageField // without methods, to not recurse
+	var raw struct {
+		messageField
+		Type json.RawMessage `json:"type"`
+	}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	*f = MessageField(raw.messageField)
+
+	var metas struct {
+		Meta []MessageFieldMeta `json:"meta"`
+	}
+	if err := unmarshalJSONNumbers(b, &metas); err != nil {
+		return err
+	}
+	f.Meta = metas.Meta
+
+	typ := strings.TrimSpace(string(raw.Type))
+	switch {
+	case typ == "" || typ == "null":
+		return nil
+	case typ[0] == '{':
+		var block fieldTypeBlock
+		err := json.Unmarshal(raw.Type, &block)
+		if err != nil {
+			return err
+		}
+		if block.Expr == "" {
+			return fmt.Errorf("json error: type block of field '%s' is missing its expr", f.Name)
+		}
+		f.Type = &VarType{Expr: block.Expr}
+		if block.Description != "" {
+			f.Description = block.Description
+		}
+		f.Deprecated = f.Deprecated || block.Deprecated
+		f.Examples = append(f.Examples, block.Examples...)
+		var blockDefault struct {
+			Default interface{} `json:"default"`
+		}
+		if err := unmarshalJSONNumbers(raw.Type, &blockDefault); err != nil {
+			return err
+		}
+		if blockDefault.Default != nil {
+			f.Meta = append(f.Meta, MessageFieldMeta{"default": blockDefault.Default})
+		}
+		return nil
+	default:
+		f.Type = &VarType{}
+		return f.Type.UnmarshalJSON(raw.Type)
+	}
+}
+
+// IsRequired reports whether the field must be present, which is when it's
+// explicitly marked required, or simply isn't optional.
+func (f *MessageField) IsRequired() bool {
+	if f.Required {
+		return true
+	}
+	return !f.Optional && (f.Type == nil || !f.Type.Optional)
+}
+
+// StringFormats are the known formats of a string field
+var StringFormats = []string{
+	"date",
+	"date-time",
+	"email",
+	"hostname",
+	"ipv4",
+	"ipv6",
+	"uri",
+	"uuid",
+}
+
+func isValidStringFormat(format string) bool {
+	for _, f := range StringFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
 }
 
 type MessageFieldMeta map[string]interface{}
 
+// unmarshalJSONNumbers decodes b like json.Unmarshal, but into json.Number
+// rather than float64 for numbers in interface{} values
+func unmarshalJSONNumbers(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// metaValueString formats a meta value, spelling numbers out in full, ie.
+// 10000000 rather than 1e+07
+func metaValueString(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// WireName returns the name of the field as it appears on the wire, which is
+// the field name unless overridden by the "json" meta.
+func (f *MessageField) WireName() string {
+	if f.JSONName != "" {
+		return f.JSONName
+	}
+	return string(f.Name)
+}
+
+// parseDefault resolves constant references in the field default, and checks
+// integer defaults fit the field type
+func (f *MessageField) parseDefault(schema *WebRPCSchema, msgName string) error {
+	if f.Default == "" {
+		return nil
+	}
+
+	constant, isConstant := getConstant(schema, f.Default)
+	if _, isInteger := intDataTypeBits[f.Type.Type]; !isInteger {
+		if isConstant {
+			return fmt.Errorf("schema error: default for field '%s' in message '%s' references integer constant '%s', but the field is of type '%s'", f.Name, msgName, constant.Name, f.Type)
+		}
+		return nil
+	}
+
+	value := f.Default
+	if isConstant {
+		value = constant.Value
+	}
+	value, err := parseIntLiteral(value, f.Type.Type)
+	if err != nil {
+		return fmt.Errorf("schema error: invalid default for field '%s' in message '%s': %v", f.Name, msgName, err)
+	}
+	f.Default = value
+	return nil
+}
+
+// parseInlineEnum materializes an inline enum field type, ie.
+// enum<active|inactive>, into a schema enum named after the message and
+// field, ie. UserStatus for field 'status' of message 'User'. The enum values
+// are numbered from 0 in order, and the field type is replaced by a
+// reference to the enum.
+func (f *MessageField) parseInlineEnum(schema *WebRPCSchema, msgName string) error {
+	if f.Type == nil {
+		return nil
+	}
+	values, optional, err := parseInlineEnumExpr(f.Type.Expr, schema.parseOptions())
+	if err != nil || values == nil {
+		return err
+	}
+
+	fieldName := string(f.Name)
+	enum := &Message{
+		Name: VarName(msgName + strings.ToUpper(fieldName[:1]) + fieldName[1:]),
+		Type: "enum",
+	}
+	if kind := typeNameKind(schema, string(enum.Name)); kind != "" {
+		return fmt.Errorf("schema error: inline enum '%s' of field '%s' in message '%s' is named '%s', which is already defined as %s", f.Type.Expr, fieldName, msgName, enum.Name, kind)
+	}
+	for i, value := range values {
+		enum.Fields = append(enum.Fields, &MessageField{
+			Name:  VarName(value),
+			Type:  &VarType{Expr: T_Uint32.String()},
+			Value: strconv.Itoa(i),
+		})
+	}
+	err = enum.Parse(schema)
+	if err != nil {
+		return err
+	}
+	schema.Messages = append(schema.Messages, enum)
+
+	f.Type.Expr = string(enum.Name)
+	if optional {
+		f.Type.Expr += "?"
+	}
+	return nil
+}
+
+// typeNameKind returns the kind of schema type already defined under the name,
+// compared case-insensitively, ie. "an alias", or "" when it's free
+func typeNameKind(schema *WebRPCSchema, name string) string {
+	name = strings.ToLower(name)
+	for _, msg := range schema.Messages {
+		if strings.ToLower(string(msg.Name)) == name {
+			if msg.Type == "enum" {
+				return "an enum"
+			}
+			return "a message"
+		}
+	}
+	for _, alias := range schema.Aliases {
+		if strings.ToLower(string(alias.Name)) == name {
+			return "an alias"
+		}
+	}
+	for _, constant := range schema.Constants {
+		if strings.ToLower(string(constant.Name)) == name {
+			return "a constant"
+		}
+	}
+	return ""
+}
+
+// parseLengthMeta returns the non-negative integer of a minLength or
+// maxLength meta, given as a JSON number or a string
+func parseLengthMeta(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return parseLengthMeta(f)
+	case float64:
+		if v < 0 || v != math.Trunc(v) || v > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || !isDecimalDigits(v) {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func (f *MessageField) parseMeta(msgName string) error {
+	for _, meta := range f.Meta {
+		for key, value := range meta {
+			switch key {
+			case "json":
+				tag, ok := value.(string)
+				if !ok {
+					return fmt.Errorf("schema error: invalid json name '%v' for field '%s' in message '%s'", value, f.Name, msgName)
+				}
+				if tag == "-" {
+					f.JSONOmitted = true
+					continue
+				}
+				parts := strings.Split(tag, ",")
+				if !IsValidArgName(parts[0]) {
+					return fmt.Errorf("schema error: invalid json name '%v' for field '%s' in message '%s'", parts[0], f.Name, msgName)
+				}
+				f.JSONName = parts[0]
+				f.JSONOptions = parts[1:]
+			case "format":
+				format, ok := value.(string)
+				if !ok || !isValidStringFormat(format) {
+					return fmt.Errorf("schema error: unknown format '%v' for field '%s' in message '%s', must be one of %s", value, f.Name, msgName, strings.Join(StringFormats, ", "))
+				}
+				f.Format = format
+			case "pattern":
+				pattern, ok := value.(string)
+				if !ok || pattern == "" {
+					return fmt.Errorf("schema error: invalid pattern '%v' for field '%s' in message '%s'", value, f.Name, msgName)
+				}
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("schema error: invalid pattern '%s' for field '%s' in message '%s': %v", pattern, f.Name, msgName, err)
+				}
+				f.Pattern = pattern
+			case "minLength", "maxLength":
+				n, ok := parseLengthMeta(value)
+				if !ok {
+					return fmt.Errorf("schema error: invalid %s '%v' for field '%s' in message '%s', must be a non-negative integer", key, value, f.Name, msgName)
+				}
+				if key == "minLength" {
+					f.MinLength = &n
+				} else {
+					f.MaxLength = &n
+				}
+			case "requiredIf":
+				name, ok := value.(string)
+				if !ok || !IsValidArgName(name) {
+					return fmt.Errorf("schema error: invalid requiredIf field '%v' for field '%s' in message '%s'", value, f.Name, msgName)
+				}
+				f.RequiredIf = name
+			case "default":
+				f.Default = metaValueString(value)
+			case "required":
+				switch value {
+				case true, "true":
+					f.Required = true
+				case false, "false":
+					f.Required = false
+				default:
+					return fmt.Errorf("schema error: invalid required value '%v' for field '%s' in message '%s', must be true or false", value, f.Name, msgName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (m *Message) Parse(schema *WebRPCSchema) error {
 	// Message name
 	msgName := string(m.Name)
@@ -75,26 +449,69 @@ func (m *Message) Parse(schema *WebRPCSchema) error {
 			return fmt.Errorf("schema error: detected duplicate field name of '%s' in message '%s'", fieldName, msgName)
 		}
 		fieldList[nFieldName] = fieldName
+
+		err := field.parseMeta(msgName)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Parse+validate message fields
 	for _, field := range m.Fields {
-		err := field.Type.Parse(schema)
+		err := field.parseInlineEnum(schema, msgName)
+		if err != nil {
+			return err
+		}
+		err = field.Type.Parse(schema)
+		if err != nil {
+			return err
+		}
+		if field.Type.Type == T_Void {
+			return fmt.Errorf("schema error: field '%s' in message '%s' cannot be void, void is only valid for method inputs or outputs", field.Name, msgName)
+		}
+		if field.Required && (field.Optional || field.Type.Optional) {
+			return fmt.Errorf("schema error: field '%s' in message '%s' cannot be both required and optional", field.Name, msgName)
+		}
+		err = field.parseDefault(schema, msgName)
 		if err != nil {
 			return err
 		}
+		if field.Format != "" && field.Type.Type != T_String {
+			return fmt.Errorf("schema error: format '%s' for field '%s' in message '%s' is only valid on a string field", field.Format, field.Name, msgName)
+		}
+		if field.Pattern != "" && field.Type.Type != T_String {
+			return fmt.Errorf("schema error: pattern '%s' for field '%s' in message '%s' is only valid on a string field", field.Pattern, field.Name, msgName)
+		}
+		if field.MinLength != nil || field.MaxLength != nil {
+			if field.Type.Type != T_String && field.Type.Type != T_List {
+				return fmt.Errorf("schema error: minLength and maxLength for field '%s' in message '%s' are only valid on a string or list field", field.Name, msgName)
+			}
+			if field.MinLength != nil && field.MaxLength != nil && *field.MinLength > *field.MaxLength {
+				return fmt.Errorf("schema error: minLength %d is greater than maxLength %d for field '%s' in message '%s'", *field.MinLength, *field.MaxLength, field.Name, msgName)
+			}
+		}
+		if field.RequiredIf != "" {
+			if other := m.getField(VarName(field.RequiredIf)); other == nil || other == field {
+				return fmt.Errorf("schema error: requiredIf of field '%s' in message '%s' references unknown field '%s'", field.Name, msgName, field.RequiredIf)
+			}
+			if field.Required {
+				return fmt.Errorf("schema error: field '%s' in message '%s' cannot be both required and requiredIf", field.Name, msgName)
+			}
+		}
+		for i, example := range field.Examples {
+			if err := field.ValidateValue(example); err != nil {
+				return fmt.Errorf("schema error: example %d for field '%s' in message '%s' is invalid: %v", i+1, field.Name, msgName, err)
+			}
+		}
 	}
 
 	// For enums only, ensure all field types are the same
 	if m.Type == "enum" {
-		// ensure enum fields have value key set, and are all of the same type
+		// ensure enum fields are all of the same type
 		fieldTypes := map[string]struct{}{}
 		for _, field := range m.Fields {
 			fieldType := field.Type.Expr
 			fieldTypes[fieldType] = struct{}{}
-			if field.Value == "" {
-				return fmt.Errorf("schema error: enum message '%s' with field '%s' is missing value", m.Name, field.Name)
-			}
 		}
 		if len(fieldTypes) > 1 {
 			return fmt.Errorf("schema error: enum message '%s' must all have the same field type", m.Name)
@@ -106,6 +523,29 @@ func (m *Message) Parse(schema *WebRPCSchema) error {
 			return fmt.Errorf("schema error: enum message '%s' field '%s' is invalid. must be an integer type.", m.Name, fieldType.String())
 		}
 		m.EnumType = fieldType
+
+		// normalize enum values to decimal, ie. 0x1F is stored as 31. Fields
+		// without a value follow the previous one, starting from 0, so
+		// RED = 1, GREEN, BLUE = 10 numbers GREEN as 2.
+		valueFields := map[string]VarName{}
+		next := big.NewInt(0)
+		for _, field := range m.Fields {
+			lit := field.Value
+			if lit == "" {
+				lit = next.String()
+			}
+			value, err := parseIntLiteral(lit, fieldType.Type)
+			if err != nil {
+				return fmt.Errorf("schema error: enum message '%s' field '%s' has invalid value: %v", m.Name, field.Name, err)
+			}
+			if other, ok := valueFields[value]; ok {
+				return fmt.Errorf("schema error: enum message '%s' fields '%s' and '%s' have the same value %s", m.Name, other, field.Name, value)
+			}
+			valueFields[value] = field.Name
+			field.Value = value
+			next.SetString(value, 10)
+			next.Add(next, big.NewInt(1))
+		}
 	}
 
 	// For structs only
@@ -120,3 +560,19 @@ func (m *Message) Parse(schema *WebRPCSchema) error {
 
 	return nil
 }
+
+// NeedsCustomJSON reports whether any field of a struct message has a type
+// that needs a custom JSON (un)marshaler, see VarType.NeedsCustomJSON.
+// Fields of nested messages are not considered, as each message gets its own
+// (un)marshaler.
+func (m *Message) NeedsCustomJSON() bool {
+	if m.Type != "struct" {
+		return false
+	}
+	for _, field := range m.Fields {
+		if field.Type.NeedsCustomJSON() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// openAPIRefPrefix is the JSON pointer to the schemas of an OpenAPI document
+const openAPIRefPrefix = "#/components/schemas/"
+
+// ToOpenAPIComponents returns the OpenAPI 3.0 `components/schemas` entries of
+// the schema messages, keyed by message name. Structs become objects listing
+// their required fields, enums become integers restricted to their values.
+// OpenAPI objects only have string keys, so maps with non-string keys are
+// emitted as objects of string keys, with the original key type in the
+// "x-webrpc-key-type" extension, or as arrays of [key, value] pairs with the
+// MapKeysAsPairs wire strategy. The schema must be parsed.
+func (s *WebRPCSchema) ToOpenAPIComponents() (map[string]interface{}, error) {
+	components := map[string]interface{}{}
+	for _, msg := range s.Messages {
+		component, err := msg.openAPISchema()
+		if err != nil {
+			return nil, err
+		}
+		components[string(msg.Name)] = component
+	}
+	return components, nil
+}
+
+func (m *Message) openAPISchema() (map[string]interface{}, error) {
+	if m.Type == "enum" {
+		values := []interface{}{}
+		names := []string{}
+		for _, field := range m.Fields {
+			values = append(values, json.Number(field.Value))
+			names = append(names, string(field.Name))
+		}
+		component := openAPIType("integer", openAPIIntegerFormat(m.EnumType))
+		component["enum"] = values
+		component["x-enum-varnames"] = names
+		return component, nil
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, field := range m.Fields {
+		if field.JSONOmitted {
+			continue
+		}
+		property, err := field.Type.openAPISchema()
+		if err != nil {
+			return nil, fmt.Errorf("message '%s' field '%s': %w", m.Name, field.Name, err)
+		}
+		if field.Optional && !field.Type.Optional {
+			property = openAPINullable(property)
+		}
+		if field.Format != "" {
+			property["format"] = field.Format
+		}
+		if field.Pattern != "" {
+			property["pattern"] = field.Pattern
+		}
+		minKey, maxKey := "minLength", "maxLength"
+		if field.Type.Type == T_List {
+			minKey, maxKey = "minItems", "maxItems"
+		}
+		if field.MinLength != nil {
+			property[minKey] = *field.MinLength
+		}
+		if field.MaxLength != nil {
+			property[maxKey] = *field.MaxLength
+		}
+		properties[field.WireName()] = property
+		if field.IsRequired() {
+			required = append(required, field.WireName())
+		}
+	}
+
+	component := openAPIType("object", "")
+	component["properties"] = properties
+	if len(required) > 0 {
+		component["required"] = required
+	}
+	if m.Description != "" {
+		component["description"] = m.Description
+	}
+	return component, nil
+}
+
+func (t *VarType) openAPISchema() (map[string]interface{}, error) {
+	if t.Optional || t.Nullable {
+		base := *t
+		base.Optional = false
+		base.Nullable = false
+		schema, err := base.openAPISchema()
+		if err != nil {
+			return nil, err
+		}
+		return openAPINullable(schema), nil
+	}
+
+	switch t.Type {
+	case T_Unknown:
+		return nil, fmt.Errorf("type '%s' is not parsed, validate the schema first", t.Expr)
+	case T_Null:
+		return map[string]interface{}{"nullable": true}, nil
+	case T_Any:
+		return map[string]interface{}{}, nil
+	case T_Bool:
+		return openAPIType("boolean", ""), nil
+	case T_Float32:
+		return openAPIType("number", "float"), nil
+	case T_Float64:
+		return openAPIType("number", "double"), nil
+	case T_Ratio:
+		schema := openAPIType("number", "double")
+		min, max := t.Range.bounds()
+		schema["minimum"] = min
+		schema["maximum"] = max
+		return schema, nil
+	case T_String:
+		return openAPIType("string", ""), nil
+	case T_Timestamp, T_DateTime:
+		return openAPIType("string", "date-time"), nil
+	case T_Date:
+		return openAPIType("string", "date"), nil
+	case T_Time:
+		return openAPIType("string", "time"), nil
+	case T_UUID:
+		return openAPIType("string", "uuid"), nil
+	case T_IPAddr:
+		return openAPIType("string", "ip"), nil
+	case T_CIDR:
+		return openAPIType("string", "cidr"), nil
+	case T_BigInt:
+		schema := openAPIType("string", "")
+		schema["pattern"] = "^-?[0-9]+$"
+		return schema, nil
+	case T_Decimal:
+		return openAPIType("string", "decimal"), nil
+	case T_Rational:
+		schema := openAPIType("string", "")
+		schema["pattern"] = "^-?[0-9]+(/[0-9]+)?$"
+		return schema, nil
+	case T_Blob:
+		return openAPIType("string", "binary"), nil
+	case T_GeoPoint:
+		schema := openAPIType("object", "")
+		schema["properties"] = map[string]interface{}{
+			"lat": openAPIType("number", "double"),
+			"lng": openAPIType("number", "double"),
+		}
+		schema["required"] = []string{"lat", "lng"}
+		return schema, nil
+	case T_Money:
+		currency := openAPIType("string", "")
+		currency["enum"] = []string{t.Money.Currency}
+		schema := openAPIType("object", "")
+		schema["properties"] = map[string]interface{}{
+			"amount":   openAPIType("string", ""),
+			"currency": currency,
+		}
+		schema["required"] = []string{"amount", "currency"}
+		return schema, nil
+	case T_List:
+		if t.IsBytes() {
+			return openAPIType("string", "byte"), nil
+		}
+		items, err := t.List.Elem.openAPISchema()
+		if err != nil {
+			return nil, err
+		}
+		schema := openAPIType("array", "")
+		schema["items"] = items
+		if t.List.Unique {
+			schema["uniqueItems"] = true
+		}
+		return schema, nil
+	case T_Map:
+		value, err := t.Map.Value.openAPISchema()
+		if err != nil {
+			return nil, err
+		}
+		if t.MapKeyWireStrategy() == MapKeysAsPairs {
+			// an array of [key, value] pairs, which OpenAPI 3.0 can only
+			// describe as a two-item array of either schema
+			key, err := (&VarType{Expr: t.Map.Key.String(), Type: t.Map.Key}).openAPISchema()
+			if err != nil {
+				return nil, err
+			}
+			pair := openAPIType("array", "")
+			pair["items"] = map[string]interface{}{"oneOf": []interface{}{key, value}}
+			pair["minItems"] = 2
+			pair["maxItems"] = 2
+			schema := openAPIType("array", "")
+			schema["items"] = pair
+			schema["x-webrpc-key-type"] = t.Map.Key.String()
+			return schema, nil
+		}
+		schema := openAPIType("object", "")
+		schema["additionalProperties"] = value
+		if !isStringWireKey(t.Map.Key) {
+			schema["x-webrpc-key-type"] = t.Map.Key.String()
+		}
+		return schema, nil
+	case T_Result, T_Union:
+		variants := []*VarType{}
+		if t.Type == T_Result {
+			variants = append(variants, t.Result.Ok, t.Result.Err)
+		} else {
+			variants = append(variants, t.Union.Variants...)
+		}
+		oneOf := []interface{}{}
+		for _, variant := range variants {
+			schema, err := variant.openAPISchema()
+			if err != nil {
+				return nil, err
+			}
+			oneOf = append(oneOf, schema)
+		}
+		schema := map[string]interface{}{"oneOf": oneOf}
+		if t.Type == T_Union && t.Union.Discriminator != "" {
+			schema["discriminator"] = map[string]interface{}{"propertyName": t.Union.Discriminator}
+		}
+		return schema, nil
+	case T_Struct:
+		return map[string]interface{}{"$ref": openAPIRefPrefix + t.Struct.Name}, nil
+	default:
+		if _, ok := intDataTypeBits[t.Type]; ok {
+			return openAPIType("integer", openAPIIntegerFormat(t)), nil
+		}
+		return nil, fmt.Errorf("type '%s' has no OpenAPI equivalent", t.Expr)
+	}
+}
+
+func openAPIType(typ, format string) map[string]interface{} {
+	schema := map[string]interface{}{"type": typ}
+	if format != "" {
+		schema["format"] = format
+	}
+	return schema
+}
+
+// openAPIIntegerFormat returns the int32 or int64 format fitting the integer
+// type, as OpenAPI has no unsigned or smaller formats
+func openAPIIntegerFormat(t *VarType) string {
+	if t == nil {
+		return ""
+	}
+	if bits := intDataTypeBits[t.Type]; bits < 32 || (bits == 32 && !isUnsignedType(t.Type)) {
+		return "int32"
+	}
+	return "int64"
+}
+
+// openAPINullable makes the schema nullable. A $ref can't have siblings in
+// OpenAPI 3.0, so it's wrapped in allOf first.
+func openAPINullable(schema map[string]interface{}) map[string]interface{} {
+	if _, isRef := schema["$ref"]; isRef {
+		schema = map[string]interface{}{"allOf": []interface{}{schema}}
+	}
+	schema["nullable"] = true
+	return schema
+}
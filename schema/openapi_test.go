@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToOpenAPIComponents(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Kind",
+				"type": "enum",
+				"fields": [
+					{ "name": "USER", "type": "uint32", "value": "1" },
+					{ "name": "ADMIN", "type": "uint32", "value": "2" }
+				]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "email", "type": "string", "meta": [{ "format": "email" }, { "json": "email_address" }] },
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "manager", "type": "User?" },
+					{ "name": "tags", "type": "[]string", "optional": true },
+					{ "name": "scores", "type": "map<uint32,float64>" },
+					{ "name": "createdAt", "type": "timestamp" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	components, err := s.ToOpenAPIComponents()
+	assert.NoError(t, err)
+
+	out, err := json.MarshalIndent(components, "", "  ")
+	assert.NoError(t, err)
+
+	golden := `{
+  "Kind": {
+    "enum": [
+      1,
+      2
+    ],
+    "format": "int64",
+    "type": "integer",
+    "x-enum-varnames": [
+      "USER",
+      "ADMIN"
+    ]
+  },
+  "User": {
+    "properties": {
+      "createdAt": {
+        "format": "date-time",
+        "type": "string"
+      },
+      "email_address": {
+        "format": "email",
+        "type": "string"
+      },
+      "id": {
+        "format": "int64",
+        "type": "integer"
+      },
+      "kind": {
+        "$ref": "#/components/schemas/Kind"
+      },
+      "manager": {
+        "allOf": [
+          {
+            "$ref": "#/components/schemas/User"
+          }
+        ],
+        "nullable": true
+      },
+      "scores": {
+        "additionalProperties": {
+          "format": "double",
+          "type": "number"
+        },
+        "type": "object",
+        "x-webrpc-key-type": "uint32"
+      },
+      "tags": {
+        "items": {
+          "type": "string"
+        },
+        "nullable": true,
+        "type": "array"
+      }
+    },
+    "required": [
+      "id",
+      "email_address",
+      "kind",
+      "scores",
+      "createdAt"
+    ],
+    "type": "object"
+  }
+}`
+	assert.Equal(t, golden, string(out))
+
+	_, err = (&WebRPCSchema{Messages: []*Message{{Name: "Bad", Type: "struct", Fields: []*MessageField{{Name: "x", Type: &VarType{Expr: "x"}}}}}}).ToOpenAPIComponents()
+	assert.EqualError(t, err, "message 'Bad' field 'x': type 'x' is not parsed, validate the schema first")
+}
+
+func TestOpenAPIPreservedByte(t *testing.T) {
+	s := newTestSchema()
+	s.ParseOptions = ParseOptions{PreserveByte: true}
+
+	vt := &VarType{Expr: "byte"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Byte, vt.Type)
+	schema, err := vt.openAPISchema()
+	assert.NoError(t, err)
+	assert.Equal(t, openAPIType("integer", "int32"), schema)
+
+	vt = &VarType{Expr: "map<string,byte>"}
+	assert.NoError(t, vt.Parse(s))
+	schema, err = vt.openAPISchema()
+	assert.NoError(t, err)
+	assert.Equal(t, openAPIType("integer", "int32"), schema["additionalProperties"])
+}
+
+func TestOpenAPIMapPairs(t *testing.T) {
+	s := newTestSchema()
+	s.ParseOptions.MapKeyWireStrategy = MapKeysAsPairs
+
+	vt := &VarType{Expr: "map<uint64,string>"}
+	assert.NoError(t, vt.Parse(s))
+	schema, err := vt.openAPISchema()
+	assert.NoError(t, err)
+
+	out, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"items":{"items":{"oneOf":[{"format":"int64","type":"integer"},{"type":"string"}]},"maxItems":2,"minItems":2,"type":"array"},"type":"array","x-webrpc-key-type":"uint64"}`, string(out))
+
+	// string keys stay objects
+	vt = &VarType{Expr: "map<string,string>"}
+	assert.NoError(t, vt.Parse(s))
+	schema, err = vt.openAPISchema()
+	assert.NoError(t, err)
+	assert.Equal(t, "object", schema["type"])
+}
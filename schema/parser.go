@@ -2,7 +2,10 @@ package schema
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"regexp"
+	"strings"
 )
 
 type Parser interface {
@@ -24,6 +27,49 @@ func ParseSchemaJSON(jsondata []byte) (*WebRPCSchema, error) {
 	return schema, nil
 }
 
+// ParseSchema decodes a JSON schema from r and resolves all of its types.
+// Problems found by Check are reported together as SchemaErrors, before the
+// schema is validated. Messages are indexed by name while loading, so large
+// schemas resolve their type references quickly.
+func ParseSchema(r io.Reader) (*WebRPCSchema, error) {
+	var schema *WebRPCSchema
+	err := json.NewDecoder(r).Decode(&schema)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema error: empty schema")
+	}
+
+	if errs := schema.Check(); len(errs) > 0 {
+		return schema, SchemaErrors(errs)
+	}
+
+	schema.messageIndex = make(map[string]*Message, len(schema.Messages))
+	for _, msg := range schema.Messages {
+		schema.messageIndex[string(msg.Name)] = msg
+	}
+	defer func() { schema.messageIndex = nil }()
+
+	err = schema.Validate()
+	if err != nil {
+		return schema, err
+	}
+
+	return schema, nil
+}
+
+// SchemaErrors is a list of problems found in a schema, see ParseSchema
+type SchemaErrors []error
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("schema error: %d problems found: %s", len(e), strings.Join(msgs, "; "))
+}
+
 var NameWhitelistRexp = regexp.MustCompile(`^[a-zA-Z]+[a-zA-Z0-9_]*$`)
 
 func IsValidArgName(s string) bool {
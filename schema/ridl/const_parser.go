@@ -0,0 +1,25 @@
+package ridl
+
+func parserStateConst(p *parser) parserState {
+	// const <name> = <value> [<# comment>]
+	matches, err := p.match(tokenWord, tokenWhitespace, tokenWord, tokenWhitespace, tokenEqual, tokenWhitespace)
+	if err != nil {
+		return p.stateError(err)
+	}
+
+	if matches[0].val != wordConst {
+		return p.stateError(errUnexpectedToken)
+	}
+
+	value, err := p.expectLiteralValue()
+	if err != nil {
+		return p.stateError(err)
+	}
+
+	p.emit(&ConstNode{
+		name:  newTokenNode(matches[2]),
+		value: newTokenNode(value),
+	})
+
+	return parserStateEOL
+}
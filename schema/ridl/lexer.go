@@ -2,6 +2,7 @@ package ridl
 
 import (
 	"fmt"
+	"unicode"
 )
 
 var (
@@ -176,7 +177,8 @@ func isWord(r rune) bool {
 			return true
 		}
 	}
-	return false
+	// names may use non-ASCII letters, ie. `message Café`
+	return unicode.IsLetter(r)
 }
 
 func lexPushTokenState(tt tokenType) lexState {
@@ -11,6 +11,7 @@ import (
 )
 
 const (
+	wordConst   = "const"
 	wordEnum    = "enum"
 	wordImport  = "import"
 	wordMap     = "map"
@@ -386,6 +387,9 @@ func parserStateDeclaration(p *parser) parserState {
 	case wordWebRPC, wordName, wordVersion:
 		// <word> = <value> # optional comment
 		return parserStateDefinition
+	case wordConst:
+		// const <name> = <value>
+		return parserStateConst
 	case wordImport:
 		// import
 		//   - <value> [<# comment>]
@@ -13,6 +13,7 @@ const (
 	ArgumentNodeType
 	MethodNodeType
 	ServiceNodeType
+	ConstNodeType
 )
 
 // Node represents a parser tree node
@@ -81,6 +82,17 @@ func (rn RootNode) Imports() []*ImportNode {
 	return importNodes
 }
 
+func (rn RootNode) Consts() []*ConstNode {
+	nodes := rn.Filter(ConstNodeType)
+
+	constNodes := make([]*ConstNode, 0, len(nodes))
+	for i := range nodes {
+		constNodes = append(constNodes, nodes[i].(*ConstNode))
+	}
+
+	return constNodes
+}
+
 func (rn RootNode) Messages() []*MessageNode {
 	nodes := rn.Filter(MessageNodeType)
 
@@ -208,6 +220,31 @@ func (in ImportNode) Type() NodeType {
 	return ImportNodeType
 }
 
+type ConstNode struct {
+	node
+
+	name  *TokenNode
+	value *TokenNode
+}
+
+func (cn ConstNode) Name() *TokenNode {
+	if cn.name == nil {
+		return invalidToken
+	}
+	return cn.name
+}
+
+func (cn ConstNode) Value() *TokenNode {
+	if cn.value == nil {
+		return invalidToken
+	}
+	return cn.value
+}
+
+func (cn ConstNode) Type() NodeType {
+	return ConstNodeType
+}
+
 type EnumNode struct {
 	node
 
@@ -36,7 +36,9 @@ func unescapeString(in string) (string, error) {
 				return "", errors.New("unexpected end after backslash")
 			}
 		} else {
-			out = out + string(c)
+			// append the byte as is, string(c) would re-encode the bytes of
+			// multi-byte runes as separate runes
+			out = out + in[i:i+1]
 		}
 	}
 
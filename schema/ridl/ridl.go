@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 
 	"github.com/webrpc/webrpc/schema"
 )
@@ -127,6 +126,11 @@ func (p *Parser) parse() (*schema.WebRPCSchema, error) {
 			return nil, p.trace(err, line.Path())
 		}
 
+		for i := range imported.Constants {
+			if isImportAllowed(string(imported.Constants[i].Name), importDef.Members) {
+				s.Constants = append(s.Constants, imported.Constants[i])
+			}
+		}
 		for i := range imported.Messages {
 			if isImportAllowed(string(imported.Messages[i].Name), importDef.Members) {
 				s.Messages = append(s.Messages, imported.Messages[i])
@@ -141,6 +145,14 @@ func (p *Parser) parse() (*schema.WebRPCSchema, error) {
 		s.Imports = append(s.Imports, importDef)
 	}
 
+	// constants
+	for _, line := range q.root.Consts() {
+		s.Constants = append(s.Constants, &schema.Constant{
+			Name:  schema.VarName(line.Name().String()),
+			Value: line.Value().String(),
+		})
+	}
+
 	// pushing enums (1st pass)
 	for _, line := range q.root.Enums() {
 		s.Messages = append(s.Messages, &schema.Message{
@@ -181,13 +193,10 @@ func (p *Parser) parse() (*schema.WebRPCSchema, error) {
 			return nil, fmt.Errorf("unknown data type: %v", line.TypeName())
 		}
 
-		for i, def := range line.Values() {
+		for _, def := range line.Values() {
+			// values left out follow the previous one, see Message.Parse
 			key, val := def.Left().String(), def.Right().String()
 
-			if val == "" {
-				val = strconv.Itoa(i)
-			}
-
 			enumDef.Fields = append(enumDef.Fields, &schema.MessageField{
 				Name:  schema.VarName(key),
 				Type:  &enumType,
@@ -169,6 +169,90 @@ func TestRIDLEnum(t *testing.T) {
 		assert.Equal(t, "1", string(s.Messages[1].Fields[1].Value))
 		assert.Equal(t, "2", string(s.Messages[1].Fields[2].Value))
 	}
+
+	{
+		input := `
+    webrpc = v1
+    version = v0.1.1
+    name = hello-webrpc
+
+    enum Color: uint32
+      - RED = 1
+      - GREEN = 5
+      - TEAL
+      - BLUE = 10
+  `
+		s, err := parseString(input)
+		assert.NoError(t, err)
+
+		values := []string{}
+		for _, field := range s.Messages[0].Fields {
+			values = append(values, field.Value)
+		}
+		assert.Equal(t, []string{"1", "5", "6", "10"}, values)
+	}
+
+	{
+		input := `
+    webrpc = v1
+    version = v0.1.1
+    name = hello-webrpc
+
+    enum Color: uint32
+      - RED = 1
+      - GREEN = 1
+  `
+		_, err := parseString(input)
+		assert.Error(t, err)
+	}
+}
+
+func TestRIDLConst(t *testing.T) {
+	input := `
+    webrpc = v1
+    version = v0.1.1
+    name = hello-webrpc
+
+    const MAX = 100 # comment
+    const MASK = 0xFF
+
+    message Query
+      - limit: uint32
+        + default = MAX
+      - mask: uint8
+        + default = MASK
+  `
+	s, err := parseString(input)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(s.Constants))
+	assert.Equal(t, "MAX", string(s.Constants[0].Name))
+	assert.Equal(t, "100", s.Constants[0].Value)
+	assert.Equal(t, "MASK", string(s.Constants[1].Name))
+	assert.Equal(t, "255", s.Constants[1].Value)
+
+	assert.Equal(t, "100", s.Messages[0].Fields[0].Default)
+	assert.Equal(t, "255", s.Messages[0].Fields[1].Default)
+}
+
+func TestRIDLUnicodeNames(t *testing.T) {
+	input := `
+    webrpc = v1
+    version = v0.1.1
+    name = hello-webrpc
+
+    message Café
+      - name: string
+
+    message Menu
+      - cafes: map<string,Café>
+  `
+	s, err := parseString(input)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Café", string(s.Messages[0].Name))
+	assert.Equal(t, "cafes", string(s.Messages[1].Fields[0].Name))
+	assert.Equal(t, "Café", s.Messages[1].Fields[0].Type.Map.Value.Struct.Name)
 }
 
 func TestRIDLMessages(t *testing.T) {
@@ -261,6 +345,9 @@ func TestRIDLMessages(t *testing.T) {
 		assert.Equal(t, "Field3", string(s.Messages[0].Fields[2].Name))
 
 		assert.Equal(t, "field_2", s.Messages[0].Fields[1].Meta[0]["json"])
+		assert.Equal(t, "field_2", s.Messages[0].Fields[1].JSONName)
+		assert.Equal(t, "field_2", s.Messages[0].Fields[1].WireName())
+		assert.Equal(t, "Field3", s.Messages[0].Fields[2].WireName())
 		assert.Equal(t, "field_3", s.Messages[0].Fields[1].Meta[1]["go.tag.db"])
 
 		assert.Equal(t, "-", s.Messages[0].Fields[2].Meta[0]["go.tag.db"])
@@ -410,6 +497,24 @@ func TestRIDLService(t *testing.T) {
 	}
 }
 
+func TestRIDLParseExamples(t *testing.T) {
+	// the shipped examples use json = - and json = name,omitempty metas
+	for _, path := range []string{
+		"../../_examples/hello-webrpc/hello-api.ridl",
+		"../../_examples/hello-webrpc-ts/hello-api.ridl",
+		"../../_examples/node-ts/service.ridl",
+		"../../_examples/golang-basics/example.ridl",
+	} {
+		fp, err := os.Open(path)
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		_, err = NewParser(schema.NewReader(fp, path)).Parse()
+		fp.Close()
+		assert.NoError(t, err, path)
+	}
+}
+
 func TestRIDLParse(t *testing.T) {
 	fp, err := os.Open("_example/example0.ridl")
 	assert.NoError(t, err)
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -15,13 +16,25 @@ const (
 
 // schema of webrpc json file, and validations
 type WebRPCSchema struct {
+	// WebrpcVersion is the webrpc schema version, ie. "v1", or a full
+	// version, ie. "v1.2.0", to only allow the type syntax of that version,
+	// see RequireVersion
 	WebrpcVersion string `json:"webrpc"`
 	SchemaName    string `json:"name"`
 	SchemaVersion string `json:"version"`
 
-	Imports  []*Import  `json:"imports"`
-	Messages []*Message `json:"messages"`
-	Services []*Service `json:"services"`
+	Imports   []*Import   `json:"imports"`
+	Constants []*Constant `json:"constants,omitempty"`
+	Aliases   []*Alias    `json:"aliases,omitempty"`
+	Messages  []*Message  `json:"messages"`
+	Services  []*Service  `json:"services"`
+
+	// ParseOptions used when parsing and rebuilding type expressions
+	ParseOptions ParseOptions `json:"-"`
+
+	// messageIndex speeds up message lookups by name while loading a
+	// schema, see ParseSchema. Lookups fall back to a scan on a miss.
+	messageIndex map[string]*Message
 }
 
 type Import struct {
@@ -32,10 +45,28 @@ type Import struct {
 // Validate validates the schema through the AST, intended to be called after
 // the json has been unmarshalled
 func (s *WebRPCSchema) Validate() error {
-	if s.WebrpcVersion != VERSION {
+	if v, ok := parseSchemaVersion(s.WebrpcVersion); !ok || fmt.Sprintf("v%d", v.major) != VERSION {
 		return fmt.Errorf("webrpc schema version, '%s' is invalid, try '%s'", s.WebrpcVersion, VERSION)
 	}
+	if err := s.parseOptions().validate(); err != nil {
+		return err
+	}
+	if err := s.ValidateTypeNames(); err != nil {
+		return err
+	}
 
+	for _, constant := range s.Constants {
+		err := constant.Parse(s)
+		if err != nil {
+			return err
+		}
+	}
+	for _, alias := range s.Aliases {
+		err := alias.Parse(s)
+		if err != nil {
+			return err
+		}
+	}
 	for _, msg := range s.Messages {
 		err := msg.Parse(s)
 		if err != nil {
@@ -49,7 +80,7 @@ func (s *WebRPCSchema) Validate() error {
 		}
 	}
 
-	return nil
+	return s.checkTypeFeatures()
 }
 
 func (s *WebRPCSchema) SchemaHash() (string, error) {
@@ -86,6 +117,92 @@ func (s *WebRPCSchema) ToJSON(optIndent ...bool) (string, error) {
 	return string(buf.Bytes()), nil
 }
 
+// CanonicalJSON serializes the schema in a deterministic form, so that two
+// semantically identical schemas produce byte-identical output. Aliases,
+// messages, services and methods are sorted by name, while field order is
+// preserved. Type exprs are canonicalized in the default syntax, whatever the
+// schema's ParseOptions, as the options aren't serialized and the output is
+// parsed back with the defaults. Imports are left out as their definitions
+// are already merged into the schema. The schema must be parsed, see
+// Validate.
+func (s *WebRPCSchema) CanonicalJSON() ([]byte, error) {
+	var err error
+	canonicalType := func(t *VarType) *VarType {
+		if t == nil || err != nil {
+			return t
+		}
+		if t.Type == T_Unknown {
+			err = fmt.Errorf("schema error: type '%s' is not parsed, validate the schema first", t.Expr)
+			return t
+		}
+		vt := *t
+		vt.Expr = buildVarTypeExpr(t, "", ParseOptions{})
+		return &vt
+	}
+
+	c := *s
+	c.Imports = nil
+
+	c.Constants = append([]*Constant{}, s.Constants...)
+	sort.SliceStable(c.Constants, func(i, j int) bool { return c.Constants[i].Name < c.Constants[j].Name })
+
+	c.Aliases = make([]*Alias, 0, len(s.Aliases))
+	for _, alias := range s.Aliases {
+		a := *alias
+		a.Type = canonicalType(alias.Type)
+		c.Aliases = append(c.Aliases, &a)
+	}
+	sort.SliceStable(c.Aliases, func(i, j int) bool { return c.Aliases[i].Name < c.Aliases[j].Name })
+
+	c.Messages = make([]*Message, 0, len(s.Messages))
+	for _, msg := range s.Messages {
+		m := *msg
+		m.Fields = make([]*MessageField, 0, len(msg.Fields))
+		for _, field := range msg.Fields {
+			f := *field
+			f.Type = canonicalType(field.Type)
+			m.Fields = append(m.Fields, &f)
+		}
+		c.Messages = append(c.Messages, &m)
+	}
+	sort.SliceStable(c.Messages, func(i, j int) bool { return c.Messages[i].Name < c.Messages[j].Name })
+
+	c.Services = make([]*Service, 0, len(s.Services))
+	for _, svc := range s.Services {
+		sv := *svc
+		sv.Methods = make([]*Method, 0, len(svc.Methods))
+		for _, method := range svc.Methods {
+			m := *method
+			m.Inputs = canonicalArguments(method.Inputs, canonicalType)
+			m.Outputs = canonicalArguments(method.Outputs, canonicalType)
+			sv.Methods = append(sv.Methods, &m)
+		}
+		sort.SliceStable(sv.Methods, func(i, j int) bool { return sv.Methods[i].Name < sv.Methods[j].Name })
+		c.Services = append(c.Services, &sv)
+	}
+	sort.SliceStable(c.Services, func(i, j int) bool { return c.Services[i].Name < c.Services[j].Name })
+
+	if err != nil {
+		return nil, err
+	}
+
+	jsonString, err := c.ToJSON(true)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(jsonString), nil
+}
+
+func canonicalArguments(args []*MethodArgument, canonicalType func(*VarType) *VarType) []*MethodArgument {
+	out := make([]*MethodArgument, 0, len(args))
+	for _, arg := range args {
+		a := *arg
+		a.Type = canonicalType(arg.Type)
+		out = append(out, &a)
+	}
+	return out
+}
+
 func (s *WebRPCSchema) GetMessageByName(name string) *Message {
 	name = strings.ToLower(name)
 	for _, message := range s.Messages {
@@ -138,3 +255,718 @@ func (s *WebRPCSchema) HasFieldType(fieldType string) (bool, error) {
 
 	return false, nil
 }
+
+// AllTypes returns every distinct type used by message fields and method
+// arguments, including nested sub-types, in order of first appearance. Types
+// are deduplicated by their canonical expr.
+func (s *WebRPCSchema) AllTypes() []*VarType {
+	types := []*VarType{}
+	seen := map[string]struct{}{}
+
+	add := func(t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			expr := buildVarTypeExpr(vt, "", s.parseOptions())
+			if _, ok := seen[expr]; !ok {
+				seen[expr] = struct{}{}
+				types = append(types, vt)
+			}
+			return true
+		})
+	}
+
+	for _, msg := range s.Messages {
+		for _, field := range msg.Fields {
+			add(field.Type)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				add(input.Type)
+			}
+			for _, output := range method.Outputs {
+				add(output.Type)
+			}
+		}
+	}
+
+	return types
+}
+
+// MapKeyTypesUsed returns the distinct key types of every map used by message
+// fields and method arguments, including nested maps, sorted by name. It lets
+// generators warn about key types their target doesn't support.
+func (s *WebRPCSchema) MapKeyTypesUsed() []DataType {
+	keys := []DataType{}
+	seen := map[DataType]bool{}
+	for _, t := range s.AllTypes() {
+		if t.Type == T_Map && t.Map != nil && !seen[t.Map.Key] {
+			seen[t.Map.Key] = true
+			keys = append(keys, t.Map.Key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// MessageUsage reports whether the named message is used by method inputs,
+// outputs, or both, for generators splitting request and response types.
+// Messages referenced through the fields of an argument type count too, ie.
+// an Address field of a User input. Unknown or unused messages are neither.
+func (s *WebRPCSchema) MessageUsage(name string) (input, output bool) {
+	msg := s.GetMessageByName(name)
+	if msg == nil {
+		return false, false
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			input = input || reachableMessages(s, method.Inputs)[msg]
+			output = output || reachableMessages(s, method.Outputs)[msg]
+		}
+	}
+	return input, output
+}
+
+// RequiredPrimitives returns the distinct leaf data types used by message
+// fields and method arguments, including map keys and enum types, in data
+// type declaration order, ie. [uint32 string timestamp]. Containers, unions,
+// results and struct references are left out, so generators can check their
+// supported primitives up front and list the unsupported ones.
+func (s *WebRPCSchema) RequiredPrimitives() []DataType {
+	used := map[DataType]bool{}
+	for _, t := range s.AllTypes() {
+		switch t.Type {
+		case T_Map:
+			used[t.Map.Key] = true
+		case T_List, T_Result, T_Union, T_Struct, T_Unknown:
+		default:
+			used[t.Type] = true
+		}
+	}
+
+	primitives := []DataType{}
+	for _, dt := range allDataTypes {
+		if used[dt] {
+			primitives = append(primitives, dt)
+		}
+	}
+	return primitives
+}
+
+// ValidateEnumMemberNames checks that no two enums share a member name, ie.
+// Color.RED and Status.RED, for targets flattening enum members into one
+// global namespace, as in C. It isn't part of Validate, as most targets
+// namespace members by their enum, so generators needing it call it
+// themselves. All collisions are reported together as SchemaErrors.
+func (s *WebRPCSchema) ValidateEnumMemberNames() error {
+	var errs SchemaErrors
+	owners := map[VarName]VarName{}
+	for _, msg := range s.Messages {
+		if msg.Type != "enum" {
+			continue
+		}
+		for _, field := range msg.Fields {
+			if owner, ok := owners[field.Name]; ok {
+				errs = append(errs, fmt.Errorf("enum member '%s' of '%s' collides with '%s.%s'", field.Name, msg.Name, owner, field.Name))
+				continue
+			}
+			owners[field.Name] = msg.Name
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateTypeNames checks that no name is defined as two kinds of type, ie.
+// both an alias and a message, or both an enum and a message, which would make
+// resolving references to it ambiguous. Names are compared case-insensitively,
+// as in resolution. Duplicates of the same kind are left to the alias and
+// message checks. It runs as part of Validate, before any type is resolved.
+// All collisions are reported together as SchemaErrors.
+func (s *WebRPCSchema) ValidateTypeNames() error {
+	var errs SchemaErrors
+	kinds := map[string]string{}
+	define := func(name VarName, kind string) {
+		key := strings.ToLower(string(name))
+		if prev, ok := kinds[key]; ok {
+			if prev != kind {
+				errs = append(errs, fmt.Errorf("type name '%s' is defined as both %s and %s", name, prev, kind))
+			}
+			return
+		}
+		kinds[key] = kind
+	}
+
+	for _, alias := range s.Aliases {
+		define(alias.Name, "an alias")
+	}
+	for _, msg := range s.Messages {
+		if msg.Type == "enum" {
+			define(msg.Name, "an enum")
+		} else {
+			define(msg.Name, "a message")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// IntegerChange is a type rewritten by NormalizeIntegers
+type IntegerChange struct {
+	Location string // ie. "message 'User' field 'ids'"
+	From     string // type expr before, ie. "[]int"
+	To       string // type expr after, ie. "[]int64"
+}
+
+// NormalizeIntegers rewrites the platform-sized int and uint types to int64
+// and uint64 across aliases, message fields, enums and method arguments,
+// including map keys and container elements, so the schema means the same on
+// every target. Type exprs are rebuilt, and the changes are returned in
+// schema order. Types whose expr doesn't change, ie. alias references kept by
+// PreserveAliases, are rewritten without being reported, as the change shows
+// on the alias itself. The schema must be parsed. See NormalizeIntegersDryRun to
+// list the changes without applying them.
+func (s *WebRPCSchema) NormalizeIntegers() []IntegerChange {
+	return s.normalizeIntegers(true)
+}
+
+// NormalizeIntegersDryRun returns the changes NormalizeIntegers would make,
+// leaving the schema untouched
+func (s *WebRPCSchema) NormalizeIntegersDryRun() []IntegerChange {
+	return s.normalizeIntegers(false)
+}
+
+func (s *WebRPCSchema) normalizeIntegers(apply bool) []IntegerChange {
+	opts := s.parseOptions()
+	changes := []IntegerChange{}
+	normalize := func(location string, t **VarType) bool {
+		if *t == nil {
+			return false
+		}
+		normalized := normalizeIntegerType(*t, opts)
+		if normalized == *t {
+			return false
+		}
+		if normalized.Expr != (*t).Expr {
+			changes = append(changes, IntegerChange{Location: location, From: (*t).Expr, To: normalized.Expr})
+		}
+		if apply {
+			*t = normalized
+		}
+		return true
+	}
+
+	for _, alias := range s.Aliases {
+		normalize(fmt.Sprintf("alias '%s'", alias.Name), &alias.Type)
+	}
+	for _, msg := range s.Messages {
+		if msg.Type == "enum" {
+			// enums report once, but every member has its own type
+			if msg.EnumType == nil || !normalize(fmt.Sprintf("enum '%s'", msg.Name), &msg.EnumType) || !apply {
+				continue
+			}
+			for _, field := range msg.Fields {
+				field.Type = normalizeIntegerType(field.Type, opts)
+			}
+			msg.EnumType = msg.Fields[0].Type
+			continue
+		}
+		for _, field := range msg.Fields {
+			normalize(fmt.Sprintf("message '%s' field '%s'", msg.Name, field.Name), &field.Type)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				normalize(fmt.Sprintf("service '%s' method '%s' input '%s'", svc.Name, method.Name, input.Name), &input.Type)
+			}
+			for _, output := range method.Outputs {
+				normalize(fmt.Sprintf("service '%s' method '%s' output '%s'", svc.Name, method.Name, output.Name), &output.Type)
+			}
+		}
+	}
+	return changes
+}
+
+// normalizeIntegerType returns t with int and uint rewritten to int64 and
+// uint64, or t itself when it holds neither. Rewritten nodes are copies with
+// rebuilt exprs, so t is left untouched.
+func normalizeIntegerType(t *VarType, opts ParseOptions) *VarType {
+	if t == nil {
+		return nil
+	}
+
+	vt := *t
+	switch t.Type {
+	case T_Int, T_Uint:
+		vt.Type = platformSizedIntegers[t.Type]
+	case T_List:
+		elem := normalizeIntegerType(t.List.Elem, opts)
+		if elem == t.List.Elem {
+			return t
+		}
+		list := *t.List
+		list.Elem = elem
+		vt.List = &list
+	case T_Map:
+		key, ok := platformSizedIntegers[t.Map.Key]
+		if !ok {
+			key = t.Map.Key
+		}
+		value := normalizeIntegerType(t.Map.Value, opts)
+		if key == t.Map.Key && value == t.Map.Value {
+			return t
+		}
+		m := *t.Map
+		m.Key, m.Value = key, value
+		vt.Map = &m
+	case T_Result:
+		ok, err := normalizeIntegerType(t.Result.Ok, opts), normalizeIntegerType(t.Result.Err, opts)
+		if ok == t.Result.Ok && err == t.Result.Err {
+			return t
+		}
+		vt.Result = &VarResultType{Ok: ok, Err: err}
+	case T_Union:
+		changed := false
+		variants := make([]*VarType, 0, len(t.Union.Variants))
+		for _, variant := range t.Union.Variants {
+			normalized := normalizeIntegerType(variant, opts)
+			changed = changed || normalized != variant
+			variants = append(variants, normalized)
+		}
+		if !changed {
+			return t
+		}
+		union := *t.Union
+		union.Variants = variants
+		vt.Union = &union
+	default:
+		return t
+	}
+
+	vt.Expr = buildVarTypeExpr(&vt, "", opts)
+	return &vt
+}
+
+// OptionalFields returns the dotted paths of every optional struct field, ie.
+// "User.nickname", in declaration order, for targets deciding between
+// pointer and value types. A field is optional when marked so itself, or when
+// its type is optional or nullable, ie. string?, matching the pointer types
+// of GoType. Optional elements of a container, ie. []string?, don't make the
+// field optional.
+func (s *WebRPCSchema) OptionalFields() []string {
+	paths := []string{}
+	for _, msg := range s.Messages {
+		if msg.Type != "struct" {
+			continue
+		}
+		for _, field := range msg.Fields {
+			if field.Optional || (field.Type != nil && (field.Type.Optional || field.Type.Nullable)) {
+				paths = append(paths, string(msg.Name)+"."+string(field.Name))
+			}
+		}
+	}
+	return paths
+}
+
+// AssertResolved checks that every message field type is fully resolved, see
+// VarType.IsFullyResolved, as a last check before generating code. All
+// unresolved struct references are reported together as SchemaErrors, along
+// with the message and field holding them. It doesn't modify the schema, so
+// it's safe to call any number of times.
+func (s *WebRPCSchema) AssertResolved() error {
+	var errs SchemaErrors
+	for _, msg := range s.Messages {
+		for _, field := range msg.Fields {
+			if field.Type == nil {
+				errs = append(errs, fmt.Errorf("field '%s' in message '%s' has no type", field.Name, msg.Name))
+				continue
+			}
+			if field.Type.IsFullyResolved() {
+				continue
+			}
+			field.Type.Walk(func(t *VarType) bool {
+				switch t.Type {
+				case T_Unknown:
+					errs = append(errs, fmt.Errorf("field '%s' in message '%s' has an unparsed type '%s'", field.Name, msg.Name, t.Expr))
+				case T_Struct:
+					if t.Struct == nil {
+						errs = append(errs, fmt.Errorf("field '%s' in message '%s' references an unresolved message", field.Name, msg.Name))
+					} else if t.Struct.Message == nil {
+						errs = append(errs, fmt.Errorf("field '%s' in message '%s' references unresolved message '%s'", field.Name, msg.Name, t.Struct.Name))
+					}
+				}
+				return true
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldsOfTypeMaxDepth limits how deep FieldsOfType descends into nested
+// struct fields, counting the top-level message
+const fieldsOfTypeMaxDepth = 4
+
+// FieldsOfType returns the dotted paths of every struct field of the given
+// data type, optional or not, ie. "User.createdAt" for timestamp. Fields of
+// nested structs are included under their parent field, ie.
+// "Order.customer.createdAt", down to a limited depth and without following
+// recursive references. Fields holding the type in a container aren't
+// included.
+func (s *WebRPCSchema) FieldsOfType(dt DataType) []string {
+	return s.fieldsMatching(func(t *VarType) bool {
+		return t.Type == dt
+	})
+}
+
+// bigNumberDataTypes need arbitrary-precision handling, see BigNumberFields
+var bigNumberDataTypes = map[DataType]bool{
+	T_BigInt:   true,
+	T_Decimal:  true,
+	T_Rational: true,
+}
+
+// BigNumberFields returns the dotted paths of every struct field holding a
+// bigint, decimal or rational, for generators to add big number imports and
+// marshalers. Unlike FieldsOfType, fields holding one in a container are
+// included, ie. "Invoice.lines" for []decimal or map<bigint,string>.
+func (s *WebRPCSchema) BigNumberFields() []string {
+	return s.fieldsMatching(func(t *VarType) bool {
+		found := false
+		t.Walk(func(t *VarType) bool {
+			if bigNumberDataTypes[t.Type] || (t.Type == T_Map && t.Map != nil && bigNumberDataTypes[t.Map.Key]) {
+				found = true
+			}
+			return !found && t.Type != T_Struct
+		})
+		return found
+	})
+}
+
+// fieldsMatching returns the dotted paths of every struct field whose type
+// matches, descending into nested struct fields the way FieldsOfType does
+func (s *WebRPCSchema) fieldsMatching(match func(t *VarType) bool) []string {
+	paths := []string{}
+
+	var visit func(msg *Message, path string, depth int, seen map[*Message]bool)
+	visit = func(msg *Message, path string, depth int, seen map[*Message]bool) {
+		seen[msg] = true
+		defer delete(seen, msg)
+
+		for _, field := range msg.Fields {
+			if field.Type == nil {
+				continue
+			}
+			fieldPath := path + "." + string(field.Name)
+			if match(field.Type) {
+				paths = append(paths, fieldPath)
+				continue
+			}
+			if field.Type.Type == T_Struct && depth < fieldsOfTypeMaxDepth {
+				nested := field.Type.Struct.Message
+				if nested != nil && nested.Type == "struct" && !seen[nested] {
+					visit(nested, fieldPath, depth+1, seen)
+				}
+			}
+		}
+	}
+
+	for _, msg := range s.Messages {
+		if msg.Type == "struct" {
+			visit(msg, string(msg.Name), 1, map[*Message]bool{})
+		}
+	}
+
+	return paths
+}
+
+// ReparseMessage re-resolves the field types of the named message, along with
+// every message field and method argument type referencing it, ie. after the
+// message definition was edited or replaced. Unrelated types are left as is.
+func (s *WebRPCSchema) ReparseMessage(name string) error {
+	msg := s.GetMessageByName(name)
+	if msg == nil {
+		return fmt.Errorf("schema error: message '%s' not found", name)
+	}
+
+	err := msg.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range s.dependentTypes(string(msg.Name)) {
+		err := t.Parse(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependentTypes returns the message field and method argument types which
+// reference the named message anywhere in their type tree
+func (s *WebRPCSchema) dependentTypes(name string) []*VarType {
+	types := []*VarType{}
+
+	add := func(t *VarType) {
+		found := false
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type == T_Struct && vt.Struct != nil && vt.Struct.Name == name {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			types = append(types, t)
+		}
+	}
+
+	for _, msg := range s.Messages {
+		if string(msg.Name) == name {
+			continue
+		}
+		for _, field := range msg.Fields {
+			add(field.Type)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				add(input.Type)
+			}
+			for _, output := range method.Outputs {
+				add(output.Type)
+			}
+		}
+	}
+
+	return types
+}
+
+// ReferenceCounts returns how many message fields and method arguments
+// reference each message, including unreferenced messages with a count of
+// zero. A field referencing the same message more than once counts once.
+func (s *WebRPCSchema) ReferenceCounts() map[string]int {
+	counts := map[string]int{}
+	for _, msg := range s.Messages {
+		counts[string(msg.Name)] = 0
+	}
+
+	count := func(t *VarType) {
+		seen := map[string]bool{}
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type == T_Struct && vt.Struct != nil && !seen[vt.Struct.Name] {
+				seen[vt.Struct.Name] = true
+				counts[vt.Struct.Name]++
+			}
+			return true
+		})
+	}
+
+	for _, msg := range s.Messages {
+		for _, field := range msg.Fields {
+			count(field.Type)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			for _, input := range method.Inputs {
+				count(input.Type)
+			}
+			for _, output := range method.Outputs {
+				count(output.Type)
+			}
+		}
+	}
+
+	return counts
+}
+
+// EnumReferences maps each enum to the messages and methods that reference
+// it, ie. "User" or "UserService.GetUser", listed once each in declaration
+// order. Unreferenced enums map to an empty list.
+func (s *WebRPCSchema) EnumReferences() map[string][]string {
+	refs := map[string][]string{}
+	for _, msg := range s.Messages {
+		if msg.Type == "enum" {
+			refs[string(msg.Name)] = []string{}
+		}
+	}
+
+	add := func(location string, t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type != T_Struct || vt.Struct == nil {
+				return true
+			}
+			locations, isEnum := refs[vt.Struct.Name]
+			if !isEnum || (len(locations) > 0 && locations[len(locations)-1] == location) {
+				return true
+			}
+			refs[vt.Struct.Name] = append(locations, location)
+			return true
+		})
+	}
+
+	for _, msg := range s.Messages {
+		for _, field := range msg.Fields {
+			add(string(msg.Name), field.Type)
+		}
+	}
+	for _, svc := range s.Services {
+		for _, method := range svc.Methods {
+			location := fmt.Sprintf("%s.%s", svc.Name, method.Name)
+			for _, input := range method.Inputs {
+				add(location, input.Type)
+			}
+			for _, output := range method.Outputs {
+				add(location, output.Type)
+			}
+		}
+	}
+
+	return refs
+}
+
+// Subset returns a new schema with only the named root messages and services,
+// plus the messages they reference transitively. Messages keep their original
+// declaration order, and aliases are kept only when every message they
+// reference is part of the subset. Constants are all kept, as field defaults
+// referencing them are resolved again when the subset is validated. The
+// subset holds copies of the definitions, with struct references pointing at
+// the subset's messages, so the original schema is left untouched.
+func (s *WebRPCSchema) Subset(roots []string) (*WebRPCSchema, error) {
+	keep := map[string]bool{}
+	services := map[string]bool{}
+
+	var visitMessage func(name string)
+	visitType := func(t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type == T_Struct && vt.Struct != nil {
+				visitMessage(vt.Struct.Name)
+			}
+			return true
+		})
+	}
+	visitMessage = func(name string) {
+		if keep[name] {
+			return
+		}
+		keep[name] = true
+		msg := s.GetMessageByName(name)
+		if msg == nil {
+			return
+		}
+		for _, field := range msg.Fields {
+			visitType(field.Type)
+		}
+	}
+
+	for _, root := range roots {
+		if msg := s.GetMessageByName(root); msg != nil {
+			visitMessage(string(msg.Name))
+			continue
+		}
+		if svc := s.GetServiceByName(root); svc != nil {
+			services[string(svc.Name)] = true
+			for _, method := range svc.Methods {
+				for _, input := range method.Inputs {
+					visitType(input.Type)
+				}
+				for _, output := range method.Outputs {
+					visitType(output.Type)
+				}
+			}
+			continue
+		}
+		return nil, fmt.Errorf("schema error: unknown message or service '%s'", root)
+	}
+
+	subset := &WebRPCSchema{
+		WebrpcVersion: s.WebrpcVersion,
+		SchemaName:    s.SchemaName,
+		SchemaVersion: s.SchemaVersion,
+		Imports:       s.Imports,
+		Constants:     []*Constant{},
+		Aliases:       []*Alias{},
+		Messages:      []*Message{},
+		Services:      []*Service{},
+		ParseOptions:  s.ParseOptions,
+	}
+
+	// everything is copied, so that validating the subset doesn't re-resolve
+	// the types and back-references of the original schema
+	for _, constant := range s.Constants {
+		c := *constant
+		subset.Constants = append(subset.Constants, &c)
+	}
+
+	copies := map[*Message]*Message{}
+	for _, msg := range s.Messages {
+		if keep[string(msg.Name)] {
+			c := *msg
+			copies[msg] = &c
+			subset.Messages = append(subset.Messages, &c)
+		}
+	}
+	for _, msg := range subset.Messages {
+		msg.EnumType = msg.EnumType.clone(copies)
+		fields := make([]*MessageField, 0, len(msg.Fields))
+		for _, field := range msg.Fields {
+			f := *field
+			f.Type = field.Type.clone(copies)
+			fields = append(fields, &f)
+		}
+		msg.Fields = fields
+	}
+
+	for _, alias := range s.Aliases {
+		reachable := true
+		alias.Type.Walk(func(vt *VarType) bool {
+			if vt.Type == T_Struct && vt.Struct != nil && !keep[vt.Struct.Name] {
+				reachable = false
+			}
+			return reachable
+		})
+		if reachable {
+			a := *alias
+			a.Type = alias.Type.clone(copies)
+			subset.Aliases = append(subset.Aliases, &a)
+		}
+	}
+
+	cloneArgs := func(args []*MethodArgument) []*MethodArgument {
+		out := make([]*MethodArgument, 0, len(args))
+		for _, arg := range args {
+			a := *arg
+			a.Type = arg.Type.clone(copies)
+			out = append(out, &a)
+		}
+		return out
+	}
+	for _, svc := range s.Services {
+		if !services[string(svc.Name)] {
+			continue
+		}
+		c := &Service{Name: svc.Name, Schema: subset}
+		for _, method := range svc.Methods {
+			m := *method
+			m.Inputs = cloneArgs(method.Inputs)
+			m.Outputs = cloneArgs(method.Outputs)
+			m.Service = c
+			c.Methods = append(c.Methods, &m)
+		}
+		subset.Services = append(subset.Services, c)
+	}
+
+	return subset, nil
+}
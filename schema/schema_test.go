@@ -1,7 +1,10 @@
 package schema
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -192,3 +195,1577 @@ func TestFoo(t *testing.T) {
 	assert.True(t, IsValidArgName("a55_____cdDDDD"))
 	assert.False(t, IsValidArgName("asSS_E_##$"))
 }
+
+func TestEnumValues(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Color",
+				"type": "enum",
+				"fields": [
+					{ "name": "RED", "type": "uint32", "value": "1" },
+					{ "name": "GREEN", "type": "uint32", "value": "5" },
+					{ "name": "TEAL", "type": "uint32" },
+					{ "name": "BLUE", "type": "uint32", "value": "0x0A" },
+					{ "name": "NAVY", "type": "uint32" }
+				]
+			},
+			{
+				"name": "Kind",
+				"type": "enum",
+				"fields": [
+					{ "name": "USER", "type": "int8" },
+					{ "name": "ADMIN", "type": "int8" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	values := []string{}
+	for _, field := range s.GetMessageByName("Color").Fields {
+		values = append(values, field.Value)
+	}
+	assert.Equal(t, []string{"1", "5", "6", "10", "11"}, values)
+
+	kind := s.GetMessageByName("Kind").Fields
+	assert.Equal(t, "0", kind[0].Value)
+	assert.Equal(t, "1", kind[1].Value)
+
+	for fields, expected := range map[string]string{
+		`{ "name": "RED", "type": "uint32", "value": "1" }, { "name": "GREEN", "type": "uint32", "value": "0x01" }`:                                    "schema error: enum message 'Color' fields 'RED' and 'GREEN' have the same value 1",
+		`{ "name": "RED", "type": "uint32", "value": "2" }, { "name": "GREEN", "type": "uint32", "value": "1" }, { "name": "BLUE", "type": "uint32" }`: "schema error: enum message 'Color' fields 'RED' and 'BLUE' have the same value 2",
+		`{ "name": "RED", "type": "uint8", "value": "255" }, { "name": "GREEN", "type": "uint8" }`:                                                     "schema error: enum message 'Color' field 'GREEN' has invalid value: integer literal '256' is out of range for uint8",
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "Color", "type": "enum", "fields": [` + fields + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.EqualError(t, err, expected, fields)
+	}
+}
+
+func TestMessageFieldJSONName(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "userID", "type": "uint64", "meta": [{ "json": "user_id" }] },
+					{ "name": "username", "type": "string" },
+					{ "name": "createdAt", "type": "timestamp", "meta": [{ "json": "created_at,omitempty" }] },
+					{ "name": "password", "type": "string", "meta": [{ "json": "-" }] }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, "user_id", fields[0].JSONName)
+	assert.Equal(t, "user_id", fields[0].WireName())
+	assert.Equal(t, "", fields[1].JSONName)
+	assert.Equal(t, "username", fields[1].WireName())
+	assert.Equal(t, "created_at", fields[2].WireName())
+	assert.Equal(t, []string{"omitempty"}, fields[2].JSONOptions)
+	assert.True(t, fields[3].JSONOmitted)
+	assert.False(t, fields[2].JSONOmitted)
+
+	// omitted fields aren't part of the wire form
+	ts := s.GetMessageByName("User").ToTSInterface()
+	assert.NotContains(t, ts, "password")
+	assert.NoError(t, (&VarType{Type: T_Struct, Expr: "User", Struct: &VarStructType{Name: "User", Message: s.GetMessageByName("User")}}).ValidateValue(map[string]interface{}{
+		"user_id": 1.0, "username": "a", "created_at": "2020-01-01T00:00:00Z",
+	}))
+
+	for _, name := range []string{`""`, `"user id"`, `"1st"`, `42`, `",omitempty"`, `"user id,omitempty"`} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [
+				{
+					"name": "User",
+					"type": "struct",
+					"fields": [{ "name": "userID", "type": "uint64", "meta": [{ "json": ` + name + ` }] }]
+				}
+			]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseSchemaJSONExamples(t *testing.T) {
+	// the shipped examples use "json": "-" and "name,omitempty" metas
+	for _, path := range []string{
+		"../_examples/golang-nodejs/example.webrpc.json",
+		"../_examples/golang-basics/example.webrpc.json",
+	} {
+		input, err := os.ReadFile(path)
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		_, err = ParseSchemaJSON(input)
+		assert.NoError(t, err, path)
+	}
+}
+
+func TestMessageFieldFormat(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "email", "type": "string", "meta": [{ "format": "email" }] },
+					{ "name": "homepage", "type": "string?", "meta": [{ "format": "uri" }] },
+					{ "name": "username", "type": "string" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, "email", fields[0].Format)
+	assert.Equal(t, "uri", fields[1].Format)
+	assert.Equal(t, "", fields[2].Format)
+
+	for _, field := range []string{
+		`{ "name": "email", "type": "string", "meta": [{ "format": "telephone" }] }`,
+		`{ "name": "email", "type": "string", "meta": [{ "format": 1 }] }`,
+		`{ "name": "id", "type": "uint64", "meta": [{ "format": "email" }] }`,
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [` + field + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.Error(t, err, field)
+	}
+}
+
+func TestMessageFieldPattern(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "username", "type": "string", "meta": [{ "pattern": "^[a-z]+$" }] },
+					{ "name": "email", "type": "string" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, "^[a-z]+$", fields[0].Pattern)
+	assert.Equal(t, "", fields[1].Pattern)
+
+	for field, expected := range map[string]string{
+		`{ "name": "username", "type": "string", "meta": [{ "pattern": "^[a-z+$" }] }`: "schema error: invalid pattern '^[a-z+$' for field 'username' in message 'User': error parsing regexp: missing closing ]: `[a-z+$`",
+		`{ "name": "username", "type": "string", "meta": [{ "pattern": 1 }] }`:         "schema error: invalid pattern '1' for field 'username' in message 'User'",
+		`{ "name": "id", "type": "uint64", "meta": [{ "pattern": "^[0-9]+$" }] }`:      "schema error: pattern '^[0-9]+$' for field 'id' in message 'User' is only valid on a string field",
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [` + field + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.EqualError(t, err, expected, field)
+	}
+}
+
+func TestMessageFieldRequiredIf(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Address",
+				"type": "struct",
+				"fields": [
+					{ "name": "country", "type": "string?" },
+					{ "name": "state", "type": "string?", "meta": [{ "requiredIf": "country" }] }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("Address").Fields
+	assert.Equal(t, "", fields[0].RequiredIf)
+	assert.Equal(t, "country", fields[1].RequiredIf)
+	assert.Equal(t, "string?", fields[1].Type.String())
+
+	for field, expected := range map[string]string{
+		`{ "name": "state", "type": "string?", "meta": [{ "requiredIf": "zip" }] }`:                          "schema error: requiredIf of field 'state' in message 'Address' references unknown field 'zip'",
+		`{ "name": "state", "type": "string?", "meta": [{ "requiredIf": "state" }] }`:                        "schema error: requiredIf of field 'state' in message 'Address' references unknown field 'state'",
+		`{ "name": "state", "type": "string?", "meta": [{ "requiredIf": 1 }] }`:                              "schema error: invalid requiredIf field '1' for field 'state' in message 'Address'",
+		`{ "name": "state", "type": "string", "meta": [{ "required": true }, { "requiredIf": "country" }] }`: "schema error: field 'state' in message 'Address' cannot be both required and requiredIf",
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "Address", "type": "struct", "fields": [{ "name": "country", "type": "string?" }, ` + field + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.EqualError(t, err, expected, field)
+	}
+}
+
+func TestMessageFieldLength(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "username", "type": "string", "meta": [{ "minLength": 3 }, { "maxLength": "16" }] },
+					{ "name": "tags", "type": "[]string?", "meta": [{ "maxLength": 2 }] },
+					{ "name": "code", "type": "string", "meta": [{ "minLength": 2 }, { "maxLength": 2 }], "examples": ["DE", "ü1"] },
+					{ "name": "bio", "type": "string" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, 3, *fields[0].MinLength)
+	assert.Equal(t, 16, *fields[0].MaxLength)
+	assert.Nil(t, fields[1].MinLength)
+	assert.Equal(t, 2, *fields[1].MaxLength)
+	assert.Nil(t, fields[3].MinLength)
+	assert.Nil(t, fields[3].MaxLength)
+
+	assert.NoError(t, fields[0].ValidateValue("alice"))
+	assert.EqualError(t, fields[0].ValidateValue("al"), "length 2 is less than the minLength 3")
+	assert.EqualError(t, fields[0].ValidateValue("abcdefghijklmnopq"), "length 17 is greater than the maxLength 16")
+	assert.EqualError(t, fields[1].ValidateValue([]interface{}{"a", "b", "c"}), "length 3 is greater than the maxLength 2")
+	assert.NoError(t, fields[1].ValidateValue([]interface{}{"a", nil}))
+
+	user := &VarType{Expr: "User"}
+	assert.NoError(t, user.Parse(s))
+	err = user.ValidateValue(map[string]interface{}{"username": "al", "tags": []interface{}{}, "code": "DE", "bio": ""})
+	assert.EqualError(t, err, "username: length 2 is less than the minLength 3")
+
+	for field, expected := range map[string]string{
+		`{ "name": "name", "type": "string", "meta": [{ "minLength": 5 }, { "maxLength": 3 }] }`:  "schema error: minLength 5 is greater than maxLength 3 for field 'name' in message 'User'",
+		`{ "name": "name", "type": "string", "meta": [{ "minLength": -1 }] }`:                     "schema error: invalid minLength '-1' for field 'name' in message 'User', must be a non-negative integer",
+		`{ "name": "name", "type": "string", "meta": [{ "maxLength": 1.5 }] }`:                    "schema error: invalid maxLength '1.5' for field 'name' in message 'User', must be a non-negative integer",
+		`{ "name": "id", "type": "uint64", "meta": [{ "maxLength": 3 }] }`:                        "schema error: minLength and maxLength for field 'id' in message 'User' are only valid on a string or list field",
+		`{ "name": "code", "type": "string", "meta": [{ "maxLength": 2 }], "examples": ["DEU"] }`: "schema error: example 1 for field 'code' in message 'User' is invalid: length 3 is greater than the maxLength 2",
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [` + field + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.EqualError(t, err, expected, field)
+	}
+}
+
+func TestMessageFieldRequired(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64", "meta": [{ "required": true }] },
+					{ "name": "username", "type": "string", "meta": [{ "required": "true" }] },
+					{ "name": "email", "type": "string" },
+					{ "name": "nickname", "type": "string", "optional": true },
+					{ "name": "bio", "type": "string?" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("User").Fields
+	assert.True(t, fields[0].Required)
+	assert.True(t, fields[0].IsRequired())
+	assert.True(t, fields[1].Required)
+	assert.True(t, fields[1].IsRequired())
+	assert.False(t, fields[2].Required)
+	assert.True(t, fields[2].IsRequired())
+	assert.False(t, fields[3].IsRequired())
+	assert.False(t, fields[4].IsRequired())
+
+	for _, field := range []string{
+		`{ "name": "bio", "type": "string?", "meta": [{ "required": true }] }`,
+		`{ "name": "bio", "type": "string", "optional": true, "meta": [{ "required": true }] }`,
+		`{ "name": "bio", "type": "string", "meta": [{ "required": "yes" }] }`,
+	} {
+		input := `{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [` + field + `] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		assert.Error(t, err, field)
+	}
+}
+
+func TestMessageFieldDefaultSignedness(t *testing.T) {
+	parse := func(fieldType string) (*WebRPCSchema, error) {
+		return ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "Query", "type": "struct", "fields": [
+				{ "name": "offset", "type": "` + fieldType + `", "meta": [{ "default": "-1" }] }
+			] }]
+		}`))
+	}
+
+	s, err := parse("int32")
+	assert.NoError(t, err)
+	assert.Equal(t, "-1", s.GetMessageByName("Query").Fields[0].Default)
+
+	_, err = parse("uint32")
+	assert.EqualError(t, err, "schema error: invalid default for field 'offset' in message 'Query': integer literal '-1' is negative, but uint32 is unsigned")
+}
+
+func TestMessageFieldDefaultNumbers(t *testing.T) {
+	parse := func(fieldType, value string) (*WebRPCSchema, error) {
+		return ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "Query", "type": "struct", "fields": [
+				{ "name": "limit", "type": "` + fieldType + `", "meta": [{ "default": ` + value + ` }] }
+			] }]
+		}`))
+	}
+
+	tt := []struct {
+		Type    string
+		Value   string
+		Default string
+	}{
+		{"uint64", "10000000", "10000000"},
+		{"uint64", "18446744073709551615", "18446744073709551615"},
+		{"int64", "-9007199254740993", "-9007199254740993"},
+		{"float64", "0.25", "0.25"},
+	}
+	for _, tc := range tt {
+		s, err := parse(tc.Type, tc.Value)
+		if assert.NoError(t, err, tc.Value) {
+			assert.Equal(t, tc.Default, s.GetMessageByName("Query").Fields[0].Default)
+		}
+	}
+
+	_, err := parse("uint32", "1.5")
+	assert.EqualError(t, err, "schema error: invalid default for field 'limit' in message 'Query': invalid integer literal '1.5'")
+
+	// programmatic float64 metas are spelled out in full too
+	field := &MessageField{Name: "limit", Meta: []MessageFieldMeta{{"default": float64(10000000)}}}
+	assert.NoError(t, field.parseMeta("Query"))
+	assert.Equal(t, "10000000", field.Default)
+}
+
+func TestMessageFieldInlineEnum(t *testing.T) {
+	parse := func(fields string) (*WebRPCSchema, error) {
+		return ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "User", "type": "struct", "fields": [` + fields + `] }]
+		}`))
+	}
+
+	s, err := parse(`{ "name": "status", "type": "enum<active | inactive | banned>" },
+		{ "name": "role", "type": "enum<ADMIN|MEMBER>?" }`)
+	assert.NoError(t, err)
+
+	status := s.GetMessageByName("UserStatus")
+	if assert.NotNil(t, status) {
+		assert.Equal(t, MessageType("enum"), status.Type)
+		assert.Equal(t, T_Uint32, status.EnumType.Type)
+		if assert.Equal(t, 3, len(status.Fields)) {
+			assert.Equal(t, VarName("active"), status.Fields[0].Name)
+			assert.Equal(t, "0", status.Fields[0].Value)
+			assert.Equal(t, VarName("banned"), status.Fields[2].Name)
+			assert.Equal(t, "2", status.Fields[2].Value)
+		}
+	}
+	assert.NotNil(t, s.GetMessageByName("UserRole"))
+
+	fields := s.GetMessageByName("User").Fields
+	assert.Equal(t, "UserStatus", fields[0].Type.Expr)
+	assert.Equal(t, status, fields[0].Type.Struct.Message)
+	assert.Equal(t, "UserRole?", fields[1].Type.Expr)
+	assert.True(t, fields[1].Type.Optional)
+
+	// the field now references the registered enum, so validation is repeatable
+	assert.NoError(t, s.Validate())
+	assert.Equal(t, 3, len(s.Messages))
+
+	tt := []struct {
+		Fields string
+		Error  string
+	}{
+		{
+			`{ "name": "status", "type": "enum<>" }`,
+			"schema error: invalid inline enum syntax for 'enum<>', expecting enum<A|B>",
+		},
+		{
+			`{ "name": "status", "type": "enum<active|inactive|>" }`,
+			"schema error: invalid inline enum syntax for 'enum<active|inactive|>', expecting enum<A|B>",
+		},
+		{
+			`{ "name": "status", "type": "enum<active|active>" }`,
+			"schema error: detected duplicate field name of 'active' in message 'UserStatus'",
+		},
+		{
+			`{ "name": "status", "type": "[]enum<active|inactive>" }`,
+			"schema error: inline enum in '[]enum<active|inactive>' is only valid as a message field type, ie. enum<A|B> or enum<A|B>?",
+		},
+		{
+			`{ "name": "status", "type": "map<enum<active|inactive>,string>" }`,
+			"schema error: inline enum 'enum<active|inactive>' is not a valid map key for 'map<enum<active|inactive>,string>', as enums aren't valid map keys",
+		},
+	}
+	for _, tc := range tt {
+		_, err := parse(tc.Fields)
+		assert.EqualError(t, err, tc.Error, tc.Fields)
+	}
+
+	// the enum name must be free in every type namespace
+	collisions := []struct {
+		Defs     string
+		Messages string
+		Kind     string
+	}{
+		{`"aliases": [{ "name": "UserStatus", "type": "string" }],`, "", "an alias"},
+		{`"constants": [{ "name": "USERSTATUS", "value": "1" }],`, "", "a constant"},
+		{"", `{ "name": "userStatus", "type": "struct", "fields": [] },`, "a message"},
+	}
+	for _, tc := range collisions {
+		_, err := ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			` + tc.Defs + `
+			"messages": [` + tc.Messages + `{ "name": "User", "type": "struct", "fields": [{ "name": "status", "type": "enum<active|inactive>" }] }]
+		}`))
+		assert.EqualError(t, err, "schema error: inline enum 'enum<active|inactive>' of field 'status' in message 'User' is named 'UserStatus', which is already defined as "+tc.Kind, tc.Kind)
+	}
+}
+
+func TestMessageFieldTypeBlock(t *testing.T) {
+	parse := func(fields string) (*WebRPCSchema, error) {
+		return ParseSchemaJSON([]byte(`{
+			"webrpc": "v1",
+			"messages": [{ "name": "Query", "type": "struct", "fields": [` + fields + `] }]
+		}`))
+	}
+
+	s, err := parse(`{
+		"name": "limit",
+		"type": {
+			"expr": "uint32",
+			"description": "page size",
+			"deprecated": true,
+			"default": 10,
+			"examples": [10, 25]
+		}
+	}, {
+		"name": "cursor",
+		"type": { "expr": "string?" }
+	}`)
+	assert.NoError(t, err)
+
+	fields := s.GetMessageByName("Query").Fields
+	assert.Equal(t, "uint32", fields[0].Type.Expr)
+	assert.Equal(t, "page size", fields[0].Description)
+	assert.True(t, fields[0].Deprecated)
+	assert.Equal(t, "10", fields[0].Default)
+	assert.Equal(t, []interface{}{10.0, 25.0}, fields[0].Examples)
+
+	assert.Equal(t, "string?", fields[1].Type.Expr)
+	assert.Equal(t, "", fields[1].Description)
+	assert.False(t, fields[1].Deprecated)
+	assert.Empty(t, fields[1].Examples)
+
+	// the metadata round-trips through the flat field form
+	out, err := s.ToJSON()
+	assert.NoError(t, err)
+	s, err = ParseSchemaJSON([]byte(out))
+	assert.NoError(t, err)
+	fields = s.GetMessageByName("Query").Fields
+	assert.Equal(t, "page size", fields[0].Description)
+	assert.Equal(t, "10", fields[0].Default)
+	assert.Equal(t, []interface{}{10.0, 25.0}, fields[0].Examples)
+
+	s, err = parse(`{ "name": "offset", "type": { "expr": "uint64", "default": 9007199254740993 } }`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "9007199254740993", s.GetMessageByName("Query").Fields[0].Default)
+	}
+
+	_, err = parse(`{ "name": "limit", "type": { "expr": "uint8", "examples": [10, 300] } }`)
+	assert.EqualError(t, err, "schema error: example 2 for field 'limit' in message 'Query' is invalid: integer literal '300' is out of range for uint8")
+
+	_, err = parse(`{ "name": "limit", "type": { "description": "page size" } }`)
+	assert.EqualError(t, err, "json error: type block of field 'limit' is missing its expr")
+}
+
+func TestAllTypes(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "tags", "type": "map<string,[]string>" },
+					{ "name": "friends", "type": "[]User" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "userID", "type": "uint64" }],
+						"outputs": [{ "name": "users", "type": "map< uint64, User >" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	exprs := []string{}
+	for _, vt := range s.AllTypes() {
+		exprs = append(exprs, buildVarTypeExpr(vt, "", ParseOptions{}))
+	}
+	assert.Equal(t, []string{
+		"uint64",
+		"map<string,[]string>",
+		"[]string",
+		"string",
+		"[]User",
+		"User",
+		"map<uint64,User>",
+	}, exprs)
+}
+
+func TestMapKeyTypesUsed(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "labels", "type": "map<string,string>" },
+					{ "name": "scores", "type": "map<uint64,map<string,float64>>" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUsers",
+						"inputs": [{ "name": "ids", "type": "[]uint64" }],
+						"outputs": [{ "name": "users", "type": "map<uint64,User>" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []DataType{T_String, T_Uint64}, s.MapKeyTypesUsed())
+
+	assert.Equal(t, []DataType{}, (&WebRPCSchema{}).MapKeyTypesUsed())
+}
+
+func TestMessageUsage(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Address", "type": "struct", "fields": [{ "name": "city", "type": "string" }] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "address", "type": "Address?" }] },
+			{ "name": "Filter", "type": "struct", "fields": [{ "name": "city", "type": "string" }] },
+			{ "name": "Page", "type": "struct", "fields": [{ "name": "users", "type": "[]User" }] },
+			{ "name": "Unused", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] }
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "FindUsers",
+						"inputs": [{ "name": "filter", "type": "Filter" }],
+						"outputs": [{ "name": "page", "type": "Page" }]
+					},
+					{
+						"name": "SaveUser",
+						"inputs": [{ "name": "user", "type": "User" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	tt := []struct {
+		Name          string
+		Input, Output bool
+	}{
+		{"User", true, true},
+		{"Address", true, true},
+		{"Filter", true, false},
+		{"Page", false, true},
+		{"Unused", false, false},
+		{"Missing", false, false},
+	}
+	for _, tc := range tt {
+		input, output := s.MessageUsage(tc.Name)
+		assert.Equal(t, tc.Input, input, tc.Name)
+		assert.Equal(t, tc.Output, output, tc.Name)
+	}
+}
+
+func TestRequiredPrimitives(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint8" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "tags", "type": "map<uuid,[]string?>" },
+					{ "name": "balance", "type": "result<money<USD>,string>" },
+					{ "name": "manager", "type": "User?" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUsers",
+						"inputs": [{ "name": "since", "type": "timestamp" }],
+						"outputs": [{ "name": "users", "type": "[]User" }, { "name": "ok", "type": "bool" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []DataType{T_Bool, T_Uint8, T_Uint64, T_String, T_Timestamp, T_UUID, T_Money}, s.RequiredPrimitives())
+}
+
+func TestFieldsOfType(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "createdAt", "type": "timestamp" },
+					{ "name": "deletedAt", "type": "timestamp?" },
+					{ "name": "manager", "type": "User?" }
+				]
+			},
+			{
+				"name": "Order",
+				"type": "struct",
+				"fields": [
+					{ "name": "placedAt", "type": "timestamp" },
+					{ "name": "customer", "type": "User" },
+					{ "name": "history", "type": "[]timestamp" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"User.createdAt",
+		"User.deletedAt",
+		"Order.placedAt",
+		"Order.customer.createdAt",
+		"Order.customer.deletedAt",
+	}, s.FieldsOfType(T_Timestamp))
+	assert.Equal(t, []string{"User.id", "Order.customer.id"}, s.FieldsOfType(T_Uint64))
+	assert.Equal(t, []string{}, s.FieldsOfType(T_UUID))
+}
+
+func TestValidateEnumMemberNames(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Color", "type": "enum", "fields": [
+				{ "name": "RED", "type": "uint32" },
+				{ "name": "GREEN", "type": "uint32" }
+			] },
+			{ "name": "Status", "type": "enum", "fields": [
+				{ "name": "ACTIVE", "type": "uint32" },
+				{ "name": "RED", "type": "uint32" }
+			] },
+			{ "name": "Alert", "type": "enum", "fields": [
+				{ "name": "GREEN", "type": "uint8" },
+				{ "name": "RED", "type": "uint8" }
+			] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "ACTIVE", "type": "bool" }] }
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	err = s.ValidateEnumMemberNames()
+	assert.EqualError(t, err, "schema error: 3 problems found: "+
+		"enum member 'RED' of 'Status' collides with 'Color.RED'; "+
+		"enum member 'GREEN' of 'Alert' collides with 'Color.GREEN'; "+
+		"enum member 'RED' of 'Alert' collides with 'Color.RED'")
+
+	s.Messages = s.Messages[:1]
+	assert.NoError(t, s.ValidateEnumMemberNames())
+}
+
+func TestValidateTypeNames(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"aliases": [
+			{ "name": "ID", "type": "uint64" },
+			{ "name": "user", "type": "string" }
+		],
+		"messages": [
+			{ "name": "Color", "type": "enum", "fields": [{ "name": "RED", "type": "uint32" }] },
+			{ "name": "Color", "type": "struct", "fields": [{ "name": "hex", "type": "string" }] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "ID" }] }
+		]
+	}`
+
+	_, err := ParseSchemaJSON([]byte(input))
+	assert.EqualError(t, err, "schema error: 2 problems found: "+
+		"type name 'Color' is defined as both an enum and a message; "+
+		"type name 'User' is defined as both an alias and a message")
+
+	s := &WebRPCSchema{
+		WebrpcVersion: VERSION,
+		Aliases:       []*Alias{{Name: "ID", Type: &VarType{Expr: "uint64"}}},
+		Messages:      []*Message{{Name: "User", Type: "struct"}},
+	}
+	assert.NoError(t, s.ValidateTypeNames())
+}
+
+func TestNormalizeIntegers(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"aliases": [{ "name": "ID", "type": "uint" }],
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "int" }, { "name": "ADMIN", "type": "int" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "ID" },
+					{ "name": "ids", "type": "[]int" },
+					{ "name": "scores", "type": "map<int,[]uint32>" },
+					{ "name": "age", "type": "int8" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [{ "name": "Count", "inputs": [], "outputs": [{ "name": "counts", "type": "result<int,string>" }] }]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	expected := []IntegerChange{
+		{"alias 'ID'", "uint", "uint64"},
+		{"enum 'Kind'", "int", "int64"},
+		{"message 'User' field 'id'", "uint", "uint64"},
+		{"message 'User' field 'ids'", "[]int", "[]int64"},
+		{"message 'User' field 'scores'", "map<int,[]uint32>", "map<int64,[]uint32>"},
+		{"service 'UserService' method 'Count' output 'counts'", "result<int,string>", "result<int64,string>"},
+	}
+	assert.Equal(t, expected, s.NormalizeIntegersDryRun())
+
+	user := s.GetMessageByName("User")
+	assert.Equal(t, "[]int", user.Fields[1].Type.Expr)
+	assert.Equal(t, T_Int, user.Fields[1].Type.List.Elem.Type)
+
+	assert.Equal(t, expected, s.NormalizeIntegers())
+	ids := user.Fields[1].Type
+	assert.Equal(t, "[]int64", ids.Expr)
+	assert.Equal(t, T_Int64, ids.List.Elem.Type)
+	assert.Equal(t, "int64", ids.List.Elem.Expr)
+	assert.Equal(t, "[]int64", ids.GoType())
+	assert.Equal(t, T_Int64, user.Fields[2].Type.Map.Key)
+	assert.Equal(t, "int8", user.Fields[3].Type.Expr)
+
+	kind := s.GetMessageByName("Kind")
+	assert.Equal(t, "int64", kind.EnumType.Expr)
+	assert.Equal(t, "int64", kind.Fields[1].Type.Expr)
+
+	assert.Empty(t, s.NormalizeIntegers())
+	assert.NoError(t, s.Validate())
+
+	// alias references keep their expr, so only the alias is reported
+	s = &WebRPCSchema{}
+	assert.NoError(t, json.Unmarshal([]byte(input), s))
+	s.ParseOptions = ParseOptions{PreserveAliases: true}
+	assert.NoError(t, s.Validate())
+	assert.Equal(t, "ID", s.GetMessageByName("User").Fields[0].Type.Expr)
+	changes := s.NormalizeIntegers()
+	assert.Equal(t, expected[0], changes[0])
+	for _, change := range changes {
+		assert.NotEqual(t, change.From, change.To)
+		assert.NotEqual(t, "message 'User' field 'id'", change.Location)
+	}
+	id := s.GetMessageByName("User").Fields[0].Type
+	assert.Equal(t, "ID", id.Expr)
+	assert.Equal(t, T_Uint64, id.Type)
+}
+
+func TestOptionalFields(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "nickname", "type": "string?" },
+					{ "name": "email", "type": "string", "optional": true },
+					{ "name": "tags", "type": "[]string?" },
+					{ "name": "deletedAt", "type": "nullable<timestamp>" }
+				]
+			},
+			{
+				"name": "Page",
+				"type": "struct",
+				"fields": [
+					{ "name": "users", "type": "[]User" },
+					{ "name": "next", "type": "optional<map<string,string>>" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"User.nickname",
+		"User.email",
+		"User.deletedAt",
+		"Page.next",
+	}, s.OptionalFields())
+}
+
+func TestBigNumberFields(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Token",
+				"type": "struct",
+				"fields": [
+					{ "name": "supply", "type": "bigint" },
+					{ "name": "symbol", "type": "string" }
+				]
+			},
+			{
+				"name": "Invoice",
+				"type": "struct",
+				"fields": [
+					{ "name": "total", "type": "decimal<10,2>" },
+					{ "name": "lines", "type": "[]decimal?" },
+					{ "name": "token", "type": "Token?" },
+					{ "name": "tokens", "type": "[]Token" },
+					{ "name": "count", "type": "uint32" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"Token.supply",
+		"Invoice.total",
+		"Invoice.lines",
+		"Invoice.token.supply",
+	}, s.BigNumberFields())
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	a := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "tags", "type": "map<string,[]string>" },
+					{ "name": "profile", "type": "Profile?" }
+				]
+			},
+			{
+				"name": "Profile",
+				"type": "struct",
+				"fields": [{ "name": "bio", "type": "string" }]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{ "name": "Ping", "inputs": [], "outputs": [] },
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "userID", "type": "uint64" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	b := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "Profile",
+				"type": "struct",
+				"fields": [{ "name": "bio", "type": "string" }]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "ID", "type": "uint64" },
+					{ "name": "tags", "type": "map< string, []string >" },
+					{ "name": "profile", "type": "optional<Profile>" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "userID", "type": "uint64" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					},
+					{ "name": "Ping", "inputs": [], "outputs": [] }
+				]
+			}
+		]
+	}`
+
+	sa, err := ParseSchemaJSON([]byte(a))
+	assert.NoError(t, err)
+	sb, err := ParseSchemaJSON([]byte(b))
+	assert.NoError(t, err)
+
+	ja, err := sa.CanonicalJSON()
+	assert.NoError(t, err)
+	jb, err := sb.CanonicalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(ja), string(jb))
+
+	// source schema is left untouched
+	assert.Equal(t, "User", string(sa.Messages[0].Name))
+
+	// field order is preserved
+	assert.Contains(t, string(ja), `"name": "ID"`)
+	assert.Less(t, strings.Index(string(ja), `"name": "tags"`), strings.Index(string(ja), `"name": "profile"`))
+}
+
+func TestCanonicalJSONDefaultSyntax(t *testing.T) {
+	s := newTestSchema("User")
+	s.ParseOptions = ParseOptions{ListKeyword: "array", MapKeyword: "dict", OptionalKeyword: true}
+	vt := &VarType{Expr: "dict<string,array<User>>?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "optional<dict<string,array<User>>>", vt.Expr)
+	s.Messages[0].Fields = []*MessageField{{Name: "friends", Type: vt}}
+
+	// the options aren't serialized, so exprs are in the default syntax
+	out, err := s.CanonicalJSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"map<string,[]User>?"`)
+
+	parsed, err := ParseSchemaJSON(out)
+	assert.NoError(t, err)
+	assert.Equal(t, T_Map, parsed.Messages[0].Fields[0].Type.Type)
+}
+
+func TestReparseMessage(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "Address",
+				"type": "struct",
+				"fields": [{ "name": "zip", "type": "string" }]
+			},
+			{
+				"name": "Profile",
+				"type": "struct",
+				"fields": [{ "name": "bio", "type": "string" }]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "addresses", "type": "map<string,[]Address?>" },
+					{ "name": "profile", "type": "Profile" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetAddress",
+						"inputs": [],
+						"outputs": [{ "name": "address", "type": "Address" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	user := s.GetMessageByName("User")
+	profileRef := user.Fields[1].Type.Struct
+
+	// replace the Address definition
+	address := &Message{
+		Name:   "Address",
+		Type:   "struct",
+		Fields: []*MessageField{{Name: "zip", Type: &VarType{Expr: "uint32"}}},
+	}
+	s.Messages[0] = address
+
+	assert.NoError(t, s.ReparseMessage("Address"))
+	assert.Equal(t, T_Uint32, address.Fields[0].Type.Type)
+
+	// dependents point to the new definition
+	assert.Same(t, address, user.Fields[0].Type.Map.Value.List.Elem.Struct.Message)
+	assert.Equal(t, "map<string,[]Address?>", user.Fields[0].Type.Expr)
+	assert.Same(t, address, s.Services[0].Methods[0].Outputs[0].Type.Struct.Message)
+
+	// unrelated types are not re-resolved
+	assert.Same(t, profileRef, user.Fields[1].Type.Struct)
+
+	assert.Error(t, s.ReparseMessage("Unknown"))
+}
+
+func TestVoidMethodArguments(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [],
+		"services": [
+			{
+				"name": "PingService",
+				"methods": [
+					{
+						"name": "Ping",
+						"inputs": [{ "name": "", "type": "void" }],
+						"outputs": [{ "name": "", "type": "void" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(s.Services[0].Methods[0].Inputs))
+	assert.Equal(t, 0, len(s.Services[0].Methods[0].Outputs))
+
+	// void must be the only argument
+	mixed := strings.Replace(input, `"outputs": [{ "name": "", "type": "void" }]`, `"outputs": [{ "name": "", "type": "void" }, { "name": "ok", "type": "bool" }]`, 1)
+	_, err = ParseSchemaJSON([]byte(mixed))
+	assert.Error(t, err)
+
+	// void is not a valid field type
+	field := strings.Replace(input, `"messages": []`, `"messages": [{ "name": "Empty", "type": "struct", "fields": [{ "name": "v", "type": "void" }] }]`, 1)
+	_, err = ParseSchemaJSON([]byte(field))
+	assert.Error(t, err)
+
+	_, err = ParseSchemaJSON([]byte(strings.Replace(input, `"type": "void"`, `"type": "[]void"`, 1)))
+	assert.Error(t, err)
+}
+
+func TestMethodResolveTypes(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] }],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "id", "type": "uint64" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	method := s.Services[0].Methods[0]
+	assert.NoError(t, method.ResolveTypes(s))
+
+	method.Inputs[0].Type = &VarType{Expr: "Account"}
+	method.Outputs[0].Type = &VarType{Expr: "map<User,bool>"}
+	err = method.ResolveTypes(s)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "method 'UserService.GetUser'")
+		assert.Contains(t, err.Error(), "input 'id'")
+		assert.Contains(t, err.Error(), "output 'user'")
+	}
+}
+
+func TestMethodTransitiveTypes(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Unused", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] },
+			{ "name": "Address", "type": "struct", "fields": [{ "name": "city", "type": "string" }] },
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "addresses", "type": "map<string,[]Address?>" },
+					{ "name": "manager", "type": "User?" }
+				]
+			},
+			{ "name": "Filter", "type": "struct", "fields": [{ "name": "users", "type": "[]User" }] },
+			{ "name": "Page", "type": "struct", "fields": [{ "name": "size", "type": "uint32" }] }
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "FindUsers",
+						"inputs": [{ "name": "filter", "type": "Filter" }],
+						"outputs": [{ "name": "page", "type": "Page" }, { "name": "count", "type": "uint64" }]
+					},
+					{ "name": "Ping", "inputs": [], "outputs": [] }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	names := func(messages []*Message) []string {
+		out := []string{}
+		for _, msg := range messages {
+			out = append(out, string(msg.Name))
+		}
+		return out
+	}
+
+	methods := s.Services[0].Methods
+	assert.Equal(t, []string{"Address", "Kind", "User", "Filter", "Page"}, names(methods[0].TransitiveTypes(s)))
+	assert.Equal(t, []string{}, names(methods[1].TransitiveTypes(s)))
+}
+
+func TestReferenceCounts(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "Address",
+				"type": "struct",
+				"fields": [{ "name": "zip", "type": "string" }]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "home", "type": "Address" },
+					{ "name": "other", "type": "map<string,result<Address,[]Address>>" }
+				]
+			},
+			{
+				"name": "Company",
+				"type": "struct",
+				"fields": [{ "name": "offices", "type": "[]Address" }]
+			},
+			{
+				"name": "Order",
+				"type": "struct",
+				"fields": [{ "name": "shipTo", "type": "Address?" }]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]int{
+		"Address": 4,
+		"User":    1,
+		"Company": 0,
+		"Order":   0,
+	}, s.ReferenceCounts())
+}
+
+func TestSubset(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"constants": [{ "name": "MAX_ADDRESSES", "value": "10" }],
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{ "name": "Address", "type": "struct", "fields": [{ "name": "zip", "type": "string" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "addresses", "type": "map<string,[]Address>" },
+					{ "name": "maxAddresses", "type": "uint32", "meta": [{ "default": "MAX_ADDRESSES" }] }
+				]
+			},
+			{ "name": "Unused", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] },
+			{ "name": "Token", "type": "struct", "fields": [{ "name": "value", "type": "string" }] }
+		],
+		"services": [
+			{
+				"name": "AuthService",
+				"methods": [
+					{
+						"name": "Login",
+						"inputs": [{ "name": "user", "type": "string" }],
+						"outputs": [{ "name": "token", "type": "Token" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	subset, err := s.Subset([]string{"User"})
+	assert.NoError(t, err)
+	names := []string{}
+	for _, msg := range subset.Messages {
+		names = append(names, string(msg.Name))
+	}
+	assert.Equal(t, []string{"Kind", "Address", "User"}, names)
+	assert.Equal(t, 0, len(subset.Services))
+	assert.Equal(t, s.Constants, subset.Constants)
+	assert.NoError(t, subset.Validate())
+	assert.Equal(t, "10", subset.GetMessageByName("User").Fields[2].Default)
+
+	// the subset's types point at its own messages
+	user := subset.GetMessageByName("User")
+	assert.True(t, user != s.GetMessageByName("User"))
+	assert.True(t, user.Fields[0].Type.Struct.Message == subset.GetMessageByName("Kind"))
+	assert.True(t, s.GetMessageByName("User").Fields[0].Type.Struct.Message == s.GetMessageByName("Kind"))
+
+	subset, err = s.Subset([]string{"AuthService"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(subset.Messages))
+	assert.Equal(t, "Token", string(subset.Messages[0].Name))
+	assert.Equal(t, 1, len(subset.Services))
+
+	// validating the subset leaves the original back-references alone
+	assert.NoError(t, subset.Validate())
+	assert.True(t, subset.Services[0].Schema == subset)
+	assert.True(t, subset.Services[0].Methods[0].Service == subset.Services[0])
+	assert.True(t, s.Services[0].Schema == s)
+	assert.True(t, s.Services[0].Methods[0].Service == s.Services[0])
+	assert.True(t, s.Services[0].Methods[0].Outputs[0].Type.Struct.Message == s.GetMessageByName("Token"))
+
+	// source schema is untouched
+	assert.Equal(t, 5, len(s.Messages))
+
+	_, err = s.Subset([]string{"Missing"})
+	assert.Error(t, err)
+}
+
+func TestEnumReferences(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{ "name": "Status", "type": "enum", "fields": [{ "name": "ACTIVE", "type": "uint32", "value": "0" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "previousKinds", "type": "[]Kind" }
+				]
+			},
+			{ "name": "Group", "type": "struct", "fields": [{ "name": "kinds", "type": "map<string,Kind>" }] }
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "FindUsers",
+						"inputs": [{ "name": "kind", "type": "Kind" }],
+						"outputs": [{ "name": "users", "type": "[]User" }, { "name": "kind", "type": "Kind?" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	refs := s.EnumReferences()
+	assert.Equal(t, map[string][]string{
+		"Kind":   {"User", "Group", "UserService.FindUsers"},
+		"Status": {},
+	}, refs)
+}
+
+func TestParseSchema(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"name": "example",
+		"version": "v0.0.1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "status", "type": "enum<active|banned>" },
+					{ "name": "friends", "type": "[]User" }
+				]
+			}
+		],
+		"services": [
+			{
+				"name": "UserService",
+				"methods": [
+					{
+						"name": "GetUser",
+						"inputs": [{ "name": "id", "type": "uint64" }],
+						"outputs": [{ "name": "user", "type": "User" }]
+					}
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchema(strings.NewReader(input))
+	assert.NoError(t, err)
+	user := s.GetMessageByName("User")
+	assert.Equal(t, user, s.Services[0].Methods[0].Outputs[0].Type.Struct.Message)
+	assert.Equal(t, user, user.Fields[2].Type.List.Elem.Struct.Message)
+	assert.NotNil(t, s.GetMessageByName("UserStatus"))
+
+	// problems are reported together
+	bad := strings.Replace(input, `"type": "[]User"`, `"type": "[]Account"`, 1)
+	bad = strings.Replace(bad, `"type": "User" }]`, `"type": "map<bool,User>" }]`, 1)
+	_, err = ParseSchema(strings.NewReader(bad))
+	if assert.Error(t, err) {
+		errs, ok := err.(SchemaErrors)
+		if assert.True(t, ok) {
+			assert.Equal(t, 2, len(errs))
+		}
+		assert.Contains(t, err.Error(), "schema error: 2 problems found: ")
+	}
+
+	_, err = ParseSchema(strings.NewReader(`{ "webrpc": `))
+	assert.Error(t, err)
+}
+
+func TestAssertResolved(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Team", "type": "struct", "fields": [{ "name": "name", "type": "string" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "team", "type": "Team" },
+					{ "name": "teams", "type": "map<string,[]Team?>" }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.NoError(t, s.AssertResolved())
+
+	// drop Team, as if its definition went missing after load
+	s.Messages = s.Messages[1:]
+	fields := s.GetMessageByName("User").Fields
+	fields[1].Type.Struct.Message = nil
+	fields[2].Type.Map.Value.List.Elem.Struct.Message = nil
+	s.Messages[0].Fields = append(fields, &MessageField{Name: "owner", Type: &VarType{Expr: "Owner"}})
+
+	err = s.AssertResolved()
+	assert.EqualError(t, err, "schema error: 3 problems found: "+
+		"field 'team' in message 'User' references unresolved message 'Team'; "+
+		"field 'teams' in message 'User' references unresolved message 'Team'; "+
+		"field 'owner' in message 'User' has an unparsed type 'Owner'")
+	assert.Equal(t, err.Error(), s.AssertResolved().Error())
+}
+
+func TestFieldOrder(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "zeta", "type": "string" },
+					{ "name": "alpha", "type": "uint64" },
+					{ "name": "status", "type": "enum<active|banned>" },
+					{ "name": "mid", "type": { "expr": "[]string", "description": "tags" } },
+					{ "name": "beta", "type": "timestamp?", "meta": [{ "json": "b" }] }
+				]
+			}
+		],
+		"services": [{ "name": "Users", "methods": [{ "name": "Get", "inputs": [], "outputs": [{ "name": "user", "type": "User" }] }] }]
+	}`
+	order := []string{"zeta", "alpha", "status", "mid", "beta"}
+
+	fieldNames := func(s *WebRPCSchema) []string {
+		names := []string{}
+		for _, field := range s.GetMessageByName("User").Fields {
+			names = append(names, string(field.Name))
+		}
+		return names
+	}
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, order, fieldNames(s))
+
+	for i := 0; i < 3; i++ {
+		out, err := s.ToJSON(false)
+		assert.NoError(t, err)
+		s, err = ParseSchemaJSON([]byte(out))
+		assert.NoError(t, err)
+		assert.Equal(t, order, fieldNames(s))
+	}
+
+	canonical, err := s.CanonicalJSON()
+	assert.NoError(t, err)
+	c, err := ParseSchemaJSON(canonical)
+	assert.NoError(t, err)
+	assert.Equal(t, order, fieldNames(c))
+
+	subset, err := s.Subset([]string{"Users"})
+	assert.NoError(t, err)
+	assert.Equal(t, order, fieldNames(subset))
+
+	messages, err := c.Subset([]string{"User"})
+	assert.NoError(t, err)
+	merged, err := Merge(s, messages)
+	assert.NoError(t, err)
+	assert.Equal(t, order, fieldNames(merged))
+}
@@ -90,6 +90,17 @@ func (m *Method) Parse(schema *WebRPCSchema, service *Service) error {
 	m.Service = service // back-ref
 	serviceName := string(service.Name)
 
+	// A sole void argument is an explicit empty payload
+	var err error
+	m.Inputs, err = parseVoidArguments(m.Inputs, "input", string(m.Name), serviceName)
+	if err != nil {
+		return err
+	}
+	m.Outputs, err = parseVoidArguments(m.Outputs, "output", string(m.Name), serviceName)
+	if err != nil {
+		return err
+	}
+
 	// Parse+validate inputs
 	for _, input := range m.Inputs {
 		input.InputArg = true // back-ref
@@ -118,3 +129,98 @@ func (m *Method) Parse(schema *WebRPCSchema, service *Service) error {
 
 	return nil
 }
+
+// ResolveTypes parses the input and output types of the method against
+// schema. Unlike Parse, it doesn't stop at the first bad type, all of them
+// are reported in a single error naming the method.
+func (m *Method) ResolveTypes(schema *WebRPCSchema) error {
+	problems := []string{}
+	resolve := func(kind string, args []*MethodArgument) {
+		for _, arg := range args {
+			if arg.Type == nil {
+				problems = append(problems, fmt.Sprintf("%s '%s' has no type", kind, arg.Name))
+				continue
+			}
+			if err := arg.Type.Parse(schema); err != nil {
+				problems = append(problems, fmt.Sprintf("%s '%s': %v", kind, arg.Name, err))
+			}
+		}
+	}
+	resolve("input", m.Inputs)
+	resolve("output", m.Outputs)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	methodName := string(m.Name)
+	if m.Service != nil {
+		methodName = string(m.Service.Name) + "." + methodName
+	}
+	return fmt.Errorf("schema error: method '%s' has unresolved types: %s", methodName, strings.Join(problems, "; "))
+}
+
+// TransitiveTypes returns every message reachable from the input and output
+// types of the method, including messages referenced by their fields, ie. to
+// generate a self-contained client for the method alone. Messages are listed
+// once each, in schema declaration order.
+func (m *Method) TransitiveTypes(schema *WebRPCSchema) []*Message {
+	reachable := reachableMessages(schema, append(append([]*MethodArgument{}, m.Inputs...), m.Outputs...))
+
+	messages := []*Message{}
+	for _, msg := range schema.Messages {
+		if reachable[msg] {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// reachableMessages returns the messages referenced by the argument types,
+// directly or through the fields of other messages
+func reachableMessages(schema *WebRPCSchema, args []*MethodArgument) map[*Message]bool {
+	reachable := map[*Message]bool{}
+
+	var visitType func(t *VarType)
+	visitType = func(t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			if vt.Type != T_Struct || vt.Struct == nil {
+				return true
+			}
+			msg := vt.Struct.Message
+			if msg == nil {
+				msg = schema.GetMessageByName(vt.Struct.Name)
+			}
+			if msg == nil || reachable[msg] {
+				return true
+			}
+			reachable[msg] = true
+			for _, field := range msg.Fields {
+				visitType(field.Type)
+			}
+			return true
+		})
+	}
+
+	for _, arg := range args {
+		visitType(arg.Type)
+	}
+	return reachable
+}
+
+// parseVoidArguments returns an empty argument list when args is a single
+// void argument, and rejects void mixed in with other arguments.
+func parseVoidArguments(args []*MethodArgument, kind, methodName, serviceName string) ([]*MethodArgument, error) {
+	for _, arg := range args {
+		if arg.Type == nil || arg.Type.Expr != T_Void.String() {
+			continue
+		}
+		if len(args) > 1 {
+			return nil, fmt.Errorf("schema error: void %s for method '%s' in service '%s' must be the only %s", kind, methodName, serviceName, kind)
+		}
+		if arg.Optional {
+			return nil, fmt.Errorf("schema error: void %s for method '%s' in service '%s' cannot be optional", kind, methodName, serviceName)
+		}
+		return []*MethodArgument{}, nil
+	}
+	return args, nil
+}
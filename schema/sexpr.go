@@ -0,0 +1,406 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SExpr returns the type as a fully parenthesized S-expression, for external
+// analysis tools, ie. (map string (list (struct User))) for
+// map<string,[]User>. Basic types are bare atoms, and every other type is a
+// list headed by its kind:
+//
+//	(list T) (uniquelist T) (map K V) (result OK ERR)
+//	(union A B) (union (discriminator kind) A B)
+//	(money USD) (decimal 10 2) (ratio 0 1) (struct User)
+//	(optional T) (nullable T)
+//
+// Struct names clashing with the syntax are quoted, ie. (struct "a b"). See
+// ParseSExpr for the reverse.
+func (t *VarType) SExpr() string {
+	if t == nil {
+		return "()"
+	}
+
+	if t.Optional {
+		base := *t
+		base.Optional = false
+		return fmt.Sprintf("(optional %s)", base.SExpr())
+	}
+	if t.Nullable {
+		base := *t
+		base.Nullable = false
+		return fmt.Sprintf("(nullable %s)", base.SExpr())
+	}
+
+	switch t.Type {
+	case T_Unknown:
+		return "unknown"
+
+	case T_List:
+		keyword := "list"
+		if t.List.Unique {
+			keyword = uniqueListKeyword
+		}
+		return fmt.Sprintf("(%s %s)", keyword, t.List.Elem.SExpr())
+
+	case T_Map:
+		return fmt.Sprintf("(map %s %s)", t.Map.Key, t.Map.Value.SExpr())
+
+	case T_Result:
+		return fmt.Sprintf("(result %s %s)", t.Result.Ok.SExpr(), t.Result.Err.SExpr())
+
+	case T_Union:
+		parts := []string{"union"}
+		if t.Union.Discriminator != "" {
+			parts = append(parts, fmt.Sprintf("(discriminator %s)", quoteSExprAtom(t.Union.Discriminator)))
+		}
+		for _, variant := range t.Union.Variants {
+			parts = append(parts, variant.SExpr())
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+
+	case T_Money:
+		return fmt.Sprintf("(money %s)", t.Money.Currency)
+
+	case T_Decimal:
+		if t.Decimal != nil {
+			return fmt.Sprintf("(decimal %d %d)", t.Decimal.Precision, t.Decimal.Scale)
+		}
+		return t.Type.String()
+
+	case T_Ratio:
+		if t.Range != nil {
+			return fmt.Sprintf("(ratio %s %s)", strconv.FormatFloat(t.Range.Min, 'f', -1, 64), strconv.FormatFloat(t.Range.Max, 'f', -1, 64))
+		}
+		return t.Type.String()
+
+	case T_Struct:
+		return fmt.Sprintf("(struct %s)", quoteSExprAtom(t.Struct.Name))
+
+	default:
+		return t.Type.String()
+	}
+}
+
+// quoteSExprAtom quotes names which aren't a plain S-expression atom
+func quoteSExprAtom(name string) string {
+	if name == "" || strings.IndexFunc(name, isSExprDelimiter) >= 0 {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+func isSExprDelimiter(r rune) bool {
+	return r == '(' || r == ')' || r == '"' || unicode.IsSpace(r)
+}
+
+// ParseSExpr parses a type from its SExpr form. Struct references are left
+// unresolved, with a nil Struct.Message, as there is no schema to look them
+// up in. The Expr of the returned type is rebuilt in the type expr syntax, so
+// a later Parse against a schema resolves them.
+func ParseSExpr(s string) (*VarType, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return newParseError(ErrInvalidSyntax, s, "schema error: invalid s-expression '%s': %s", s, fmt.Sprintf(format, args...))
+	}
+
+	tokens, err := tokenizeSExpr(s)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	p := &sexprParser{tokens: tokens, invalid: invalid}
+
+	vt, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, invalid("unexpected '%s' after the type", p.tokens[p.pos].val)
+	}
+
+	vt.Expr = buildVarTypeExpr(vt, "", ParseOptions{})
+	return vt, nil
+}
+
+type sexprToken struct {
+	val    string
+	open   bool // (
+	close  bool // )
+	quoted bool
+}
+
+func tokenizeSExpr(s string) ([]sexprToken, error) {
+	tokens := []sexprToken{}
+	for i := 0; i < len(s); {
+		switch c := rune(s[i]); {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, sexprToken{val: "(", open: true})
+			i++
+		case c == ')':
+			tokens = append(tokens, sexprToken{val: ")", close: true})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated quoted name")
+			}
+			name, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted name %s", s[i:end+1])
+			}
+			tokens = append(tokens, sexprToken{val: name, quoted: true})
+			i = end + 1
+		default:
+			start := i
+			for i < len(s) && !isSExprDelimiter(rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, sexprToken{val: s[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type sexprParser struct {
+	tokens  []sexprToken
+	pos     int
+	invalid func(format string, args ...interface{}) error
+}
+
+func (p *sexprParser) next() (sexprToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return sexprToken{}, false
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, true
+}
+
+// atom consumes a plain or quoted atom
+func (p *sexprParser) atom(what string) (sexprToken, error) {
+	tok, ok := p.next()
+	if !ok || tok.open || tok.close {
+		return tok, p.invalid("expecting %s", what)
+	}
+	return tok, nil
+}
+
+func (p *sexprParser) close(keyword string) error {
+	tok, ok := p.next()
+	if !ok || !tok.close {
+		return p.invalid("expecting ')' to close (%s ...)", keyword)
+	}
+	return nil
+}
+
+func (p *sexprParser) parseType() (*VarType, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, p.invalid("expecting a type")
+	}
+	if tok.close {
+		return nil, p.invalid("unexpected ')'")
+	}
+	if !tok.open {
+		return p.parseBasicType(tok)
+	}
+
+	head, err := p.atom("a type keyword after '('")
+	if err != nil {
+		return nil, err
+	}
+	if head.quoted {
+		return nil, p.invalid("type keyword %q must not be quoted", head.val)
+	}
+
+	vt := &VarType{}
+	switch head.val {
+	case optionalKeyword, nullableKeyword:
+		base, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if (head.val == optionalKeyword && base.Optional) || (head.val == nullableKeyword && base.Nullable) {
+			return nil, p.invalid("nested (%s ...)", head.val)
+		}
+		vt = base
+		if head.val == optionalKeyword {
+			vt.Optional = true
+		} else {
+			vt.Nullable = true
+		}
+
+	case "list", uniqueListKeyword:
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		vt.Type = T_List
+		vt.List = &VarListType{Elem: elem, Unique: head.val == uniqueListKeyword}
+
+	case "map":
+		key, err := p.atom("a map key type")
+		if err != nil {
+			return nil, err
+		}
+		keyDataType, ok := DataTypeFromString[key.val]
+		if key.quoted || !ok {
+			return nil, p.invalid("unknown map key type '%s'", key.val)
+		}
+		if err := ValidateMapKey(keyDataType); err != nil {
+			return nil, p.invalid("%s", strings.TrimPrefix(err.Error(), "schema error: "))
+		}
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		vt.Type = T_Map
+		vt.Map = &VarMapType{Key: keyDataType, Value: value}
+
+	case "result":
+		okType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		errType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		vt.Type = T_Result
+		vt.Result = &VarResultType{Ok: okType, Err: errType}
+
+	case "union":
+		vt.Type = T_Union
+		vt.Union = &VarUnionType{}
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos].open && p.tokens[p.pos+1].val == "discriminator" && !p.tokens[p.pos+1].quoted {
+			p.pos += 2
+			name, err := p.atom("a discriminator field name")
+			if err != nil {
+				return nil, err
+			}
+			if err := p.close("discriminator"); err != nil {
+				return nil, err
+			}
+			vt.Union.Discriminator = name.val
+		}
+		for p.pos < len(p.tokens) && !p.tokens[p.pos].close {
+			variant, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			if vt.Union.Discriminator != "" && (variant.Type != T_Struct || variant.Optional || variant.Nullable) {
+				return nil, p.invalid("variant '%s' of a discriminated union must be a struct", variant.SExpr())
+			}
+			vt.Union.Variants = append(vt.Union.Variants, variant)
+		}
+		if len(vt.Union.Variants) < 2 {
+			return nil, p.invalid("union needs at least two variants")
+		}
+
+	case "money":
+		currency, err := p.atom("a currency code")
+		if err != nil {
+			return nil, err
+		}
+		if currency.quoted || !isValidCurrencyCode(currency.val) {
+			return nil, p.invalid("invalid currency '%s', expecting a 3-letter ISO 4217 code", currency.val)
+		}
+		vt.Type = T_Money
+		vt.Money = &VarMoneyType{Currency: currency.val}
+
+	case "decimal":
+		precision, err := p.int("decimal precision")
+		if err != nil {
+			return nil, err
+		}
+		scale, err := p.int("decimal scale")
+		if err != nil {
+			return nil, err
+		}
+		vt.Type = T_Decimal
+		vt.Decimal = &VarDecimalType{Precision: precision, Scale: scale}
+		if err := vt.Decimal.validate(); err != nil {
+			return nil, p.invalid("%v", err)
+		}
+
+	case "ratio":
+		min, err := p.float("ratio minimum")
+		if err != nil {
+			return nil, err
+		}
+		max, err := p.float("ratio maximum")
+		if err != nil {
+			return nil, err
+		}
+		vt.Type = T_Ratio
+		vt.Range = &VarRangeType{Min: min, Max: max}
+		if err := vt.Range.validate(); err != nil {
+			return nil, p.invalid("%v", err)
+		}
+
+	case "struct":
+		name, err := p.atom("a struct name")
+		if err != nil {
+			return nil, err
+		}
+		if name.val == "" {
+			return nil, p.invalid("empty struct name")
+		}
+		vt.Type = T_Struct
+		vt.Struct = &VarStructType{Name: name.val}
+
+	default:
+		return nil, p.invalid("unknown type keyword '%s'", head.val)
+	}
+
+	if err := p.close(head.val); err != nil {
+		return nil, err
+	}
+	return vt, nil
+}
+
+func (p *sexprParser) parseBasicType(tok sexprToken) (*VarType, error) {
+	dataType, ok := DataTypeFromString[tok.val]
+	if tok.quoted || !ok {
+		return nil, p.invalid("unknown type '%s', expecting (struct %s) for a message", tok.val, quoteSExprAtom(tok.val))
+	}
+	switch dataType {
+	case T_List, T_Map, T_Result, T_Union, T_Money:
+		return nil, p.invalid("%s is missing its sub-types, expecting (%s ...)", tok.val, tok.val)
+	}
+	return &VarType{Type: dataType}, nil
+}
+
+func (p *sexprParser) int(what string) (int, error) {
+	tok, err := p.atom("a " + what)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(tok.val)
+	if tok.quoted || err != nil {
+		return 0, p.invalid("invalid %s '%s'", what, tok.val)
+	}
+	return n, nil
+}
+
+func (p *sexprParser) float(what string) (float64, error) {
+	tok, err := p.atom("a " + what)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(tok.val, 64)
+	if tok.quoted || err != nil {
+		return 0, p.invalid("invalid %s '%s'", what, tok.val)
+	}
+	return f, nil
+}
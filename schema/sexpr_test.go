@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeSExpr(t *testing.T) {
+	s := newTestSchema("User", "Cat", "Dog", "Error", "weird name")
+
+	tt := []struct {
+		Expr  string
+		SExpr string
+	}{
+		{"string", "string"},
+		{"map<string,[]User>", "(map string (list (struct User)))"},
+		{"map<uint64,map<string,User?>>", "(map uint64 (map string (optional (struct User))))"},
+		{"nullable<decimal<10,2>>?", "(optional (nullable (decimal 10 2)))"},
+		{"optional<[]int64>", "(optional (list int64))"},
+		{"uniquelist<ratio<0,100>>", "(uniquelist (ratio 0 100))"},
+		{"result<[]money<USD>,Error>", "(result (list (money USD)) (struct Error))"},
+		{"union<kind:Cat|Dog>", "(union (discriminator kind) (struct Cat) (struct Dog))"},
+		{"union<string|[]int32>", "(union string (list int32))"},
+		{"[]`weird name`", `(list (struct "weird name"))`},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.Equal(t, tc.SExpr, vt.SExpr(), tc.Expr)
+
+		parsed, err := ParseSExpr(tc.SExpr)
+		if !assert.NoError(t, err, tc.SExpr) {
+			continue
+		}
+		assert.Equal(t, tc.Expr, parsed.Expr, tc.SExpr)
+		assert.Equal(t, tc.SExpr, parsed.SExpr(), tc.SExpr)
+
+		// struct resolution is deferred to a Parse against a schema
+		assert.NoError(t, parsed.Parse(s), tc.SExpr)
+		assert.True(t, parsed.Equal(vt), tc.SExpr)
+	}
+}
+
+func TestParseSExprDeferredStruct(t *testing.T) {
+	vt, err := ParseSExpr("(map string (list (struct User)))")
+	assert.NoError(t, err)
+	assert.Equal(t, "map<string,[]User>", vt.Expr)
+	assert.Equal(t, "User", vt.Map.Value.List.Elem.Struct.Name)
+	assert.Nil(t, vt.Map.Value.List.Elem.Struct.Message)
+}
+
+func TestParseSExprErrors(t *testing.T) {
+	tt := []struct {
+		SExpr string
+		Err   string
+	}{
+		{"", "schema error: invalid s-expression '': expecting a type"},
+		{"User", "schema error: invalid s-expression 'User': unknown type 'User', expecting (struct User) for a message"},
+		{"(list string", "schema error: invalid s-expression '(list string': expecting ')' to close (list ...)"},
+		{"(list string) int", "schema error: invalid s-expression '(list string) int': unexpected 'int' after the type"},
+		{"(map float64 int)", "schema error: invalid s-expression '(map float64 int)': invalid map key type 'float64', must be one of string, uint, uint8, uint16, uint32, uint64, int, int8, int16, int32, int64, bigint, uuid, date, ipaddr, cidr"},
+		{"(union string)", "schema error: invalid s-expression '(union string)': union needs at least two variants"},
+		{"(union (discriminator kind) (struct Cat) string)", "schema error: invalid s-expression '(union (discriminator kind) (struct Cat) string)': variant 'string' of a discriminated union must be a struct"},
+		{"(ratio 1 0)", "schema error: invalid s-expression '(ratio 1 0)': ratio minimum 1 must be less than the maximum 0"},
+		{"(decimal 10 x)", "schema error: invalid s-expression '(decimal 10 x)': invalid decimal scale 'x'"},
+		{"(optional (optional string))", "schema error: invalid s-expression '(optional (optional string))': nested (optional ...)"},
+		{"(tuple string int)", "schema error: invalid s-expression '(tuple string int)': unknown type keyword 'tuple'"},
+		{"map", "schema error: invalid s-expression 'map': map is missing its sub-types, expecting (map ...)"},
+		{`(struct "User)`, `schema error: invalid s-expression '(struct "User)': unterminated quoted name`},
+	}
+	for _, tc := range tt {
+		_, err := ParseSExpr(tc.SExpr)
+		assert.EqualError(t, err, tc.Err, tc.SExpr)
+	}
+}
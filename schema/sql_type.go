@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlDialects maps basic data types to SQL column types, per dialect
+var sqlDialects = map[string]map[DataType]string{
+	"postgres": {
+		T_Bool:      "BOOLEAN",
+		T_Byte:      "SMALLINT",
+		T_Uint8:     "SMALLINT",
+		T_Uint16:    "INTEGER",
+		T_Uint32:    "BIGINT",
+		T_Uint64:    "NUMERIC(20,0)",
+		T_Uint:      "NUMERIC(20,0)",
+		T_Int8:      "SMALLINT",
+		T_Int16:     "SMALLINT",
+		T_Int32:     "INTEGER",
+		T_Int64:     "BIGINT",
+		T_Int:       "BIGINT",
+		T_Float32:   "REAL",
+		T_Float64:   "DOUBLE PRECISION",
+		T_Ratio:     "DOUBLE PRECISION",
+		T_String:    "TEXT",
+		T_Timestamp: "TIMESTAMP",
+		T_DateTime:  "TIMESTAMPTZ",
+		T_Date:      "DATE",
+		T_Time:      "TIME",
+		T_BigInt:    "NUMERIC",
+		T_Rational:  "TEXT",
+		T_Decimal:   "NUMERIC",
+		T_UUID:      "UUID",
+		T_IPAddr:    "INET",
+		T_CIDR:      "CIDR",
+		T_Blob:      "BYTEA",
+	},
+	"mysql": {
+		T_Bool:      "BOOLEAN",
+		T_Byte:      "TINYINT UNSIGNED",
+		T_Uint8:     "TINYINT UNSIGNED",
+		T_Uint16:    "SMALLINT UNSIGNED",
+		T_Uint32:    "INT UNSIGNED",
+		T_Uint64:    "BIGINT UNSIGNED",
+		T_Uint:      "BIGINT UNSIGNED",
+		T_Int8:      "TINYINT",
+		T_Int16:     "SMALLINT",
+		T_Int32:     "INT",
+		T_Int64:     "BIGINT",
+		T_Int:       "BIGINT",
+		T_Float32:   "FLOAT",
+		T_Float64:   "DOUBLE",
+		T_Ratio:     "DOUBLE",
+		T_String:    "TEXT",
+		T_Timestamp: "TIMESTAMP",
+		T_DateTime:  "DATETIME",
+		T_Date:      "DATE",
+		T_Time:      "TIME",
+		T_BigInt:    "DECIMAL(65,0)",
+		T_Rational:  "TEXT",
+		T_Decimal:   "DECIMAL(65,30)",
+		T_UUID:      "CHAR(36)",
+		T_IPAddr:    "VARCHAR(45)",
+		T_CIDR:      "VARCHAR(49)",
+		T_Blob:      "LONGBLOB",
+	},
+}
+
+// sqlBytesTypes is the binary column type of []byte, per dialect
+var sqlBytesTypes = map[string]string{
+	"postgres": "BYTEA",
+	"mysql":    "BLOB",
+}
+
+// SQLType returns the SQL column type of the type for the given dialect,
+// "postgres" or "mysql". Nullability is left to the column definition, so
+// optional and nullable types map to the type they wrap. Containers, structs
+// and other composite types have no column type and return an error, callers
+// usually store those as JSON instead.
+func (t *VarType) SQLType(dialect string) (string, error) {
+	d := strings.ToLower(dialect)
+	if d == "postgresql" {
+		d = "postgres"
+	}
+	types, ok := sqlDialects[d]
+	if !ok {
+		return "", fmt.Errorf("unknown SQL dialect '%s', expecting postgres or mysql", dialect)
+	}
+
+	switch t.Type {
+	case T_Decimal:
+		if t.Decimal != nil {
+			if d == "mysql" {
+				return fmt.Sprintf("DECIMAL(%d,%d)", t.Decimal.Precision, t.Decimal.Scale), nil
+			}
+			return fmt.Sprintf("NUMERIC(%d,%d)", t.Decimal.Precision, t.Decimal.Scale), nil
+		}
+	case T_List:
+		if t.IsBytes() {
+			return sqlBytesTypes[d], nil
+		}
+	case T_Struct:
+		if msg := t.Struct.Message; msg != nil && msg.Type == "enum" && msg.EnumType != nil {
+			return msg.EnumType.SQLType(d)
+		}
+	}
+
+	if sqlType, ok := types[t.Type]; ok {
+		return sqlType, nil
+	}
+	return "", fmt.Errorf("type '%s' has no SQL column type, store it as JSON instead", t.String())
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeSQLType(t *testing.T) {
+	s := newTestSchema("User")
+	s.Messages = append(s.Messages, &Message{Name: "Kind", Type: "enum", EnumType: &VarType{Expr: "uint16", Type: T_Uint16}})
+
+	tt := []struct {
+		Expr     string
+		Postgres string
+		MySQL    string
+	}{
+		{"decimal<10,2>", "NUMERIC(10,2)", "DECIMAL(10,2)"},
+		{"decimal", "NUMERIC", "DECIMAL(65,30)"},
+		{"timestamp", "TIMESTAMP", "TIMESTAMP"},
+		{"timestamp?", "TIMESTAMP", "TIMESTAMP"},
+		{"int64", "BIGINT", "BIGINT"},
+		{"uint64", "NUMERIC(20,0)", "BIGINT UNSIGNED"},
+		{"float64", "DOUBLE PRECISION", "DOUBLE"},
+		{"uuid", "UUID", "CHAR(36)"},
+		{"[]byte", "BYTEA", "BLOB"},
+		{"Kind", "INTEGER", "SMALLINT UNSIGNED"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		pg, err := vt.SQLType("postgres")
+		assert.NoError(t, err, tc.Expr)
+		assert.Equal(t, tc.Postgres, pg, tc.Expr)
+
+		my, err := vt.SQLType("mysql")
+		assert.NoError(t, err, tc.Expr)
+		assert.Equal(t, tc.MySQL, my, tc.Expr)
+	}
+}
+
+func TestVarTypeSQLTypeUnsupported(t *testing.T) {
+	s := newTestSchema("User")
+
+	for _, expr := range []string{"map<string,int64>", "[]string", "User", "any"} {
+		vt := &VarType{Expr: expr}
+		if !assert.NoError(t, vt.Parse(s), expr) {
+			continue
+		}
+		_, err := vt.SQLType("postgres")
+		assert.Contains(t, err.Error(), "has no SQL column type, store it as JSON instead", expr)
+	}
+
+	vt := &VarType{Expr: "string"}
+	assert.NoError(t, vt.Parse(s))
+	_, err := vt.SQLType("oracle")
+	assert.Contains(t, err.Error(), "unknown SQL dialect 'oracle'")
+}
@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tsDataTypes maps basic data types to TypeScript types. Types encoded as
+// strings on the wire, ie. timestamps and bigints, are kept as strings.
+var tsDataTypes = map[DataType]string{
+	T_Null: "null",
+	T_Void: "void",
+	T_Any:  "any",
+	T_Byte: "number",
+	T_Bool: "boolean",
+
+	T_Uint:   "number",
+	T_Uint8:  "number",
+	T_Uint16: "number",
+	T_Uint32: "number",
+	T_Uint64: "number",
+	T_Int:    "number",
+	T_Int8:   "number",
+	T_Int16:  "number",
+	T_Int32:  "number",
+	T_Int64:  "number",
+
+	T_Float32: "number",
+	T_Float64: "number",
+	T_Ratio:   "number",
+
+	T_String: "string",
+
+	T_Timestamp: "string",
+	T_Date:      "string",
+	T_Time:      "string",
+	T_DateTime:  "string",
+
+	T_BigInt:   "string",
+	T_Rational: "string",
+	T_Decimal:  "string",
+	T_UUID:     "string",
+	T_IPAddr:   "string",
+	T_CIDR:     "string",
+
+	// generators emit GeoPoint and Money interfaces, and a Blob stream type,
+	// as they do for Go
+	T_GeoPoint: "GeoPoint",
+	T_Money:    "Money",
+	T_Blob:     "Blob",
+}
+
+// TSType returns the TypeScript type expression for the type, ie.
+// Array<User> for []User, or {[key: string]: number} for map<string,int64>.
+// Optional and nullable types are unions with null, as both are null on the
+// wire, see ToTSInterface for optional fields.
+func (t *VarType) TSType() string {
+	if t.Optional || t.Nullable {
+		base := *t
+		base.Optional = false
+		base.Nullable = false
+		return base.TSType() + " | null"
+	}
+
+	if t.IsBytes() {
+		// base64 on the wire
+		return "string"
+	}
+
+	switch t.Type {
+	case T_List:
+		return fmt.Sprintf("Array<%s>", t.List.Elem.TSType())
+	case T_Map:
+		key := "string"
+		if isIntegerType(t.Map.Key) {
+			key = "number"
+		}
+		if t.MapKeyWireStrategy() == MapKeysAsPairs {
+			return fmt.Sprintf("Array<[%s, %s]>", key, t.Map.Value.TSType())
+		}
+		return fmt.Sprintf("{[key: %s]: %s}", key, t.Map.Value.TSType())
+	case T_Result:
+		return fmt.Sprintf("%s | %s", t.Result.Ok.TSType(), t.Result.Err.TSType())
+	case T_Union:
+		variants := make([]string, 0, len(t.Union.Variants))
+		for _, variant := range t.Union.Variants {
+			if t.Union.Discriminator != "" {
+				variants = append(variants, fmt.Sprintf("({%s: '%s'} & %s)", t.Union.Discriminator, variant.Struct.Name, variant.Struct.Name))
+				continue
+			}
+			variants = append(variants, variant.TSType())
+		}
+		return strings.Join(variants, " | ")
+	case T_Struct:
+		return t.Struct.Name
+	default:
+		if tsType, ok := tsDataTypes[t.Type]; ok {
+			return tsType
+		}
+		return "any"
+	}
+}
+
+// ToTSInterface returns a TypeScript interface for a struct message, with a
+// property for each field under its wire name, ie.
+//
+//	interface User {
+//	  id: number;
+//	  nickname?: string;
+//	}
+//
+// Optional fields are marked `name?:` rather than unioned with null, and
+// nested structs reference their own interface names. Field descriptions and
+// deprecations become doc comments. Enums have no interface, and return "".
+func (m *Message) ToTSInterface() string {
+	if m.Type != "struct" {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "interface %s {\n", m.Name)
+	for _, field := range m.Fields {
+		if field.Type == nil || field.JSONOmitted {
+			continue
+		}
+		if doc := field.tsDoc(); doc != "" {
+			fmt.Fprintf(&b, "  /** %s */\n", doc)
+		}
+		fieldType := *field.Type
+		name := field.WireName()
+		if field.Optional || fieldType.Optional {
+			fieldType.Optional = false
+			name += "?"
+		}
+		fmt.Fprintf(&b, "  %s: %s;\n", name, fieldType.TSType())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// tsDoc returns the doc comment text of a field, if any
+func (f *MessageField) tsDoc() string {
+	parts := []string{}
+	if f.Description != "" {
+		parts = append(parts, f.Description)
+	}
+	if f.Deprecated {
+		parts = append(parts, "@deprecated")
+	}
+	return strings.Join(parts, " ")
+}
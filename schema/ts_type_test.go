@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeTSType(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	tt := []struct {
+		Expr   string
+		TSType string
+	}{
+		{"string", "string"},
+		{"uint64?", "number | null"},
+		{"[]User", "Array<User>"},
+		{"map<string,[]User?>", "{[key: string]: Array<User | null>}"},
+		{"map<uint32,timestamp>", "{[key: number]: string}"},
+		{"result<User,Error>", "User | Error"},
+		{"union<User|string>", "User | string"},
+		{"union<kind:User|Error>", "({kind: 'User'} & User) | ({kind: 'Error'} & Error)"},
+		{"money<USD>", "Money"},
+		{"nullable<bigint>", "string | null"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.TSType, vt.TSType(), tc.Expr)
+		}
+	}
+
+	s.ParseOptions = ParseOptions{MapKeyWireStrategy: MapKeysAsPairs}
+	vt := &VarType{Expr: "map<uint64,User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "Array<[number, User]>", vt.TSType())
+}
+
+func TestMessageToTSInterface(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32" }] },
+			{ "name": "Team", "type": "struct", "fields": [{ "name": "name", "type": "string" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "nickname", "type": "string?" },
+					{ "name": "email", "type": "string", "optional": true, "meta": [{ "json": "email_address" }] },
+					{ "name": "team", "type": { "expr": "Team?", "description": "The user's team" } },
+					{ "name": "tags", "type": "[]string?" },
+					{ "name": "scores", "type": "map<string,float64>" },
+					{ "name": "legacyID", "type": { "expr": "uint32", "deprecated": true } }
+				]
+			}
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	golden := `interface User {
+  id: number;
+  kind: Kind;
+  nickname?: string;
+  email_address?: string;
+  /** The user's team */
+  team?: Team;
+  tags: Array<string | null>;
+  scores: {[key: string]: number};
+  /** @deprecated */
+  legacyID: number;
+}`
+	assert.Equal(t, golden, s.GetMessageByName("User").ToTSInterface())
+	assert.Equal(t, "interface Team {\n  name: string;\n}", s.GetMessageByName("Team").ToTSInterface())
+	assert.Equal(t, "", s.GetMessageByName("Kind").ToTSInterface())
+}
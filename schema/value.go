@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ValidateValue checks a JSON value, as decoded into an interface{}, has the
+// shape of the type, ie. for field examples. Numbers must fit integer types,
+// struct objects must hold their required fields, see MessageField.IsRequired,
+// and enums accept their value names or numbers. Unlike Coerce, values are
+// never converted, so ie. "42" isn't a valid uint32. json.Number values are
+// checked against integer types in full, without rounding through float64.
+func (t *VarType) ValidateValue(v interface{}) error {
+	if n, ok := v.(json.Number); ok {
+		if isIntegerType(t.Type) {
+			_, err := parseIntLiteral(n.String(), t.Type)
+			return err
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid number '%s'", n)
+		}
+		v = f
+	}
+	if v == nil {
+		if t.Optional || t.Nullable || t.Type == T_Null || t.Type == T_Any {
+			return nil
+		}
+		return fmt.Errorf("null is not a valid '%s'", t.Expr)
+	}
+
+	invalid := func() error {
+		return fmt.Errorf("%T value '%v' is not a valid '%s'", v, v, t.Expr)
+	}
+
+	switch t.Type {
+	case T_Any:
+		return nil
+
+	case T_Null:
+		return invalid()
+
+	case T_Bool:
+		if _, ok := v.(bool); !ok {
+			return invalid()
+		}
+		return nil
+
+	case T_Float32, T_Float64:
+		if _, ok := v.(float64); !ok {
+			return invalid()
+		}
+		return nil
+
+	case T_Ratio:
+		f, ok := v.(float64)
+		if !ok {
+			return invalid()
+		}
+		return t.Range.check(f)
+
+	case T_String, T_Timestamp, T_Date, T_Time, T_DateTime, T_UUID, T_Rational, T_Decimal:
+		if _, ok := v.(string); !ok {
+			return invalid()
+		}
+		return nil
+
+	case T_IPAddr, T_CIDR:
+		if s, ok := v.(string); !ok || !isValidIPValue(s, t.Type) {
+			return invalid()
+		}
+		return nil
+
+	case T_BigInt:
+		if s, ok := v.(string); !ok || !isDecimalDigits(s) {
+			return invalid()
+		}
+		return nil
+
+	case T_List:
+		if t.IsBytes() {
+			if s, ok := v.(string); !ok || !isBase64(s) {
+				return invalid()
+			}
+			return nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return invalid()
+		}
+		for i, elem := range list {
+			if err := t.List.Elem.ValidateValue(elem); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return nil
+
+	case T_Map:
+		if t.MapKeyWireStrategy() == MapKeysAsPairs {
+			pairs, ok := v.([]interface{})
+			if !ok {
+				return invalid()
+			}
+			key := &VarType{Expr: t.Map.Key.String(), Type: t.Map.Key}
+			for i, p := range pairs {
+				pair, ok := p.([]interface{})
+				if !ok || len(pair) != 2 {
+					return fmt.Errorf("[%d]: %T value '%v' is not a [key, value] pair", i, p, p)
+				}
+				if err := key.ValidateValue(pair[0]); err != nil {
+					return fmt.Errorf("[%d] key: %w", i, err)
+				}
+				if err := t.Map.Value.ValidateValue(pair[1]); err != nil {
+					return fmt.Errorf("[%d] value: %w", i, err)
+				}
+			}
+			return nil
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		for key, value := range obj {
+			if isIntegerType(t.Map.Key) {
+				if _, err := parseIntLiteral(key, t.Map.Key); err != nil {
+					return fmt.Errorf("map key '%s': %w", key, err)
+				}
+			}
+			if (t.Map.Key == T_IPAddr || t.Map.Key == T_CIDR) && !isValidIPValue(key, t.Map.Key) {
+				return fmt.Errorf("map key '%s' is not a valid %s", key, t.Map.Key)
+			}
+			if err := t.Map.Value.ValidateValue(value); err != nil {
+				return fmt.Errorf("[%q]: %w", key, err)
+			}
+		}
+		return nil
+
+	case T_Result:
+		if t.Result.Ok.ValidateValue(v) == nil || t.Result.Err.ValidateValue(v) == nil {
+			return nil
+		}
+		return invalid()
+
+	case T_Union:
+		for _, variant := range t.Union.Variants {
+			if variant.ValidateValue(v) == nil {
+				return nil
+			}
+		}
+		return invalid()
+
+	case T_Money, T_GeoPoint:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return invalid()
+		}
+		return nil
+
+	case T_Struct:
+		msg := t.Struct.Message
+		if msg == nil {
+			return invalid()
+		}
+		if msg.Type == "enum" {
+			if name, ok := v.(string); ok && msg.getField(VarName(name)) != nil {
+				return nil
+			}
+			if n, ok := v.(float64); ok {
+				for _, field := range msg.Fields {
+					if field.Value == strconv.FormatFloat(n, 'f', -1, 64) {
+						return nil
+					}
+				}
+			}
+			return invalid()
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		for _, field := range msg.Fields {
+			if field.JSONOmitted {
+				continue
+			}
+			value, present := obj[field.WireName()]
+			if !present {
+				if field.IsRequired() {
+					return fmt.Errorf("missing required field '%s' of '%s'", field.WireName(), msg.Name)
+				}
+				continue
+			}
+			if value == nil && field.Optional {
+				continue
+			}
+			if err := field.ValidateValue(value); err != nil {
+				return fmt.Errorf("%s: %w", field.WireName(), err)
+			}
+		}
+		return nil
+
+	default:
+		if !isIntegerType(t.Type) {
+			return invalid()
+		}
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || math.IsInf(n, 0) {
+			return invalid()
+		}
+		if _, err := parseIntLiteral(strconv.FormatFloat(n, 'f', 0, 64), t.Type); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// ValidateValue checks a JSON value against the field type, as
+// VarType.ValidateValue does, and against the field constraints of the
+// pattern, minLength and maxLength metas. Null values of optional fields
+// aren't constrained.
+func (f *MessageField) ValidateValue(v interface{}) error {
+	if err := f.Type.ValidateValue(v); err != nil {
+		return err
+	}
+
+	length := -1
+	switch value := v.(type) {
+	case string:
+		if f.Pattern != "" {
+			if matched, _ := regexp.MatchString(f.Pattern, value); !matched {
+				return fmt.Errorf("value '%s' doesn't match the pattern '%s'", value, f.Pattern)
+			}
+		}
+		length = utf8.RuneCountInString(value)
+	case []interface{}:
+		length = len(value)
+	}
+	if length < 0 {
+		return nil
+	}
+	if f.MinLength != nil && length < *f.MinLength {
+		return fmt.Errorf("length %d is less than the minLength %d", length, *f.MinLength)
+	}
+	if f.MaxLength != nil && length > *f.MaxLength {
+		return fmt.Errorf("length %d is greater than the maxLength %d", length, *f.MaxLength)
+	}
+	return nil
+}
+
+// isValidIPValue reports whether s is the text form of an ipaddr, ie.
+// "10.0.0.1" or "::1", or of a cidr, ie. "10.0.0.0/8"
+func isValidIPValue(s string, dt DataType) bool {
+	if dt == T_CIDR {
+		_, _, err := net.ParseCIDR(s)
+		return err == nil
+	}
+	return net.ParseIP(s) != nil
+}
+
+// isBase64 reports whether s is standard, padded base64, the encoding of
+// []byte values on the wire
+func isBase64(s string) bool {
+	_, err := base64.StdEncoding.DecodeString(s)
+	return err == nil
+}
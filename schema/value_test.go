@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarTypeValidateValue(t *testing.T) {
+	s, err := ParseSchemaJSON([]byte(`{
+		"webrpc": "v1",
+		"messages": [
+			{
+				"name": "Kind",
+				"type": "enum",
+				"fields": [{ "name": "USER", "type": "uint32", "value": "1" }]
+			},
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "id", "type": "uint64" },
+					{ "name": "kind", "type": "Kind" },
+					{ "name": "nickname", "type": "string", "optional": true }
+				]
+			}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	valid := []struct {
+		Expr  string
+		Value interface{}
+	}{
+		{"uint8", 255.0},
+		{"[]string?", []interface{}{"a", nil}},
+		{"map<uint64,bool>", map[string]interface{}{"42": true}},
+		{"User", map[string]interface{}{"id": 1.0, "kind": "USER"}},
+		{"Kind", 1.0},
+		{"union<string|uint32>", 7.0},
+		{"int64", json.Number("9223372036854775807")},
+		{"uint64", json.Number("18446744073709551615")},
+		{"int64", json.Number("-9007199254740993")},
+		{"float64", json.Number("0.5")},
+		{"union<string|uint32>", json.Number("7")},
+	}
+	for _, tc := range valid {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.NoError(t, vt.ValidateValue(tc.Value), tc.Expr)
+	}
+
+	invalid := []struct {
+		Expr  string
+		Value interface{}
+		Error string
+	}{
+		{"uint32", "42", "string value '42' is not a valid 'uint32'"},
+		{"int8", 1.5, "float64 value '1.5' is not a valid 'int8'"},
+		{"map<uint64,bool>", map[string]interface{}{"x": true}, "map key 'x': invalid integer literal 'x'"},
+		{"User", map[string]interface{}{"kind": "USER"}, "missing required field 'id' of 'User'"},
+		{"User", map[string]interface{}{"id": 1.0, "kind": "ADMIN"}, "kind: string value 'ADMIN' is not a valid 'Kind'"},
+		{"string", nil, "null is not a valid 'string'"},
+		{"int64", json.Number("9223372036854775808"), "integer literal '9223372036854775808' is out of range for int64"},
+		{"uint64", json.Number("18446744073709551616"), "integer literal '18446744073709551616' is out of range for uint64"},
+		{"uint32", json.Number("1.5"), "invalid integer literal '1.5'"},
+	}
+	for _, tc := range invalid {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.EqualError(t, vt.ValidateValue(tc.Value), tc.Error, tc.Expr)
+	}
+}
+
+func TestVarTypeValidateValueMapPairs(t *testing.T) {
+	s := newTestSchema()
+	s.ParseOptions.MapKeyWireStrategy = MapKeysAsPairs
+
+	vt := &VarType{Expr: "map<uint64,string>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.NoError(t, vt.ValidateValue([]interface{}{[]interface{}{1.0, "a"}, []interface{}{2.0, "b"}}))
+	assert.NoError(t, vt.ValidateValue([]interface{}{}))
+
+	assert.EqualError(t, vt.ValidateValue(map[string]interface{}{"1": "a"}), "map[string]interface {} value 'map[1:a]' is not a valid 'map<uint64,string>'")
+	assert.EqualError(t, vt.ValidateValue([]interface{}{[]interface{}{1.0}}), "[0]: []interface {} value '[1]' is not a [key, value] pair")
+	assert.EqualError(t, vt.ValidateValue([]interface{}{[]interface{}{-1.0, "a"}}), "[0] key: integer literal '-1' is negative, but uint64 is unsigned")
+	assert.EqualError(t, vt.ValidateValue([]interface{}{[]interface{}{1.0, 2.0}}), "[0] value: float64 value '2' is not a valid 'string'")
+
+	// string keys are always objects on the wire
+	vt = &VarType{Expr: "map<string,string>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.NoError(t, vt.ValidateValue(map[string]interface{}{"a": "b"}))
+}
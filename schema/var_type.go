@@ -2,6 +2,8 @@ package schema
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -9,15 +11,58 @@ type VarType struct {
 	Expr string   // Type, ie. map<string,map<string,uint32>> or []User
 	Type DataType // Kind, ie. map or struct
 
+	// Optional is set for types in the User? or optional<User> form
+	Optional bool
+
+	// Nullable is set for types in the nullable<User> form, for wire formats
+	// telling a present null value apart from an absent one. A type can be
+	// both, ie. nullable<User>?
+	Nullable bool
+
 	List   *VarListType
 	Map    *VarMapType
+	Result *VarResultType
+	Union  *VarUnionType
+	Money  *VarMoneyType
 	Struct *VarStructType
+
+	// Decimal holds the precision and scale of decimal<p,s> types, and is
+	// nil for a plain decimal
+	Decimal *VarDecimalType
+
+	// Range holds the bounds of ratio<min,max> types, and is nil for a plain
+	// ratio, which is bounded to 0..1
+	Range *VarRangeType
+
+	// Alias is the name of the alias the type was resolved from, ie. "Names"
+	// for a Names alias of []string. The type itself holds the expansion, so
+	// Alias is informational, and kept in rebuilt exprs with the
+	// PreserveAliases parse option.
+	Alias string
+
+	// aliasOptional and aliasNullable are set when the alias target itself
+	// is optional or nullable, to tell them apart from Names? uses
+	aliasOptional, aliasNullable bool
+
+	// opts are the parse options the type was parsed with, so rebuilt exprs
+	// keep the schema's syntax, and nil for types built programmatically
+	opts *ParseOptions
 }
 
 func (t *VarType) String() string {
 	return t.Expr
 }
 
+// IsOptional reports whether values of the type may be absent
+func (t *VarType) IsOptional() bool {
+	return t.Optional
+}
+
+// IsNullable reports whether values of the type may be an explicit null
+func (t *VarType) IsNullable() bool {
+	return t.Nullable
+}
+
 func (t *VarType) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("%q", t)), nil
 }
@@ -54,144 +99,1242 @@ func (t *VarType) Parse(schema *WebRPCSchema) error {
 	if err != nil {
 		return err
 	}
-	t.Expr = buildVarTypeExpr(t, "")
+	t.Expr = buildVarTypeExpr(t, "", schema.parseOptions())
 	return nil
 }
 
-type VarListType struct {
-	Elem *VarType
+// Equal reports whether both types have the same structure. Struct types are
+// compared by name.
+func (t *VarType) Equal(other *VarType) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	if t.Type != other.Type || t.Optional != other.Optional || t.Nullable != other.Nullable {
+		return false
+	}
+
+	switch t.Type {
+	case T_List:
+		return t.List.Unique == other.List.Unique && t.List.Elem.Equal(other.List.Elem)
+	case T_Map:
+		return t.Map.Key == other.Map.Key && t.Map.Value.Equal(other.Map.Value)
+	case T_Result:
+		return t.Result.Ok.Equal(other.Result.Ok) && t.Result.Err.Equal(other.Result.Err)
+	case T_Union:
+		if t.Union.Discriminator != other.Union.Discriminator || len(t.Union.Variants) != len(other.Union.Variants) {
+			return false
+		}
+		for i, variant := range t.Union.Variants {
+			if !variant.Equal(other.Union.Variants[i]) {
+				return false
+			}
+		}
+		return true
+	case T_Money:
+		return t.Money.Currency == other.Money.Currency
+	case T_Decimal:
+		return t.Decimal.equal(other.Decimal)
+	case T_Ratio:
+		return t.Range.equal(other.Range)
+	case T_Struct:
+		return t.Struct.Name == other.Struct.Name
+	default:
+		return true
+	}
 }
 
-type VarMapType struct {
-	Key   DataType // see, VarMapKeyDataTypes -- only T_String or T_XintX supported
-	Value *VarType
+// BaseEqual reports whether both types are Equal once their own optional and
+// nullable flags are ignored, ie. User and User? or nullable<User>, so
+// generators can share a single definition between them. Flags of nested
+// types still count, as []User and []User? hold different elements.
+func (t *VarType) BaseEqual(other *VarType) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	a, b := *t, *other
+	a.Optional, a.Nullable = false, false
+	b.Optional, b.Nullable = false, false
+	return a.Equal(&b)
 }
 
-type VarStructType struct {
-	Name    string
-	Message *Message
+// CompareVarType returns -1, 0 or 1 as a sorts before, equal to or after b,
+// for a stable order of types, ie. with sort.Slice. Types are ordered by data
+// type, with struct references last, then required before optional, then by
+// their sub-types and finally by struct name. Types that are Equal compare as
+// 0, and nil sorts first.
+func CompareVarType(a, b *VarType) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if a.Type != b.Type {
+		return compareInts(dataTypeRank(a.Type), dataTypeRank(b.Type))
+	}
+	if a.Optional != b.Optional {
+		if a.Optional {
+			return 1
+		}
+		return -1
+	}
+	if a.Nullable != b.Nullable {
+		if a.Nullable {
+			return 1
+		}
+		return -1
+	}
+
+	switch a.Type {
+	case T_List:
+		if a.List.Unique != b.List.Unique {
+			if a.List.Unique {
+				return 1
+			}
+			return -1
+		}
+		return CompareVarType(a.List.Elem, b.List.Elem)
+	case T_Map:
+		if a.Map.Key != b.Map.Key {
+			return compareInts(int(a.Map.Key), int(b.Map.Key))
+		}
+		return CompareVarType(a.Map.Value, b.Map.Value)
+	case T_Result:
+		if c := CompareVarType(a.Result.Ok, b.Result.Ok); c != 0 {
+			return c
+		}
+		return CompareVarType(a.Result.Err, b.Result.Err)
+	case T_Union:
+		if c := strings.Compare(a.Union.Discriminator, b.Union.Discriminator); c != 0 {
+			return c
+		}
+		for i := 0; i < len(a.Union.Variants) && i < len(b.Union.Variants); i++ {
+			if c := CompareVarType(a.Union.Variants[i], b.Union.Variants[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(a.Union.Variants), len(b.Union.Variants))
+	case T_Money:
+		return strings.Compare(a.Money.Currency, b.Money.Currency)
+	case T_Decimal:
+		if a.Decimal == nil || b.Decimal == nil {
+			return compareInts(boolInt(a.Decimal != nil), boolInt(b.Decimal != nil))
+		}
+		if c := compareInts(a.Decimal.Precision, b.Decimal.Precision); c != 0 {
+			return c
+		}
+		return compareInts(a.Decimal.Scale, b.Decimal.Scale)
+	case T_Ratio:
+		aMin, aMax := a.Range.bounds()
+		bMin, bMax := b.Range.bounds()
+		if c := compareFloats(aMin, bMin); c != 0 {
+			return c
+		}
+		return compareFloats(aMax, bMax)
+	case T_Struct:
+		return strings.Compare(a.Struct.Name, b.Struct.Name)
+	default:
+		return 0
+	}
 }
 
-func ParseVarTypeExpr(schema *WebRPCSchema, expr string, vt *VarType) error {
-	if expr == "" {
-		return nil
+// dataTypeRank orders data types for CompareVarType, by value except for
+// struct references, which sort after every other data type, including the
+// ones appended after T_Struct
+func dataTypeRank(dt DataType) int {
+	if dt == T_Struct {
+		return int(numDataTypes)
 	}
-	vt.Expr = expr
+	return int(dt)
+}
 
-	// parse data type from string
-	dataType, ok := DataTypeFromString[expr]
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
 
-	if !ok {
-		// test for complex datatype
-		if isListExpr(expr) {
-			dataType = T_List
-		} else if isMapExpr(expr) {
-			dataType = T_Map
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// TypeAtPath returns the type at the dotted path from t, where a segment is
+// either a struct field name, or `[]` for a list element or `{}` for a map
+// value, ie. "addresses[].zip" or "profile.name". An empty path returns t.
+// Struct types must be resolved to navigate into their fields.
+func (t *VarType) TypeAtPath(path string) (*VarType, error) {
+	vt := t
+	walked := ""
+	for rest := path; rest != ""; {
+		var segment string
+		switch {
+		case strings.HasPrefix(rest, "[]"), strings.HasPrefix(rest, "{}"):
+			segment, rest = rest[:2], rest[2:]
+		default:
+			if walked != "" {
+				if rest[0] != '.' {
+					return nil, fmt.Errorf("invalid path '%s': expecting '.', '[]' or '{}' after '%s'", path, walked)
+				}
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[{")
+			if end < 0 {
+				end = len(rest)
+			}
+			segment, rest = rest[:end], rest[end:]
+			if segment == "" {
+				return nil, fmt.Errorf("invalid path '%s': empty field name after '%s'", path, walked)
+			}
+		}
+
+		next, err := vt.typeAtSegment(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path '%s': %v", path, err)
+		}
+		vt = next
+		if segment[0] == '[' || segment[0] == '{' || walked == "" {
+			walked += segment
+		} else {
+			walked += "." + segment
 		}
 	}
+	return vt, nil
+}
 
-	// Set core data type
-	vt.Type = dataType
+func (t *VarType) typeAtSegment(segment string) (*VarType, error) {
+	switch segment {
+	case "[]":
+		if t.Type != T_List || t.List == nil {
+			return nil, fmt.Errorf("'%s' is not a list", t)
+		}
+		return t.List.Elem, nil
+	case "{}":
+		if t.Type != T_Map || t.Map == nil {
+			return nil, fmt.Errorf("'%s' is not a map", t)
+		}
+		return t.Map.Value, nil
+	}
 
-	// For complex types, keep parsing
-	switch vt.Type {
+	if t.Type != T_Struct || t.Struct == nil {
+		return nil, fmt.Errorf("'%s' has no field '%s'", t, segment)
+	}
+	if t.Struct.Message == nil {
+		return nil, fmt.Errorf("struct '%s' is not resolved", t.Struct.Name)
+	}
+	for _, field := range t.Struct.Message.Fields {
+		if string(field.Name) == segment {
+			return field.Type, nil
+		}
+	}
+	return nil, fmt.Errorf("%s '%s' has no field '%s'", t.Struct.Message.Type, t.Struct.Name, segment)
+}
+
+// IsSupersetOf reports whether t can stand in for other in a response without
+// breaking existing clients. Struct types are compared structurally: t must
+// have all of other's fields with compatible types, and any extra fields must
+// be optional. A field that is required in other must be required in t.
+// Nested struct fields are compared recursively, other types must be Equal.
+func (t *VarType) IsSupersetOf(other *VarType) bool {
+	return t.isSupersetOf(other, map[[2]*Message]bool{})
+}
+
+func (t *VarType) isSupersetOf(other *VarType, seen map[[2]*Message]bool) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	if t.Type != other.Type || (t.Optional && !other.Optional) || (t.Nullable && !other.Nullable) {
+		return false
+	}
+
+	switch t.Type {
 	case T_List:
-		// create sub-type object for list element
-		vt.List = &VarListType{Elem: &VarType{}}
+		// a plain list accepts the values of a unique list, not vice versa
+		if t.List.Unique && !other.List.Unique {
+			return false
+		}
+		return t.List.Elem.isSupersetOf(other.List.Elem, seen)
+	case T_Map:
+		return t.Map.Key == other.Map.Key && t.Map.Value.isSupersetOf(other.Map.Value, seen)
+	case T_Result:
+		return t.Result.Ok.isSupersetOf(other.Result.Ok, seen) && t.Result.Err.isSupersetOf(other.Result.Err, seen)
+	case T_Union:
+		return t.Equal(other)
+	case T_Money:
+		return t.Money.Currency == other.Money.Currency
+	case T_Decimal:
+		// a plain decimal accepts any precision
+		return t.Decimal == nil || t.Decimal.equal(other.Decimal)
+	case T_Ratio:
+		min, max := t.Range.bounds()
+		otherMin, otherMax := other.Range.bounds()
+		return min <= otherMin && max >= otherMax
+	case T_Struct:
+		a, b := t.Struct.Message, other.Struct.Message
+		if a == nil || b == nil || a.Type != "struct" || b.Type != "struct" {
+			return t.Struct.Name == other.Struct.Name
+		}
+		return a.isSupersetOf(b, seen)
+	default:
+		return true
+	}
+}
 
-		// shift expr, and keep parsing
-		expr = strings.TrimPrefix(expr, DataTypeToString[T_List])
-		err := ParseVarTypeExpr(schema, expr, vt.List.Elem)
-		if err != nil {
-			return err
+func (m *Message) isSupersetOf(other *Message, seen map[[2]*Message]bool) bool {
+	pair := [2]*Message{m, other}
+	if seen[pair] {
+		return true
+	}
+	seen[pair] = true
+
+	fields := map[VarName]*MessageField{}
+	for _, field := range m.Fields {
+		fields[field.Name] = field
+	}
+	for _, otherField := range other.Fields {
+		field, ok := fields[otherField.Name]
+		if !ok {
+			return false
 		}
+		if field.Optional && !otherField.Optional {
+			return false
+		}
+		if !field.Type.isSupersetOf(otherField.Type, seen) {
+			return false
+		}
+		delete(fields, otherField.Name)
+	}
+	for _, field := range fields {
+		if !field.Optional && !field.Type.Optional {
+			return false
+		}
+	}
+	return true
+}
 
+// Walk traverses the type tree depth-first, calling fn for t and each of its
+// sub-types. If fn returns false, the children of that node are skipped.
+// Struct types are leaves, as Walk does not descend into message fields.
+func (t *VarType) Walk(fn func(t *VarType) bool) {
+	if t == nil || !fn(t) {
+		return
+	}
+	switch t.Type {
+	case T_List:
+		if t.List != nil {
+			t.List.Elem.Walk(fn)
+		}
 	case T_Map:
-		// parse map expr
-		key, value, err := parseMapExpr(expr)
-		if err != nil {
-			return err
+		if t.Map != nil {
+			t.Map.Value.Walk(fn)
+		}
+	case T_Result:
+		if t.Result != nil {
+			t.Result.Ok.Walk(fn)
+			t.Result.Err.Walk(fn)
+		}
+	case T_Union:
+		if t.Union != nil {
+			for _, variant := range t.Union.Variants {
+				variant.Walk(fn)
+			}
 		}
+	}
+}
 
-		keyDataType, ok := DataTypeFromString[key]
-		if !ok {
-			return fmt.Errorf("schema error: invalid map key type '%s' for expr '%s'", key, expr)
+// MapChain returns the key types of directly nested maps and the terminal
+// value type, ie. [string, int] and User for map<string,map<int,User>>.
+// Non-map types return an empty slice and the type itself.
+func (t *VarType) MapChain() ([]DataType, *VarType) {
+	keys := []DataType{}
+	for t.Type == T_Map && t.Map != nil {
+		keys = append(keys, t.Map.Key)
+		t = t.Map.Value
+	}
+	return keys, t
+}
+
+// UnionVariants returns the variant types of a union, with directly nested
+// unions flattened into their own variants, ie. [User, []string, Error] for
+// union<User|union<[]string|Error>>. Optional nested unions are kept, as
+// they also accept null. It returns nil for types other than unions.
+func (t *VarType) UnionVariants() []*VarType {
+	if t.Type != T_Union || t.Union == nil {
+		return nil
+	}
+	variants := []*VarType{}
+	for _, variant := range t.Union.Variants {
+		if variant.Type == T_Union && !variant.Optional && variant.Union.Discriminator == "" {
+			variants = append(variants, variant.UnionVariants()...)
+			continue
 		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
 
-		// create sub-type object for map
-		vt.Map = &VarMapType{Key: keyDataType, Value: &VarType{}}
+// IsFullyResolved reports whether every struct reference in the type tree has
+// its message resolved, so generators can safely dereference it. Unparsed
+// types are never resolved.
+func (t *VarType) IsFullyResolved() bool {
+	resolved := true
+	t.Walk(func(vt *VarType) bool {
+		switch vt.Type {
+		case T_Unknown:
+			resolved = false
+		case T_Struct:
+			if vt.Struct == nil || vt.Struct.Message == nil {
+				resolved = false
+			}
+		}
+		return resolved
+	})
+	return resolved
+}
 
-		// shift expr and keep parsing
-		expr = value
-		err = ParseVarTypeExpr(schema, expr, vt.Map.Value)
-		if err != nil {
-			return err
+// IsBytes reports whether the type is a list of bytes, ie. []byte or []uint8,
+// which is a base64 string on the wire, the way Go's encoding/json encodes
+// []byte. Lists of optional or nullable bytes stay lists of numbers.
+func (t *VarType) IsBytes() bool {
+	if t.Type != T_List || t.List == nil || t.List.Elem == nil {
+		return false
+	}
+	elem := t.List.Elem
+	return !elem.Optional && !elem.Nullable && (elem.Type == T_Byte || elem.Type == T_Uint8)
+}
+
+// HasEmptyValue reports whether the type has a meaningful empty value that an
+// omitempty tag can omit, ie. "" for strings, 0 for numbers and enums, false
+// for bools, and empty lists and maps. Required structs, and types encoded as
+// objects such as timestamps, money and geopoints, have no empty value.
+// Optional types are empty when null.
+func (t *VarType) HasEmptyValue() bool {
+	if t.Optional || t.Nullable {
+		return true
+	}
+	switch t.Type {
+	case T_Struct:
+		return t.Enum() != nil
+	case T_Timestamp, T_DateTime, T_Money, T_GeoPoint, T_Unknown, T_Void:
+		return false
+	default:
+		return true
+	}
+}
+
+// NeedsCustomJSON reports whether the type tree holds a value that standard
+// JSON encoders don't handle on their own, so generators can emit custom
+// (un)marshalers: a map with non-string keys, a union, a bigint, or a
+// timestamp or datetime. Struct types don't descend into message fields, see
+// Message.NeedsCustomJSON.
+func (t *VarType) NeedsCustomJSON() bool {
+	needs := false
+	t.Walk(func(vt *VarType) bool {
+		switch vt.Type {
+		case T_Map:
+			if vt.Map != nil && (!isStringWireKey(vt.Map.Key) || vt.Map.Key == T_BigInt) {
+				needs = true
+			}
+		case T_Union, T_BigInt, T_Timestamp, T_DateTime, T_CIDR:
+			needs = true
 		}
+		return !needs
+	})
+	return needs
+}
+
+// Validate checks that the type tree is internally consistent, ie. that Type
+// matches the populated sub-type, so programmatically constructed types can
+// be verified before they reach generators. It does not resolve messages.
+func (t *VarType) Validate() error {
+	if t == nil {
+		return fmt.Errorf("invalid type: type is nil")
+	}
+
+	var populated []string
+	if t.List != nil {
+		populated = append(populated, "list")
+	}
+	if t.Map != nil {
+		populated = append(populated, "map")
+	}
+	if t.Result != nil {
+		populated = append(populated, "result")
+	}
+	if t.Union != nil {
+		populated = append(populated, "union")
+	}
+	if t.Money != nil {
+		populated = append(populated, "money")
+	}
+	if t.Decimal != nil {
+		populated = append(populated, "decimal")
+	}
+	if t.Range != nil {
+		populated = append(populated, "range")
+	}
+	if t.Struct != nil {
+		populated = append(populated, "struct")
+	}
+	if len(populated) > 1 {
+		return fmt.Errorf("invalid type '%s': has conflicting %s sub-types", t.Expr, strings.Join(populated, " and "))
+	}
 
+	switch t.Type {
 	case T_Unknown:
+		return fmt.Errorf("invalid type '%s': unknown data type", t.Expr)
+	case T_List:
+		if t.List == nil || t.List.Elem == nil {
+			return fmt.Errorf("invalid type '%s': list is missing its element type", t.Expr)
+		}
+		return t.List.Elem.Validate()
+	case T_Map:
+		if t.Map == nil || t.Map.Value == nil {
+			return fmt.Errorf("invalid type '%s': map is missing its value type", t.Expr)
+		}
+		if err := ValidateMapKey(t.Map.Key); err != nil {
+			return fmt.Errorf("invalid type '%s': %v", t.Expr, err)
+		}
+		return t.Map.Value.Validate()
+	case T_Result:
+		if t.Result == nil || t.Result.Ok == nil || t.Result.Err == nil {
+			return fmt.Errorf("invalid type '%s': result is missing its ok or error type", t.Expr)
+		}
+		if err := t.Result.Ok.Validate(); err != nil {
+			return err
+		}
+		return t.Result.Err.Validate()
+	case T_Union:
+		if t.Union == nil || len(t.Union.Variants) < 2 {
+			return fmt.Errorf("invalid type '%s': union needs at least two variants", t.Expr)
+		}
+		for _, variant := range t.Union.Variants {
+			if err := variant.Validate(); err != nil {
+				return err
+			}
+			if t.Union.Discriminator != "" && !variant.isStructMessage() {
+				return fmt.Errorf("invalid type '%s': variant '%s' of a discriminated union must be a struct", t.Expr, variant.Expr)
+			}
+		}
+		return nil
+	case T_Money:
+		if t.Money == nil || !isValidCurrencyCode(t.Money.Currency) {
+			return fmt.Errorf("invalid type '%s': money is missing a valid currency code", t.Expr)
+		}
+		return nil
+	case T_Decimal:
+		if t.Decimal != nil && t.Decimal.validate() != nil {
+			return fmt.Errorf("invalid type '%s': %v", t.Expr, t.Decimal.validate())
+		}
+		return nil
+	case T_Ratio:
+		if t.Range != nil && t.Range.validate() != nil {
+			return fmt.Errorf("invalid type '%s': %v", t.Expr, t.Range.validate())
+		}
+		return nil
+	case T_Struct:
+		if t.Struct == nil || t.Struct.Name == "" {
+			return fmt.Errorf("invalid type '%s': struct is missing its name", t.Expr)
+		}
+		return nil
+	default:
+		if len(populated) > 0 {
+			return fmt.Errorf("invalid type '%s': %s type has a %s sub-type", t.Expr, t.Type, populated[0])
+		}
+		return nil
+	}
+}
+
+// ParseError is returned when a type expression cannot be parsed
+type ParseError struct {
+	Code ErrorCode // kind of failure, ie. ErrUnknownType
+	Expr string    // the (sub-)expression which failed to parse
+	msg  string
 
-		structExpr := expr
-		msg, ok := getMessageType(schema, structExpr)
-		if !ok || msg == nil {
-			return fmt.Errorf("schema error: invalid struct/message type '%s'", structExpr)
+	minimal string
+}
+
+// ErrorCode classifies schema errors, see ParseError and CheckError
+type ErrorCode string
+
+const (
+	ErrInvalidSyntax ErrorCode = "invalid-syntax"
+	ErrInvalidMapKey ErrorCode = "invalid-map-key"
+	ErrUnknownType   ErrorCode = "unknown-type"
+
+	ErrRedundantOptional ErrorCode = "redundant-optional"
+	ErrInvalidVoid       ErrorCode = "invalid-void"
+	ErrOptionalMapKey    ErrorCode = "optional-map-key"
+
+	ErrInvalidUnionVariant ErrorCode = "invalid-union-variant"
+	ErrInvalidStream       ErrorCode = "invalid-stream"
+)
+
+func (e *ParseError) Error() string {
+	return e.msg
+}
+
+// MinimalExpr returns the smallest sub-expression of Expr reproducing the
+// failure, ie. map<float64,int> for an invalid map key deep in
+// map<string,[]map<float64,int>>. It's Expr itself when the failure isn't
+// nested in a type.
+func (e *ParseError) MinimalExpr() string {
+	if e.minimal == "" {
+		return e.Expr
+	}
+	return e.minimal
+}
+
+func newParseError(code ErrorCode, expr string, format string, args ...interface{}) *ParseError {
+	return &ParseError{Code: code, Expr: expr, msg: fmt.Sprintf(format, args...)}
+}
+
+// Unwrap returns a copy of the type without optionality or nullability,
+// leaving the receiver untouched. The copy shares its sub-types with the
+// receiver.
+func (t *VarType) Unwrap() *VarType {
+	if !t.Optional && !t.Nullable {
+		return t
+	}
+	base := *t
+	base.Optional = false
+	base.Nullable = false
+	base.Expr = buildVarTypeExpr(&base, "", base.parseOptions())
+	return &base
+}
+
+// clone deep-copies the type tree, pointing struct references at the copy of
+// their message where messages has one
+func (t *VarType) clone(messages map[*Message]*Message) *VarType {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	if t.List != nil {
+		list := *t.List
+		list.Elem = t.List.Elem.clone(messages)
+		c.List = &list
+	}
+	if t.Map != nil {
+		m := *t.Map
+		m.Value = t.Map.Value.clone(messages)
+		c.Map = &m
+	}
+	if t.Result != nil {
+		c.Result = &VarResultType{Ok: t.Result.Ok.clone(messages), Err: t.Result.Err.clone(messages)}
+	}
+	if t.Union != nil {
+		union := *t.Union
+		union.Variants = make([]*VarType, 0, len(t.Union.Variants))
+		for _, variant := range t.Union.Variants {
+			union.Variants = append(union.Variants, variant.clone(messages))
+		}
+		c.Union = &union
+	}
+	if t.Money != nil {
+		money := *t.Money
+		c.Money = &money
+	}
+	if t.Decimal != nil {
+		decimal := *t.Decimal
+		c.Decimal = &decimal
+	}
+	if t.Range != nil {
+		r := *t.Range
+		c.Range = &r
+	}
+	if t.Struct != nil {
+		st := *t.Struct
+		if msg, ok := messages[st.Message]; ok {
+			st.Message = msg
 		}
+		c.Struct = &st
+	}
+	return &c
+}
 
-		vt.Type = T_Struct
-		vt.Struct = &VarStructType{Name: structExpr, Message: msg}
+// parseOptions returns the options the type was parsed with, or the default
+// syntax for types built programmatically
+func (t *VarType) parseOptions() ParseOptions {
+	if t == nil || t.opts == nil {
+		return ParseOptions{}
+	}
+	return *t.opts
+}
 
+// WireShape renders the JSON shape of values of the type, for documentation,
+// ie. `{"<string>": [ {User} ]}` for map<string,[]User>
+func (t *VarType) WireShape() string {
+	if t.Optional {
+		base := *t
+		base.Optional = false
+		return base.WireShape() + " | null"
+	}
+	if t.Nullable {
+		base := *t
+		base.Nullable = false
+		return base.WireShape() + " | null"
+	}
+
+	if t.IsBytes() {
+		return `"<base64>"`
+	}
+
+	switch t.Type {
+	case T_List:
+		return fmt.Sprintf("[ %s ]", t.List.Elem.WireShape())
+	case T_Map:
+		if t.MapKeyWireStrategy() == MapKeysAsPairs {
+			return fmt.Sprintf("[ [<%s>, %s] ]", t.Map.Key, t.Map.Value.WireShape())
+		}
+		return fmt.Sprintf(`{"<%s>": %s}`, t.Map.Key, t.Map.Value.WireShape())
+	case T_Result:
+		return fmt.Sprintf("%s | %s", t.Result.Ok.WireShape(), t.Result.Err.WireShape())
+	case T_Union:
+		shapes := make([]string, 0, len(t.Union.Variants))
+		for _, variant := range t.Union.Variants {
+			if t.Union.Discriminator != "" {
+				shapes = append(shapes, fmt.Sprintf(`{"%s": "%s", ...%s}`, t.Union.Discriminator, variant.Struct.Name, variant.Struct.Name))
+				continue
+			}
+			shapes = append(shapes, variant.WireShape())
+		}
+		return strings.Join(shapes, " | ")
+	case T_Money:
+		return fmt.Sprintf(`{"amount": "<decimal>", "currency": "%s"}`, t.Money.Currency)
+	case T_Struct:
+		return fmt.Sprintf("{%s}", t.Struct.Name)
+	case T_Null:
+		return "null"
+	case T_GeoPoint:
+		return `{"lat": <float64>, "lng": <float64>}`
+	case T_Blob:
+		return `"<blob reference>"`
+	case T_String, T_Timestamp, T_Date, T_Time, T_DateTime, T_BigInt, T_Rational, T_Decimal, T_UUID, T_IPAddr, T_CIDR:
+		return fmt.Sprintf(`"<%s>"`, t.Type)
 	default:
-		// basic type, we're done here
+		return fmt.Sprintf("<%s>", t.Type)
+	}
+}
+
+type VarListType struct {
+	Elem *VarType
+
+	// Unique marks a list which must not hold duplicate elements, ie.
+	// uniquelist<string>, for generators and validators to enforce. Unlike a
+	// set, the order of the elements is kept.
+	Unique bool
+}
+
+type VarMapType struct {
+	Key   DataType // see, VarMapKeyDataTypes -- only T_String or T_XintX supported
+	Value *VarType
+
+	// KeyWireStrategy is the JSON encoding of the map, set from ParseOptions
+	// for keys that aren't strings on the wire
+	KeyWireStrategy MapKeyWireStrategy
+}
+
+// isStringWireKey reports whether the map key data type is encoded as a JSON
+// string, and so is a native JSON object key
+func isStringWireKey(dt DataType) bool {
+	switch dt {
+	case T_String, T_UUID, T_Date, T_BigInt, T_IPAddr, T_CIDR:
+		return true
 	}
+	return false
+}
+
+// MapKeyWireStrategy returns the JSON encoding of a map type, which is always
+// MapKeysAsStrings for keys that are strings on the wire. It returns an empty
+// strategy for types other than maps.
+func (t *VarType) MapKeyWireStrategy() MapKeyWireStrategy {
+	if t == nil || t.Type != T_Map || t.Map == nil {
+		return ""
+	}
+	if isStringWireKey(t.Map.Key) || t.Map.KeyWireStrategy == "" {
+		return MapKeysAsStrings
+	}
+	return t.Map.KeyWireStrategy
+}
+
+// VarResultType models a success/error union, ie. result<User,Error>
+type VarResultType struct {
+	Ok  *VarType
+	Err *VarType
+}
+
+// VarUnionType is a value of any one of its variant types, ie. union<A|B>
+type VarUnionType struct {
+	Variants []*VarType
+
+	// Discriminator is the name of the field selecting the variant in a
+	// discriminated union, ie. "type" for union<type:Cat|Dog>. The value of
+	// the field is the variant struct name, and every variant is a struct.
+	Discriminator string
+}
+
+// Enum returns the enum message referenced by the type, or nil for any other
+// type, including unresolved references. Enums are struct references to an
+// enum message, so list elements and map values of enums are found through
+// Enum, ie. vt.List.Elem.Enum() of []Color.
+func (t *VarType) Enum() *Message {
+	if t.Type != T_Struct || t.Struct == nil || t.Struct.Message == nil || t.Struct.Message.Type != "enum" {
+		return nil
+	}
+	return t.Struct.Message
+}
+
+// isStructMessage reports whether the type is a required reference to a
+// struct message, as opposed to an enum
+func (t *VarType) isStructMessage() bool {
+	return !t.Optional && t.Type == T_Struct && t.Struct != nil && t.Struct.Message != nil && t.Struct.Message.Type == "struct"
+}
+
+// VarDecimalType is the precision and scale of a decimal<p,s> type, ie.
+// decimal<10,2> for numbers of up to 10 digits, 2 of which after the decimal
+// point. On the wire, decimals are strings, ie. "12.50".
+type VarDecimalType struct {
+	Precision int
+	Scale     int
+}
 
+// maxDecimalPrecision is the largest precision of decimal<p,s> types, as
+// supported by most SQL databases
+const maxDecimalPrecision = 1000
+
+func (d *VarDecimalType) validate() error {
+	if d.Precision < 1 || d.Precision > maxDecimalPrecision {
+		return fmt.Errorf("decimal precision must be between 1 and %d", maxDecimalPrecision)
+	}
+	if d.Scale < 0 || d.Scale > d.Precision {
+		return fmt.Errorf("decimal scale must be between 0 and the precision")
+	}
 	return nil
 }
 
-func parseMapExpr(expr string) (string, string, error) {
-	if !isMapExpr(expr) {
-		return "", "", fmt.Errorf("schema error: invalid map expr for '%s'", expr)
+func (d *VarDecimalType) equal(other *VarDecimalType) bool {
+	if d == nil || other == nil {
+		return d == other
 	}
+	return *d == *other
+}
 
-	mapKeyword := DataTypeToString[T_Map]
-	expr = expr[len(mapKeyword):]
+// VarRangeType is the inclusive bounds of a ratio<min,max> type, ie.
+// ratio<0,100> for a percentage
+type VarRangeType struct {
+	Min float64
+	Max float64
+}
 
-	if expr[0:1] != "<" {
-		return "", "", fmt.Errorf("schema error: invalid map syntax for '%s'", expr)
+func (r *VarRangeType) validate() error {
+	if math.IsNaN(r.Min) || math.IsInf(r.Min, 0) || math.IsNaN(r.Max) || math.IsInf(r.Max, 0) {
+		return fmt.Errorf("ratio bounds must be finite numbers")
 	}
-	if expr[len(expr)-1:] != ">" {
-		return "", "", fmt.Errorf("schema error: invalid map syntax for '%s'", expr)
+	if r.Min >= r.Max {
+		return fmt.Errorf("ratio minimum %v must be less than the maximum %v", r.Min, r.Max)
 	}
-	expr = expr[1 : len(expr)-1]
+	return nil
+}
 
-	p := strings.Index(expr, ",")
-	if p < 0 {
-		return "", "", fmt.Errorf("schema error: invalid map syntax for '%s'", expr)
+// bounds returns the range, defaulting to 0..1 of a plain ratio
+func (r *VarRangeType) bounds() (float64, float64) {
+	if r == nil {
+		return 0, 1
 	}
+	return r.Min, r.Max
+}
+
+// check returns an error if the value is out of the range
+func (r *VarRangeType) check(f float64) error {
+	min, max := r.bounds()
+	if math.IsNaN(f) || f < min || f > max {
+		return fmt.Errorf("value %v is out of range %v..%v", f, min, max)
+	}
+	return nil
+}
 
-	key := expr[0:p]
-	value := expr[p+1:]
+func (r *VarRangeType) equal(other *VarRangeType) bool {
+	aMin, aMax := r.bounds()
+	bMin, bMax := other.bounds()
+	return aMin == bMin && aMax == bMax
+}
 
-	if !isValidVarKeyType(key) {
-		return "", "", fmt.Errorf("schema error: invalid map key '%s' for '%s'", key, expr)
+func boolInt(b bool) int {
+	if b {
+		return 1
 	}
+	return 0
+}
+
+// VarMoneyType is an amount of money in the given ISO 4217 currency, ie.
+// money<USD>. On the wire it's an object with the amount as a decimal string,
+// ie. {"amount": "12.50", "currency": "USD"}.
+type VarMoneyType struct {
+	Currency string
+}
+
+// isValidCurrencyCode reports whether code is in the form of an ISO 4217
+// currency code, ie. 3 uppercase letters
+func isValidCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
 
-	return key, value, nil
+type VarStructType struct {
+	Name    string
+	Message *Message
 }
 
-func buildVarTypeExpr(vt *VarType, expr string) string {
+// Description returns the description of the referenced message, or an empty
+// string when the message is not resolved yet.
+func (s *VarStructType) Description() string {
+	if s == nil || s.Message == nil {
+		return ""
+	}
+	return s.Message.Description
+}
+
+// ParseOptions controls how type expressions are parsed and rebuilt
+type ParseOptions struct {
+	// OptionalKeyword makes optional<T> the canonical form of optional types,
+	// instead of the T? suffix form. Optional lists always use optional<[]T>,
+	// as []T? denotes a list of optional elements.
+	OptionalKeyword bool
+
+	// ListKeyword and MapKeyword replace the default `[]` and `map` spellings
+	// for alternate front-ends. A custom list keyword takes the generic form,
+	// ie. array<T> for "array".
+	ListKeyword string
+	MapKeyword  string
+
+	// StripComments ignores a trailing // comment in type exprs, ie.
+	// "[]User // the users", for exprs taken from commented sources. Slashes
+	// in `escaped` names are kept.
+	StripComments bool
+
+	// PreserveAliases keeps alias names in rebuilt exprs, ie.
+	// map<string,Names>, instead of spelling out their target type, so
+	// schemas round-trip with their aliases. Map keys are always spelled out.
+	PreserveAliases bool
+
+	// GenericLists makes list<T> the canonical form of lists, instead of []T,
+	// for tooling coming from IDLs with generic list syntax. Both forms are
+	// parsed either way. It doesn't apply to a custom ListKeyword.
+	GenericLists bool
+
+	// PreserveByte keeps `byte` as its own data type, instead of parsing it
+	// as a synonym of uint8
+	PreserveByte bool
+
+	// RejectOptionalContainers makes optional lists and maps a parse error,
+	// see LintOptionalContainers
+	RejectOptionalContainers bool
+
+	// LegacyParsing restores the whitespace handling of the original string
+	// slicing parser, for schemas migrating to the current parser. Whitespace
+	// is then significant and becomes part of type names, so that ie.
+	// map<string, User> fails to resolve the ' User' message instead of being
+	// parsed as map<string,User>.
+	LegacyParsing bool
+
+	// StrictSeparators rejects a trailing separator before the closing '>',
+	// ie. map<string,int,> or union<A|B|>, at the LenientParsing level,
+	// which otherwise ignores them, keeping its other ergonomics. The
+	// StrictParsing level always rejects them.
+	StrictSeparators bool
+
+	// MapKeyWireStrategy sets how maps with integer keys are encoded in JSON,
+	// which only allows string object keys. Defaults to MapKeysAsStrings.
+	MapKeyWireStrategy MapKeyWireStrategy
+
+	// Strictness sets how forgiving the parser is with hand-written type
+	// exprs, see StrictParsing and LenientParsing. The zero value is
+	// StrictParsing.
+	Strictness Strictness
+}
+
+// Strictness is a parser leniency level, grouping the ergonomic toggles of
+// ParseOptions into a single setting
+type Strictness string
+
+const (
+	// StrictParsing only accepts canonical spellings: primitive names are
+	// case-sensitive, a type can be made optional once, and trailing
+	// separators are rejected
+	StrictParsing Strictness = "strict"
+
+	// LenientParsing accepts primitive names in any case, ie. String or
+	// UINT64, collapses redundant optionals, ie. string?? or
+	// optional<string?>, and ignores trailing separators. Whitespace between
+	// tokens is accepted at any level, unless LegacyParsing is set.
+	LenientParsing Strictness = "lenient"
+)
+
+func (o ParseOptions) lenient() bool {
+	return o.Strictness == LenientParsing
+}
+
+func (o ParseOptions) strictSeparators() bool {
+	return o.StrictSeparators || !o.lenient()
+}
+
+// MapKeyWireStrategy is the JSON encoding of maps with non-string keys
+type MapKeyWireStrategy string
+
+const (
+	// MapKeysAsStrings encodes a map as an object with its keys coerced to
+	// strings, ie. {"42": {...}}
+	MapKeysAsStrings MapKeyWireStrategy = "string"
+
+	// MapKeysAsPairs encodes a map as an array of key/value pairs, ie.
+	// [[42, {...}]]
+	MapKeysAsPairs MapKeyWireStrategy = "pairs"
+)
+
+func (o ParseOptions) mapKeyWireStrategy() MapKeyWireStrategy {
+	if o.MapKeyWireStrategy == "" {
+		return MapKeysAsStrings
+	}
+	return o.MapKeyWireStrategy
+}
+
+// validate checks the options are consistent with each other
+func (o ParseOptions) validate() error {
+	switch o.mapKeyWireStrategy() {
+	case MapKeysAsStrings, MapKeysAsPairs:
+	default:
+		return fmt.Errorf("schema error: invalid map key wire strategy '%s', must be one of %s, %s", o.MapKeyWireStrategy, MapKeysAsStrings, MapKeysAsPairs)
+	}
+	if o.GenericLists && o.ListKeyword != "" {
+		return fmt.Errorf("schema error: generic lists conflict with the custom list keyword '%s'", o.ListKeyword)
+	}
+	switch o.Strictness {
+	case "", StrictParsing:
+	case LenientParsing:
+		if o.RejectOptionalContainers || o.LegacyParsing {
+			return fmt.Errorf("schema error: lenient parsing conflicts with the RejectOptionalContainers and LegacyParsing options")
+		}
+	default:
+		return fmt.Errorf("schema error: invalid parse strictness '%s', must be one of %s, %s", o.Strictness, StrictParsing, LenientParsing)
+	}
+	return nil
+}
+
+// dataType returns the data type for a basic type name, resolving synonyms
+func (o ParseOptions) dataType(name string) (DataType, bool) {
+	dt, ok := DataTypeFromString[name]
+	if ok && dt == T_Byte && !o.PreserveByte {
+		return T_Uint8, true
+	}
+	return dt, ok
+}
+
+func (o ParseOptions) listKeyword() string {
+	if o.ListKeyword == "" {
+		return DataTypeToString[T_List]
+	}
+	return o.ListKeyword
+}
+
+func (o ParseOptions) mapKeyword() string {
+	if o.MapKeyword == "" {
+		return DataTypeToString[T_Map]
+	}
+	return o.MapKeyword
+}
+
+// isGenericList reports whether lists are spelled list<T> rather than []T
+func (o ParseOptions) isGenericList() bool {
+	return o.listKeyword() != DataTypeToString[T_List]
+}
+
+func (s *WebRPCSchema) parseOptions() ParseOptions {
+	if s == nil {
+		return ParseOptions{}
+	}
+	return s.ParseOptions
+}
+
+// parseMapKey resolves the map key type, which may be given through an alias
+func parseMapKey(schema *WebRPCSchema, key string, expr string) (DataType, error) {
+	if inner, ok := parseOptionalExpr(key); ok {
+		return T_Unknown, newParseError(ErrOptionalMapKey, expr, "schema error: map key '%s' cannot be optional for '%s', as JSON object keys are always present, use '%s' instead", key, expr, inner)
+	}
+
+	if strings.HasPrefix(key, inlineEnumKeyword+"<") {
+		return T_Unknown, newParseError(ErrInvalidMapKey, expr, "schema error: inline enum '%s' is not a valid map key for '%s', as enums aren't valid map keys", key, expr)
+	}
+
+	name, escaped := unescapeTypeName(key)
+	if dt, ok := schema.parseOptions().dataType(key); ok && !escaped && ValidateMapKey(dt) == nil {
+		return dt, nil
+	}
+
+	if alias, ok := getAliasType(schema, name); ok {
+		var keyType VarType
+		err := parseAliasExpr(schema, alias, &keyType)
+		if err != nil {
+			return T_Unknown, err
+		}
+		if keyType.Optional {
+			return T_Unknown, newParseError(ErrOptionalMapKey, expr, "schema error: map key alias '%s' resolves to optional type '%s' for '%s'", key, alias.Type.Expr, expr)
+		}
+		if !isValidVarKeyType(keyType.Type.String()) {
+			return T_Unknown, newParseError(ErrInvalidMapKey, expr, "schema error: map key alias '%s' resolves to '%s', which is not a valid map key type for '%s'", key, alias.Type.Expr, expr)
+		}
+		return keyType.Type, nil
+	}
+
+	if dt, ok := lenientDataType(schema, key); ok && !escaped && ValidateMapKey(dt) == nil {
+		return dt, nil
+	}
+
+	if schema != nil {
+		if msg := schema.GetMessageByName(name); msg != nil && msg.Type == "enum" {
+			return T_Unknown, newParseError(ErrInvalidMapKey, expr, "schema error: enum '%s' is not a valid map key for '%s', as enums aren't valid map keys", key, expr)
+		}
+	}
+
+	return T_Unknown, newParseError(ErrInvalidMapKey, expr, "schema error: invalid map key '%s' for '%s'", key, expr)
+}
+
+// parseOptionalExpr returns the inner type expr of T? or optional<T>
+func parseOptionalExpr(expr string) (string, bool) {
+	if strings.HasSuffix(expr, "?") {
+		return expr[:len(expr)-1], true
+	}
+	if strings.HasPrefix(expr, optionalKeyword+"<") && strings.HasSuffix(expr, ">") {
+		return expr[len(optionalKeyword)+1 : len(expr)-1], true
+	}
+	return "", false
+}
+
+const optionalKeyword = "optional"
+
+const nullableKeyword = "nullable"
+
+// BuildExpr returns the canonical expr of a type tree, ie. map<string,[]User>,
+// so types built programmatically can set their Expr. Struct references only
+// need their Struct.Name, and aliases are spelled out as their target type.
+// Parsed types are rebuilt in the syntax of the parse options they were
+// parsed with, and other types in the default syntax.
+func BuildExpr(t *VarType) string {
+	return buildVarTypeExpr(t, "", t.parseOptions())
+}
+
+func buildVarTypeExpr(vt *VarType, expr string, opts ParseOptions) string {
+	if opts.GenericLists && opts.ListKeyword == "" {
+		// spelled the way a custom list keyword is
+		opts.ListKeyword = genericListKeyword
+	}
+
+	if opts.PreserveAliases && vt.Alias != "" {
+		name := vt.Alias
+		if vt.Nullable && !vt.aliasNullable {
+			name = fmt.Sprintf("%s<%s>", nullableKeyword, name)
+		}
+		if vt.Optional && !vt.aliasOptional {
+			if opts.OptionalKeyword {
+				return expr + fmt.Sprintf("%s<%s>", optionalKeyword, name)
+			}
+			name += "?"
+		}
+		return expr + name
+	}
+
+	if vt.Optional {
+		base := *vt
+		base.Optional = false
+		if opts.OptionalKeyword || (vt.Type == T_List && !vt.List.Unique && !opts.isGenericList()) {
+			return expr + fmt.Sprintf("%s<%s>", optionalKeyword, buildVarTypeExpr(&base, "", opts))
+		}
+		return expr + buildVarTypeExpr(&base, "", opts) + "?"
+	}
+	if vt.Nullable {
+		base := *vt
+		base.Nullable = false
+		return expr + fmt.Sprintf("%s<%s>", nullableKeyword, buildVarTypeExpr(&base, "", opts))
+	}
+
 	switch vt.Type {
 	case T_Unknown:
 		return "<unknown>"
 
 	case T_List:
-		expr += "[]" + buildVarTypeExpr(vt.List.Elem, expr)
+		if vt.List.Unique {
+			expr += fmt.Sprintf("%s<%s>", uniqueListKeyword, buildVarTypeExpr(vt.List.Elem, "", opts))
+			return expr
+		}
+		if opts.isGenericList() {
+			expr += fmt.Sprintf("%s<%s>", opts.listKeyword(), buildVarTypeExpr(vt.List.Elem, "", opts))
+			return expr
+		}
+		expr += opts.listKeyword() + buildVarTypeExpr(vt.List.Elem, expr, opts)
 		return expr
 
 	case T_Map:
-		expr += fmt.Sprintf("map<%s,%s>", vt.Map.Key, buildVarTypeExpr(vt.Map.Value, ""))
+		expr += fmt.Sprintf("%s<%s,%s>", opts.mapKeyword(), vt.Map.Key, buildVarTypeExpr(vt.Map.Value, "", opts))
+		return expr
+
+	case T_Result:
+		expr += fmt.Sprintf("result<%s,%s>", buildVarTypeExpr(vt.Result.Ok, "", opts), buildVarTypeExpr(vt.Result.Err, "", opts))
+		return expr
+
+	case T_Union:
+		variants := make([]string, 0, len(vt.Union.Variants))
+		for _, variant := range vt.Union.Variants {
+			variants = append(variants, buildVarTypeExpr(variant, "", opts))
+		}
+		discriminator := ""
+		if vt.Union.Discriminator != "" {
+			discriminator = vt.Union.Discriminator + ":"
+		}
+		expr += fmt.Sprintf("union<%s%s>", discriminator, strings.Join(variants, "|"))
 		return expr
 
+	case T_Money:
+		expr += fmt.Sprintf("money<%s>", vt.Money.Currency)
+		return expr
+
+	case T_Decimal:
+		if vt.Decimal != nil {
+			expr += fmt.Sprintf("decimal<%d,%d>", vt.Decimal.Precision, vt.Decimal.Scale)
+			return expr
+		}
+		return expr + vt.Type.String()
+
+	case T_Ratio:
+		if vt.Range != nil {
+			expr += fmt.Sprintf("ratio<%s,%s>", strconv.FormatFloat(vt.Range.Min, 'f', -1, 64), strconv.FormatFloat(vt.Range.Max, 'f', -1, 64))
+			return expr
+		}
+		return expr + vt.Type.String()
+
 	case T_Struct:
-		expr += vt.Struct.Name
+		expr += escapeTypeName(vt.Struct.Name)
 		return expr
 
 	default:
@@ -201,17 +1344,44 @@ func buildVarTypeExpr(vt *VarType, expr string) string {
 	}
 }
 
-func isListExpr(expr string) bool {
-	listTest := DataTypeToString[T_List]
-	return strings.HasPrefix(expr, listTest)
+// escapeTypeName quotes names clashing with the type syntax or keywords in
+// backticks, ie. `weird,name`. Names holding // are quoted too, so they
+// survive ParseOptions.StripComments.
+func escapeTypeName(name string) string {
+	_, isKeyword := DataTypeFromString[name]
+	if isKeyword || name == optionalKeyword || strings.IndexFunc(name, isExprDelimiter) >= 0 || strings.Contains(name, "//") {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+func unescapeTypeName(name string) (string, bool) {
+	if len(name) > 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		return name[1 : len(name)-1], true
+	}
+	return name, false
 }
 
-func isMapExpr(expr string) bool {
-	mapTest := DataTypeToString[T_Map] + "<"
-	return strings.HasPrefix(expr, mapTest)
+// lenientDataType resolves a basic type name spelled in any case, ie. String,
+// with lenient parsing. Messages and aliases of that exact name take
+// precedence, so ie. a `Date` message is never shadowed by date.
+func lenientDataType(schema *WebRPCSchema, name string) (DataType, bool) {
+	if !schema.parseOptions().lenient() {
+		return T_Unknown, false
+	}
+	if _, ok := getMessageType(schema, name); ok {
+		return T_Unknown, false
+	}
+	if _, ok := getAliasType(schema, name); ok {
+		return T_Unknown, false
+	}
+	return schema.parseOptions().dataType(strings.ToLower(name))
 }
 
 func getMessageType(schema *WebRPCSchema, structExpr string) (*Message, bool) {
+	if msg, ok := schema.messageIndex[structExpr]; ok {
+		return msg, true
+	}
 	for _, msg := range schema.Messages {
 		if structExpr == string(msg.Name) {
 			return msg, true
@@ -221,13 +1391,38 @@ func getMessageType(schema *WebRPCSchema, structExpr string) (*Message, bool) {
 }
 
 var VarKeyDataTypes = []DataType{
-	T_String, T_Uint, T_Uint8, T_Uint16, T_Uint32, T_Uint64, T_Int, T_Int8, T_Int16, T_Int32, T_Int64,
+	T_String, T_Uint, T_Uint8, T_Uint16, T_Uint32, T_Uint64, T_Int, T_Int8, T_Int16, T_Int32, T_Int64, T_BigInt, T_UUID, T_Date,
+	T_IPAddr, T_CIDR,
 }
 
 var VarIntegerDataTypes = []DataType{
 	T_Uint, T_Uint8, T_Uint16, T_Uint32, T_Uint64, T_Int, T_Int8, T_Int16, T_Int32, T_Int64,
 }
 
+// ValidateMapKey returns an error if the data type is not a legal map key,
+// see VarKeyDataTypes
+func ValidateMapKey(dt DataType) error {
+	for _, t := range VarKeyDataTypes {
+		if dt == t {
+			return nil
+		}
+	}
+
+	allowed := make([]string, 0, len(VarKeyDataTypes))
+	for _, t := range VarKeyDataTypes {
+		allowed = append(allowed, t.String())
+	}
+
+	name := dt.String()
+	if name == "" {
+		name = "<unknown>"
+		if dt == T_Struct {
+			name = "struct"
+		}
+	}
+	return fmt.Errorf("schema error: invalid map key type '%s', must be one of %s", name, strings.Join(allowed, ", "))
+}
+
 func isValidVarKeyType(s string) bool {
 	return isValidVarType(s, VarKeyDataTypes)
 }
@@ -0,0 +1,770 @@
+package schema
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type exprTokenType int
+
+const (
+	exprTokenEOF      exprTokenType = iota
+	exprTokenWord                   // type names and keywords, ie. uint32, map or User
+	exprTokenList                   // []
+	exprTokenOpen                   // <
+	exprTokenClose                  // >
+	exprTokenComma                  // ,
+	exprTokenQuestion               // ?
+	exprTokenPipe                   // |
+	exprTokenColon                  // :
+)
+
+type exprToken struct {
+	tt  exprTokenType
+	val string
+	pos int // byte offset of the token in the expr
+	end int // byte offset past the token in the expr
+
+	// escaped is set for `backtick quoted` names, which are always treated
+	// as a single struct/alias name
+	escaped bool
+}
+
+// tokenizeVarTypeExpr splits a type expr into tokens, skipping whitespace.
+// In legacy mode whitespace is significant, and becomes part of type names.
+func tokenizeVarTypeExpr(expr string, legacy bool) ([]exprToken, error) {
+	tokens := []exprToken{}
+
+	for i := 0; i < len(expr); {
+		// decode whole runes, so bytes of multi-byte letters in names are
+		// never mistaken for whitespace, ie. the 0xA0 in "à"
+		c, size := utf8.DecodeRuneInString(expr[i:])
+		switch {
+		case unicode.IsSpace(c) && !legacy:
+			i += size
+		case c == '`':
+			end := strings.IndexByte(expr[i+1:], '`')
+			if end < 0 {
+				return nil, newParseError(ErrInvalidSyntax, expr, "schema error: unterminated escaped name in '%s'", expr)
+			}
+			if end == 0 {
+				return nil, newParseError(ErrInvalidSyntax, expr, "schema error: empty escaped name in '%s'", expr)
+			}
+			tokens = append(tokens, exprToken{tt: exprTokenWord, val: expr[i+1 : i+1+end], pos: i, end: i + end + 2, escaped: true})
+			i += end + 2
+		case c == '[':
+			if !strings.HasPrefix(expr[i:], "[]") {
+				return nil, newParseError(ErrInvalidSyntax, expr, "schema error: invalid list syntax for '%s'", expr)
+			}
+			tokens = append(tokens, exprToken{tt: exprTokenList, val: "[]", pos: i, end: i + 2})
+			i += 2
+		case c == '<':
+			tokens = append(tokens, exprToken{tt: exprTokenOpen, val: "<", pos: i, end: i + 1})
+			i++
+		case c == '>':
+			tokens = append(tokens, exprToken{tt: exprTokenClose, val: ">", pos: i, end: i + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, exprToken{tt: exprTokenComma, val: ",", pos: i, end: i + 1})
+			i++
+		case c == '?':
+			tokens = append(tokens, exprToken{tt: exprTokenQuestion, val: "?", pos: i, end: i + 1})
+			i++
+		case c == '|':
+			tokens = append(tokens, exprToken{tt: exprTokenPipe, val: "|", pos: i, end: i + 1})
+			i++
+		case c == ':':
+			tokens = append(tokens, exprToken{tt: exprTokenColon, val: ":", pos: i, end: i + 1})
+			i++
+		default:
+			start := i
+			for i < len(expr) {
+				r, size := utf8.DecodeRuneInString(expr[i:])
+				if isExprDelimiter(r) && !(legacy && unicode.IsSpace(r)) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, exprToken{tt: exprTokenWord, val: expr[start:i], pos: start, end: i})
+		}
+	}
+
+	return append(tokens, exprToken{tt: exprTokenEOF, pos: len(expr), end: len(expr)}), nil
+}
+
+func isExprDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("[]<>,?|:`", r)
+}
+
+// varTypeParser is a recursive descent parser over the tokens of a type expr
+type varTypeParser struct {
+	schema *WebRPCSchema
+	opts   ParseOptions
+	expr   string
+	tokens []exprToken
+	pos    int
+
+	// frames holds the first token of every type being parsed, innermost
+	// last. Frames are only popped on success, so on failure the last one
+	// is the failing type, see minimalExpr.
+	frames []int
+}
+
+// ParseVarTypeWithMessages parses expr, resolving struct references against
+// msgs instead of a full schema, ie. for tools and tests working with a few
+// messages. Without messages, only primitives and containers of them
+// resolve. Aliases and schema parse options don't apply.
+func ParseVarTypeWithMessages(expr string, msgs []*Message) (*VarType, error) {
+	vt := &VarType{Expr: expr}
+	err := vt.Parse(&WebRPCSchema{Messages: msgs})
+	if err != nil {
+		return nil, err
+	}
+	return vt, nil
+}
+
+func ParseVarTypeExpr(schema *WebRPCSchema, expr string, vt *VarType) error {
+	if expr == "" {
+		return nil
+	}
+
+	opts := schema.parseOptions()
+	if opts.StripComments {
+		expr = stripExprComment(expr)
+	}
+
+	// reset any previously parsed state, so types can be re-resolved
+	*vt = VarType{Expr: expr}
+
+	tokens, err := tokenizeVarTypeExpr(expr, opts.LegacyParsing)
+	if err != nil {
+		return err
+	}
+	p := &varTypeParser{schema: schema, opts: opts, expr: expr, tokens: tokens}
+
+	err = p.parseType(vt)
+	if err != nil {
+		if perr, ok := err.(*ParseError); ok && perr.minimal == "" {
+			perr.minimal = p.minimalExpr()
+		}
+		return err
+	}
+	if tok := p.cursor(); tok.tt != exprTokenEOF {
+		return p.errorf(ErrInvalidSyntax, "schema error: unexpected '%s' in type expr '%s'", tok.val, expr)
+	}
+
+	vt.Walk(func(t *VarType) bool {
+		t.opts = &opts
+		return true
+	})
+	return nil
+}
+
+// stripExprComment cuts a trailing // comment from expr, along with the
+// whitespace before it, skipping over `escaped` names
+func stripExprComment(expr string) string {
+	escaped := false
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '`':
+			escaped = !escaped
+		case !escaped && strings.HasPrefix(expr[i:], "//"):
+			return strings.TrimRightFunc(expr[:i], unicode.IsSpace)
+		}
+	}
+	return expr
+}
+
+func (p *varTypeParser) cursor() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *varTypeParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.tt != exprTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *varTypeParser) accept(tt exprTokenType) bool {
+	if p.cursor().tt != tt {
+		return false
+	}
+	p.next()
+	return true
+}
+
+// span returns the source text from the start offset up to the last token
+func (p *varTypeParser) span(start int) string {
+	if p.pos == 0 {
+		return ""
+	}
+	return strings.TrimSpace(p.expr[start:p.tokens[p.pos-1].end])
+}
+
+func (p *varTypeParser) errorf(code ErrorCode, format string, args ...interface{}) error {
+	return newParseError(code, p.expr, format, args...)
+}
+
+// minimalExpr returns the source text of the innermost type which failed to
+// parse, ie. map<float64,int> of map<string,[]map<float64,int>>
+func (p *varTypeParser) minimalExpr() string {
+	if len(p.frames) == 0 {
+		return p.expr
+	}
+	first := p.frames[len(p.frames)-1]
+
+	// the type extends over its list prefixes, name, <...> arguments and
+	// optional suffixes
+	i := first
+	for p.tokens[i].tt == exprTokenList {
+		i++
+	}
+	if p.tokens[i].tt == exprTokenWord {
+		i++
+	}
+	if p.tokens[i].tt == exprTokenOpen {
+		for depth := 0; ; i++ {
+			switch p.tokens[i].tt {
+			case exprTokenEOF:
+				// unbalanced, the failure may be the missing >
+				return strings.TrimSpace(p.expr[p.tokens[first].pos:])
+			case exprTokenOpen:
+				depth++
+			case exprTokenClose:
+				depth--
+			}
+			if depth == 0 {
+				i++
+				break
+			}
+		}
+	}
+	for p.tokens[i].tt == exprTokenQuestion {
+		i++
+	}
+	if i == first {
+		return strings.TrimSpace(p.expr[p.tokens[first].pos:])
+	}
+	return strings.TrimSpace(p.expr[p.tokens[first].pos:p.tokens[i-1].end])
+}
+
+// parseType parses `[]<type>` or `<base>[?]`, where the list prefix binds
+// looser than the optional suffix, ie. []User? is a list of optional users.
+func (p *varTypeParser) parseType(vt *VarType) error {
+	p.frames = append(p.frames, p.pos)
+	if err := p.parseTypeFrame(vt); err != nil {
+		return err
+	}
+	p.frames = p.frames[:len(p.frames)-1]
+	return nil
+}
+
+func (p *varTypeParser) parseTypeFrame(vt *VarType) error {
+	start := p.cursor().pos
+
+	if !p.opts.isGenericList() && p.accept(exprTokenList) {
+		vt.Type = T_List
+		vt.List = &VarListType{Elem: &VarType{}}
+
+		err := p.parseElemType(vt.List.Elem, "list")
+		if err != nil {
+			return err
+		}
+		vt.Expr = p.span(start)
+		return nil
+	}
+
+	err := p.parseBaseType(vt)
+	if err != nil {
+		return err
+	}
+
+	if p.accept(exprTokenQuestion) {
+		if vt.Type == T_Void {
+			return p.errorf(ErrInvalidVoid, "schema error: void cannot be optional for '%s'", p.expr)
+		}
+		err := p.setOptional(vt, start)
+		if err != nil {
+			return err
+		}
+		for p.opts.lenient() && p.accept(exprTokenQuestion) {
+			// redundant, ie. string??
+		}
+	}
+	vt.Expr = p.span(start)
+
+	return nil
+}
+
+// parseElemType parses a type nested in a container, where void is invalid
+func (p *varTypeParser) parseElemType(vt *VarType, container string) error {
+	if p.atStream() {
+		return p.errorf(ErrInvalidStream, "schema error: stream is only valid for a method input or output, not inside %s for '%s'", container, p.expr)
+	}
+	err := p.parseType(vt)
+	if err != nil {
+		return err
+	}
+	if vt.Type == T_Void {
+		return p.errorf(ErrInvalidVoid, "schema error: void is only valid as a method input or output, not inside %s for '%s'", container, p.expr)
+	}
+	return nil
+}
+
+// uniqueListKeyword is the list form without duplicate elements, ie.
+// uniquelist<string>, see VarListType.Unique
+const uniqueListKeyword = "uniquelist"
+
+// genericListKeyword is the list<T> form of []T, parsed unless a custom list
+// keyword is set, see ParseOptions.GenericLists
+const genericListKeyword = "list"
+
+// streamKeyword is reserved for stream<T>, which isn't a type, as streaming
+// is a property of the method, see Method.StreamInput and StreamOutput
+const streamKeyword = "stream"
+
+// atStream reports whether the cursor is at a stream<T> expr
+func (p *varTypeParser) atStream() bool {
+	tok := p.cursor()
+	return tok.tt == exprTokenWord && !tok.escaped && tok.val == streamKeyword && p.tokens[p.pos+1].tt == exprTokenOpen
+}
+
+func (p *varTypeParser) parseBaseType(vt *VarType) error {
+	tok := p.next()
+	if tok.tt != exprTokenWord {
+		if tok.tt == exprTokenEOF {
+			return p.errorf(ErrInvalidSyntax, "schema error: unexpected end of type expr '%s'", p.expr)
+		}
+		return p.errorf(ErrInvalidSyntax, "schema error: unexpected '%s' in type expr '%s'", tok.val, p.expr)
+	}
+
+	if tok.escaped {
+		return p.parseStruct(vt, tok.val)
+	}
+
+	if p.cursor().tt == exprTokenOpen {
+		switch tok.val {
+		case p.opts.mapKeyword():
+			return p.parseMap(vt)
+		case p.opts.listKeyword():
+			return p.parseList(vt)
+		case genericListKeyword:
+			if p.opts.ListKeyword == "" {
+				return p.parseList(vt)
+			}
+		case uniqueListKeyword:
+			err := p.parseList(vt)
+			if err != nil {
+				return err
+			}
+			vt.List.Unique = true
+			return nil
+		case DataTypeToString[T_Result]:
+			return p.parseResult(vt)
+		case DataTypeToString[T_Money]:
+			return p.parseMoney(vt)
+		case DataTypeToString[T_Decimal]:
+			return p.parseDecimal(vt)
+		case DataTypeToString[T_Ratio]:
+			return p.parseRatio(vt)
+		case DataTypeToString[T_Union]:
+			return p.parseUnion(vt)
+		case optionalKeyword:
+			return p.parseOptional(vt)
+		case nullableKeyword:
+			return p.parseNullable(vt)
+		case streamKeyword:
+			return p.errorf(ErrInvalidStream, "schema error: stream in '%s' is not a type, mark the method with streamInput or streamOutput instead", p.expr)
+		case inlineEnumKeyword:
+			return p.errorf(ErrInvalidSyntax, "schema error: inline enum in '%s' is only valid as a message field type, ie. enum<A|B> or enum<A|B>?", p.expr)
+		}
+	}
+
+	dataType, ok := p.opts.dataType(tok.val)
+	if !ok {
+		dataType, ok = lenientDataType(p.schema, tok.val)
+	}
+	if ok {
+		switch dataType {
+		case T_List, T_Map, T_Result, T_Money, T_Union:
+			return p.errorf(ErrInvalidSyntax, "schema error: invalid %s expr for '%s'", tok.val, p.expr)
+		}
+		vt.Type = dataType
+		return nil
+	}
+
+	return p.parseStruct(vt, tok.val)
+}
+
+// parseList parses the generic list<T> form, the list keyword is already
+// consumed, see ParseOptions.ListKeyword
+func (p *varTypeParser) parseList(vt *VarType) error {
+	keyword := p.tokens[p.pos-1].val
+	p.next() // <
+
+	vt.Type = T_List
+	vt.List = &VarListType{Elem: &VarType{}}
+
+	err := p.parseElemType(vt.List.Elem, "list")
+	if err != nil {
+		return err
+	}
+	if !p.accept(exprTokenClose) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid %s syntax for '%s'", keyword, p.expr)
+	}
+
+	return nil
+}
+
+// parseMap parses map<key,value>, the map keyword is already consumed
+func (p *varTypeParser) parseMap(vt *VarType) error {
+	start := p.tokens[p.pos-1].pos
+	p.next() // <
+
+	// collect the key up to the top-level comma, it may be an alias
+	key := ""
+	for depth := 0; ; {
+		tok := p.cursor()
+		if tok.tt == exprTokenEOF || (depth == 0 && tok.tt == exprTokenClose) {
+			return p.errorf(ErrInvalidSyntax, "schema error: invalid map syntax for '%s'", p.expr)
+		}
+		if depth == 0 && tok.tt == exprTokenComma {
+			break
+		}
+		if tok.tt == exprTokenOpen {
+			depth++
+		} else if tok.tt == exprTokenClose {
+			depth--
+		}
+		key += p.expr[tok.pos:tok.end]
+		p.next()
+	}
+	p.next() // ,
+
+	keyDataType, err := parseMapKey(p.schema, key, p.expr)
+	if err != nil {
+		return err
+	}
+
+	// create sub-type object for map
+	vt.Type = T_Map
+	vt.Map = &VarMapType{Key: keyDataType, Value: &VarType{}}
+	if !isStringWireKey(keyDataType) {
+		vt.Map.KeyWireStrategy = p.opts.mapKeyWireStrategy()
+	}
+
+	err = p.parseElemType(vt.Map.Value, "map")
+	if err != nil {
+		return err
+	}
+	p.acceptTrailing(exprTokenComma)
+	if !p.accept(exprTokenClose) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid map syntax for '%s'", p.span(start))
+	}
+
+	return nil
+}
+
+// parseDecimal parses decimal<p,s>, the decimal keyword is already consumed
+func (p *varTypeParser) parseDecimal(vt *VarType) error {
+	p.next() // <
+
+	invalid := func() error {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid decimal syntax for '%s', expecting decimal<PRECISION,SCALE>", p.expr)
+	}
+
+	precision, ok := p.acceptInt()
+	if !ok || !p.accept(exprTokenComma) {
+		return invalid()
+	}
+	scale, ok := p.acceptInt()
+	if !ok || !p.accept(exprTokenClose) {
+		return invalid()
+	}
+
+	vt.Type = T_Decimal
+	vt.Decimal = &VarDecimalType{Precision: precision, Scale: scale}
+	if err := vt.Decimal.validate(); err != nil {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid decimal '%s': %v", p.expr, err)
+	}
+	return nil
+}
+
+// parseRatio parses ratio<min,max>, the ratio keyword is already consumed
+func (p *varTypeParser) parseRatio(vt *VarType) error {
+	p.next() // <
+
+	invalid := func() error {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid ratio syntax for '%s', expecting ratio<MIN,MAX>", p.expr)
+	}
+
+	min, ok := p.acceptFloat()
+	if !ok || !p.accept(exprTokenComma) {
+		return invalid()
+	}
+	max, ok := p.acceptFloat()
+	if !ok || !p.accept(exprTokenClose) {
+		return invalid()
+	}
+
+	vt.Type = T_Ratio
+	vt.Range = &VarRangeType{Min: min, Max: max}
+	if err := vt.Range.validate(); err != nil {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid ratio '%s': %v", p.expr, err)
+	}
+	return nil
+}
+
+// acceptFloat consumes a decimal number word, ie. -1 or 0.5
+func (p *varTypeParser) acceptFloat() (float64, bool) {
+	tok := p.cursor()
+	if tok.tt != exprTokenWord || tok.escaped {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(tok.val, 64)
+	if err != nil {
+		return 0, false
+	}
+	p.next()
+	return f, true
+}
+
+// acceptInt consumes a decimal integer word
+func (p *varTypeParser) acceptInt() (int, bool) {
+	tok := p.cursor()
+	if tok.tt != exprTokenWord || tok.escaped || !isDecimalDigits(tok.val) || tok.val[0] == '-' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(tok.val)
+	if err != nil {
+		return 0, false
+	}
+	p.next()
+	return n, true
+}
+
+// parseMoney parses money<CURRENCY>, the money keyword is already consumed
+func (p *varTypeParser) parseMoney(vt *VarType) error {
+	p.next() // <
+
+	tok := p.next()
+	if tok.tt != exprTokenWord || !p.accept(exprTokenClose) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid money syntax for '%s', expecting money<CURRENCY>", p.expr)
+	}
+	if tok.escaped || !isValidCurrencyCode(tok.val) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid currency '%s' for '%s', expecting a 3-letter ISO 4217 code, ie. money<USD>", tok.val, p.expr)
+	}
+
+	vt.Type = T_Money
+	vt.Money = &VarMoneyType{Currency: tok.val}
+	return nil
+}
+
+func (p *varTypeParser) parseUnion(vt *VarType) error {
+	p.next() // <
+
+	invalid := func() error {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid union syntax for '%s', expecting union<A|B> or union<tag:A|B>", p.expr)
+	}
+
+	vt.Type = T_Union
+	vt.Union = &VarUnionType{}
+
+	// discriminated union, ie. union<type:Cat|Dog>
+	if p.cursor().tt == exprTokenWord && p.tokens[p.pos+1].tt == exprTokenColon {
+		tok := p.next()
+		p.next() // :
+		if tok.escaped || !IsValidArgName(tok.val) {
+			return p.errorf(ErrInvalidSyntax, "schema error: invalid union discriminator '%s' for '%s'", tok.val, p.expr)
+		}
+		vt.Union.Discriminator = tok.val
+	}
+
+	for {
+		if tt := p.cursor().tt; tt == exprTokenPipe || tt == exprTokenClose {
+			return invalid()
+		}
+		variant := &VarType{}
+		err := p.parseElemType(variant, "union")
+		if err != nil {
+			return err
+		}
+		if vt.Union.Discriminator != "" && !variant.isStructMessage() {
+			return p.errorf(ErrInvalidUnionVariant, "schema error: variant '%s' of discriminated union '%s' must be a struct", buildVarTypeExpr(variant, "", p.opts), p.expr)
+		}
+		vt.Union.Variants = append(vt.Union.Variants, variant)
+
+		if p.acceptTrailing(exprTokenPipe) {
+			break
+		}
+		if !p.accept(exprTokenPipe) {
+			break
+		}
+	}
+	if !p.accept(exprTokenClose) || len(vt.Union.Variants) < 2 {
+		return invalid()
+	}
+
+	return nil
+}
+
+// acceptTrailing consumes a trailing separator right before the closing '>',
+// ie. the comma in map<string,int,>, unless separators are strict
+func (p *varTypeParser) acceptTrailing(sep exprTokenType) bool {
+	if p.opts.strictSeparators() || p.cursor().tt != sep || p.tokens[p.pos+1].tt != exprTokenClose {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *varTypeParser) parseResult(vt *VarType) error {
+	p.next() // <
+
+	invalid := func() error {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid result syntax for '%s', expecting result<T,E>", p.expr)
+	}
+
+	// create sub-type objects for result
+	vt.Type = T_Result
+	vt.Result = &VarResultType{Ok: &VarType{}, Err: &VarType{}}
+
+	for i, sub := range []*VarType{vt.Result.Ok, vt.Result.Err} {
+		if tt := p.cursor().tt; tt == exprTokenComma || tt == exprTokenClose {
+			return invalid()
+		}
+		err := p.parseElemType(sub, "result")
+		if err != nil {
+			return err
+		}
+
+		if i == 1 {
+			p.acceptTrailing(exprTokenComma)
+			if !p.accept(exprTokenClose) {
+				return invalid()
+			}
+		} else if !p.accept(exprTokenComma) {
+			return invalid()
+		}
+	}
+
+	return nil
+}
+
+// parseOptional parses optional<T>, the optional keyword is already consumed
+func (p *varTypeParser) parseOptional(vt *VarType) error {
+	start := p.tokens[p.pos-1].pos
+	p.next() // <
+
+	err := p.parseElemType(vt, "optional")
+	if err != nil {
+		return err
+	}
+	if !p.accept(exprTokenClose) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid optional syntax for '%s'", p.expr)
+	}
+	return p.setOptional(vt, start)
+}
+
+// parseNullable parses nullable<T>, the nullable keyword is already consumed
+func (p *varTypeParser) parseNullable(vt *VarType) error {
+	start := p.tokens[p.pos-1].pos
+	p.next() // <
+
+	err := p.parseElemType(vt, "nullable")
+	if err != nil {
+		return err
+	}
+	if !p.accept(exprTokenClose) {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid nullable syntax for '%s'", p.expr)
+	}
+	if vt.Nullable && !p.opts.lenient() {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid nullable syntax for '%s', type is already nullable", p.span(start))
+	}
+	vt.Nullable = true
+	return nil
+}
+
+func (p *varTypeParser) setOptional(vt *VarType, start int) error {
+	if vt.Optional {
+		if p.opts.lenient() {
+			return nil
+		}
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid optional syntax for '%s', type is already optional", p.span(start))
+	}
+	if p.opts.RejectOptionalContainers && (vt.Type == T_List || vt.Type == T_Map) {
+		return p.errorf(ErrRedundantOptional, "schema error: redundant optional %s '%s', containers are already nullable", vt.Type.containerName(), p.span(start))
+	}
+	vt.Optional = true
+	return nil
+}
+
+// parseStruct resolves a message or alias by its name
+func (p *varTypeParser) parseStruct(vt *VarType, structExpr string) error {
+	msg, ok := getMessageType(p.schema, structExpr)
+	if !ok || msg == nil {
+		if alias, ok := getAliasType(p.schema, structExpr); ok {
+			err := parseAliasExpr(p.schema, alias, vt)
+			if err != nil {
+				return err
+			}
+			vt.Alias = string(alias.Name)
+			vt.aliasOptional, vt.aliasNullable = vt.Optional, vt.Nullable
+			return nil
+		}
+		return newParseError(ErrUnknownType, structExpr, "schema error: invalid struct/message type '%s'", structExpr)
+	}
+
+	vt.Type = T_Struct
+	vt.Struct = &VarStructType{Name: structExpr, Message: msg}
+
+	return nil
+}
+
+// inlineEnumKeyword starts an anonymous enum field type, ie. enum<A|B>
+const inlineEnumKeyword = "enum"
+
+// parseInlineEnumExpr returns the value names of an inline enum field type,
+// ie. [active inactive] for enum<active|inactive>, and whether it's optional,
+// ie. enum<active|inactive>?. It returns no values when expr isn't an inline
+// enum.
+func parseInlineEnumExpr(expr string, opts ParseOptions) ([]string, bool, error) {
+	tokens, err := tokenizeVarTypeExpr(expr, opts.LegacyParsing)
+	if err != nil {
+		return nil, false, err
+	}
+	if tokens[0].tt != exprTokenWord || tokens[0].escaped || tokens[0].val != inlineEnumKeyword || tokens[1].tt != exprTokenOpen {
+		return nil, false, nil
+	}
+	p := &varTypeParser{opts: opts, expr: expr, tokens: tokens, pos: 2}
+
+	invalid := func() error {
+		return p.errorf(ErrInvalidSyntax, "schema error: invalid inline enum syntax for '%s', expecting enum<A|B>", expr)
+	}
+
+	values := []string{}
+	for {
+		tok := p.next()
+		if tok.tt != exprTokenWord || tok.escaped {
+			return nil, false, invalid()
+		}
+		values = append(values, tok.val)
+
+		if p.acceptTrailing(exprTokenPipe) || !p.accept(exprTokenPipe) {
+			break
+		}
+	}
+	if !p.accept(exprTokenClose) {
+		return nil, false, invalid()
+	}
+	optional := p.accept(exprTokenQuestion)
+	if p.cursor().tt != exprTokenEOF {
+		return nil, false, invalid()
+	}
+
+	return values, optional, nil
+}
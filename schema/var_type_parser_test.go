@@ -0,0 +1,590 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenizeVarTypeExpr(t *testing.T) {
+	tokens, err := tokenizeVarTypeExpr("map< string, []User? >", false)
+	assert.NoError(t, err)
+
+	types := []exprTokenType{}
+	values := []string{}
+	for _, tok := range tokens {
+		types = append(types, tok.tt)
+		values = append(values, tok.val)
+	}
+	assert.Equal(t, []exprTokenType{
+		exprTokenWord, exprTokenOpen, exprTokenWord, exprTokenComma, exprTokenList, exprTokenWord, exprTokenQuestion, exprTokenClose, exprTokenEOF,
+	}, types)
+	assert.Equal(t, []string{"map", "<", "string", ",", "[]", "User", "?", ">", ""}, values)
+
+	_, err = tokenizeVarTypeExpr("[User", false)
+	assert.Error(t, err)
+}
+
+func TestParseVarTypeExprCorpus(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	tt := []struct {
+		Expr      string
+		Canonical string
+	}{
+		{"string", "string"},
+		{"timestamp", "timestamp"},
+		{"User", "User"},
+		{"[]string", "[]string"},
+		{"[][]string", "[][]string"},
+		{"[]User", "[]User"},
+		{"map<string,any>", "map<string,any>"},
+		{"map<string,map<string,uint32>>", "map<string,map<string,uint32>>"},
+		{"map<int64,[]string>", "map<int64,[]string>"},
+		{"[]map<string,uint32>", "[]map<string,uint32>"},
+		{"map<string,[]map<uint8,User>>", "map<string,[]map<uint8,User>>"},
+		{"result<User,Error>", "result<User,Error>"},
+		{"result<map<string,int>,[]Error>", "result<map<string,int>,[]Error>"},
+		{"[]User?", "[]User?"},
+		{"optional<[]User>", "optional<[]User>"},
+		{"optional<map<string,User>>", "map<string,User>?"},
+
+		// whitespace is tolerated between tokens
+		{" map< string , []User > ", "map<string,[]User>"},
+		{"map<string, map<string, uint32>>", "map<string,map<string,uint32>>"},
+		{"result< User , Error >", "result<User,Error>"},
+		{"[] string", "[]string"},
+		{"User ?", "User?"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.Canonical, vt.Expr, tc.Expr)
+
+			// re-parsing the canonical form yields the same type
+			again := &VarType{Expr: vt.Expr}
+			assert.NoError(t, again.Parse(s))
+			assert.True(t, vt.Equal(again), tc.Expr)
+		}
+	}
+}
+
+func TestParseVarTypeExprErrors(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr  string
+		Code  ErrorCode
+		Error string
+	}{
+		{"Unknown", ErrUnknownType, "schema error: invalid struct/message type 'Unknown'"},
+		{"[]Unknown", ErrUnknownType, "schema error: invalid struct/message type 'Unknown'"},
+		{"map<bool,string>", ErrInvalidMapKey, "schema error: invalid map key 'bool' for 'map<bool,string>'"},
+		{"map<uint64?,User>", ErrOptionalMapKey, "schema error: map key 'uint64?' cannot be optional for 'map<uint64?,User>', as JSON object keys are always present, use 'uint64' instead"},
+		{"map<optional<string>,User>", ErrOptionalMapKey, "schema error: map key 'optional<string>' cannot be optional for 'map<optional<string>,User>', as JSON object keys are always present, use 'string' instead"},
+		{"map<string>", ErrInvalidSyntax, "schema error: invalid map syntax for 'map<string>'"},
+		{"map<string,int", ErrInvalidSyntax, "schema error: invalid map syntax for 'map<string,int'"},
+		{"map", ErrInvalidSyntax, "schema error: invalid map expr for 'map'"},
+		{"map<string,int>>", ErrInvalidSyntax, "schema error: unexpected '>' in type expr 'map<string,int>>'"},
+		{"result<User>", ErrInvalidSyntax, "schema error: invalid result syntax for 'result<User>', expecting result<T,E>"},
+		{"[]", ErrInvalidSyntax, "schema error: unexpected end of type expr '[]'"},
+		{"User User", ErrInvalidSyntax, "schema error: unexpected 'User' in type expr 'User User'"},
+		{"[]void", ErrInvalidVoid, "schema error: void is only valid as a method input or output, not inside list for '[]void'"},
+		{"map<string,void>", ErrInvalidVoid, "schema error: void is only valid as a method input or output, not inside map for 'map<string,void>'"},
+		{"void?", ErrInvalidVoid, "schema error: void cannot be optional for 'void?'"},
+		{"[]stream<User>", ErrInvalidStream, "schema error: stream is only valid for a method input or output, not inside list for '[]stream<User>'"},
+		{"map<string,stream<User>>", ErrInvalidStream, "schema error: stream is only valid for a method input or output, not inside map for 'map<string,stream<User>>'"},
+		{"map<string,[]stream<User>>", ErrInvalidStream, "schema error: stream is only valid for a method input or output, not inside list for 'map<string,[]stream<User>>'"},
+		{"stream<User>", ErrInvalidStream, "schema error: stream in 'stream<User>' is not a type, mark the method with streamInput or streamOutput instead"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		err := vt.Parse(s)
+		if assert.Error(t, err, tc.Expr) {
+			assert.Equal(t, tc.Error, err.Error())
+			assert.Equal(t, tc.Code, err.(*ParseError).Code, tc.Expr)
+		}
+	}
+}
+
+func TestParseVarTypeExprCustomKeywords(t *testing.T) {
+	s := newTestSchema("User")
+	s.ParseOptions = ParseOptions{ListKeyword: "array", MapKeyword: "dict"}
+
+	tt := []struct {
+		Expr      string
+		Canonical string
+	}{
+		{"array<string>", "array<string>"},
+		{"array<array<User>>", "array<array<User>>"},
+		{"dict<string,array<User?>>", "dict<string,array<User?>>"},
+		{"array<dict<uint64,User>>?", "array<dict<uint64,User>>?"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.Canonical, vt.Expr)
+
+			// same tree as the default spelling
+			def := &VarType{Expr: buildVarTypeExpr(vt, "", ParseOptions{})}
+			assert.NoError(t, def.Parse(newTestSchema("User")))
+			assert.True(t, vt.Equal(def), tc.Expr)
+		}
+	}
+
+	for _, expr := range []string{"[]string", "map<string,User>", "array<string"} {
+		vt := &VarType{Expr: expr}
+		assert.Error(t, vt.Parse(s), expr)
+	}
+}
+
+func TestParseVarTypeExprGenericLists(t *testing.T) {
+	tt := []struct {
+		Generic string
+		Default string
+	}{
+		{"list<string>", "[]string"},
+		{"list<list<User?>>", "[][]User?"},
+		{"map<string,list<User>>", "map<string,[]User>"},
+		{"list<map<uint64,User>>?", "optional<[]map<uint64,User>>"},
+		{"list< int64 >", "[]int64"},
+	}
+
+	for _, tc := range tt {
+		generic, plain := &VarType{Expr: tc.Generic}, &VarType{Expr: tc.Default}
+		if assert.NoError(t, generic.Parse(newTestSchema("User")), tc.Generic) && assert.NoError(t, plain.Parse(newTestSchema("User")), tc.Default) {
+			assert.True(t, generic.Equal(plain), tc.Generic)
+			assert.Equal(t, tc.Default, generic.Expr)
+		}
+	}
+
+	s := newTestSchema("User")
+	s.ParseOptions = ParseOptions{GenericLists: true}
+	for _, expr := range []string{"[]map<string,[]User>", "list<map<string,list<User>>>"} {
+		vt := &VarType{Expr: expr}
+		if assert.NoError(t, vt.Parse(s), expr) {
+			assert.Equal(t, "list<map<string,list<User>>>", vt.Expr)
+		}
+	}
+	vt := &VarType{Expr: "[]string?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "list<string?>", vt.Expr)
+	vt = &VarType{Expr: "optional<[]string>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "list<string>?", vt.Expr)
+
+	err := (&VarType{Expr: "list<string"}).Parse(newTestSchema())
+	assert.EqualError(t, err, "schema error: invalid list syntax for 'list<string'")
+
+	s.ParseOptions = ParseOptions{GenericLists: true, ListKeyword: "array"}
+	assert.Error(t, s.ParseOptions.validate())
+}
+
+func TestParseVarTypeExprEscapedNames(t *testing.T) {
+	s := newTestSchema("weird,name", "map<User>", "string")
+	s.Aliases = []*Alias{newTestAlias("odd key", "uint64")}
+
+	vt := &VarType{Expr: "map<string,`weird,name`>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Struct, vt.Map.Value.Type)
+	assert.Equal(t, "weird,name", vt.Map.Value.Struct.Name)
+	assert.Equal(t, "map<string,`weird,name`>", vt.Expr)
+
+	vt = &VarType{Expr: "[]`map<User>`?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<User>", vt.List.Elem.Struct.Name)
+	assert.True(t, vt.List.Elem.Optional)
+	assert.Equal(t, "[]`map<User>`?", vt.Expr)
+
+	// escaped names always refer to messages or aliases
+	vt = &VarType{Expr: "`string`"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Struct, vt.Type)
+	assert.Equal(t, "`string`", vt.Expr)
+
+	vt = &VarType{Expr: "map<`odd key`,bool>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Uint64, vt.Map.Key)
+
+	for _, expr := range []string{"`weird,name", "``", "map<string,weird,name>"} {
+		vt := &VarType{Expr: expr}
+		assert.Error(t, vt.Parse(s), expr)
+	}
+}
+
+func TestParseVarTypeExprByte(t *testing.T) {
+	s := newTestSchema()
+
+	tt := []struct {
+		Expr      string
+		Canonical string
+	}{
+		{"byte", "uint8"},
+		{"[]byte", "[]uint8"},
+		{"map<byte,[]byte>", "map<uint8,[]uint8>"},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s), tc.Expr)
+		assert.Equal(t, tc.Canonical, vt.Expr)
+	}
+
+	vt := &VarType{Expr: "[]byte"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Uint8, vt.List.Elem.Type)
+
+	s.ParseOptions.PreserveByte = true
+	vt = &VarType{Expr: "[]byte"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Byte, vt.List.Elem.Type)
+	assert.Equal(t, "[]byte", vt.Expr)
+}
+
+func TestParseVarTypeExprLegacyParsing(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<string, User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<string,User>", vt.Expr)
+
+	s.ParseOptions.LegacyParsing = true
+
+	vt = &VarType{Expr: "map<string, User>"}
+	err := vt.Parse(s)
+	if assert.Error(t, err) {
+		assert.Equal(t, "schema error: invalid struct/message type ' User'", err.Error())
+	}
+
+	vt = &VarType{Expr: "map< string,User>"}
+	err = vt.Parse(s)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrInvalidMapKey, err.(*ParseError).Code)
+	}
+
+	// whitespace-free exprs parse the same in both modes
+	vt = &VarType{Expr: "map<string,[]User?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<string,[]User?>", vt.Expr)
+}
+
+func TestParseVarTypeExprUnicodeNames(t *testing.T) {
+	s := newTestSchema("Café", "Straße", "Ünïcødé")
+
+	vt := &VarType{Expr: "map<string,Café>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Struct, vt.Map.Value.Type)
+	assert.Equal(t, "Café", vt.Map.Value.Struct.Name)
+	assert.Equal(t, "map<string,Café>", vt.Expr)
+
+	vt = &VarType{Expr: "[]Straße?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "Straße", vt.List.Elem.Struct.Name)
+	assert.True(t, vt.List.Elem.Optional)
+
+	// 'à' is encoded as 0xC3 0xA0, and 0xA0 alone is a no-break space
+	vt = &VarType{Expr: "map<string, []Ünïcødé>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<string,[]Ünïcødé>", vt.Expr)
+
+	assert.Error(t, (&VarType{Expr: "Voilà"}).Parse(s))
+}
+
+func TestParseVarTypeExprUnion(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	vt := &VarType{Expr: "union<User | Error | []string>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Union, vt.Type)
+	if assert.Equal(t, 3, len(vt.Union.Variants)) {
+		assert.Equal(t, "User", vt.Union.Variants[0].Struct.Name)
+		assert.Equal(t, "Error", vt.Union.Variants[1].Struct.Name)
+		assert.Equal(t, T_List, vt.Union.Variants[2].Type)
+	}
+	assert.Equal(t, "union<User|Error|[]string>", vt.Expr)
+	assert.Equal(t, "{User} | {Error} | [ \"<string>\" ]", vt.WireShape())
+	assert.Equal(t, "interface{}", vt.GoType())
+	assert.True(t, vt.NeedsCustomJSON())
+	assert.NoError(t, vt.Validate())
+
+	count := 0
+	vt.Walk(func(*VarType) bool { count++; return true })
+	assert.Equal(t, 5, count)
+
+	vt = &VarType{Expr: "map<string,union<User|Error>?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<string,union<User|Error>?>", vt.Expr)
+	assert.True(t, vt.Map.Value.Optional)
+
+	for _, expr := range []string{"union<User>", "union<>", "union<|User>", "union<User||Error>", "union<User|Error", "union"} {
+		assert.Error(t, (&VarType{Expr: expr}).Parse(s), expr)
+	}
+}
+
+func TestVarTypeUnionVariants(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	vt := &VarType{Expr: "union<User|[]string|union<Error|map<string,User>>|union<bool|uint32>?>"}
+	assert.NoError(t, vt.Parse(s))
+
+	variants := vt.UnionVariants()
+	exprs := []string{}
+	for _, variant := range variants {
+		exprs = append(exprs, variant.Expr)
+	}
+	assert.Equal(t, []string{"User", "[]string", "Error", "map<string,User>", "union<bool|uint32>?"}, exprs)
+	assert.Equal(t, T_Struct, variants[0].Type)
+	assert.Equal(t, T_List, variants[1].Type)
+
+	assert.Nil(t, (&VarType{Expr: "User"}).UnionVariants())
+	user := &VarType{Expr: "[]User"}
+	assert.NoError(t, user.Parse(s))
+	assert.Nil(t, user.UnionVariants())
+}
+
+func TestParseVarTypeExprDiscriminatedUnion(t *testing.T) {
+	s := newTestSchema("Cat", "Dog")
+
+	vt := &VarType{Expr: "[]union<kind: Cat | Dog>"}
+	assert.NoError(t, vt.Parse(s))
+	union := vt.List.Elem
+	assert.Equal(t, T_Union, union.Type)
+	assert.Equal(t, "kind", union.Union.Discriminator)
+	assert.Equal(t, 2, len(union.Union.Variants))
+	assert.Equal(t, "[]union<kind:Cat|Dog>", vt.Expr)
+	assert.Equal(t, `[ {"kind": "Cat", ...Cat} | {"kind": "Dog", ...Dog} ]`, vt.WireShape())
+	assert.NoError(t, vt.Validate())
+	assert.False(t, vt.Equal(&VarType{Expr: "[]union<Cat|Dog>", Type: T_List, List: &VarListType{Elem: &VarType{Type: T_Union, Union: &VarUnionType{Variants: union.Union.Variants}}}}))
+
+	// every variant must be a struct
+	for _, expr := range []string{"union<type:Cat|string>", "union<type:Cat|Dog?>", "union<type:Cat|[]Dog>"} {
+		err := (&VarType{Expr: expr}).Parse(s)
+		if assert.Error(t, err, expr) {
+			assert.Equal(t, ErrInvalidUnionVariant, err.(*ParseError).Code, expr)
+		}
+	}
+	err := (&VarType{Expr: "union<type:Cat|string>"}).Parse(s)
+	assert.EqualError(t, err, "schema error: variant 'string' of discriminated union 'union<type:Cat|string>' must be a struct")
+
+	for _, expr := range []string{"union<:Cat|Dog>", "union<type:>", "union<type:Cat>", "union<`type`:Cat|Dog>", "union<type:kind:Cat|Dog>"} {
+		assert.Error(t, (&VarType{Expr: expr}).Parse(s), expr)
+	}
+}
+
+func TestParseVarTypeExprTrailingSeparators(t *testing.T) {
+	s := newTestSchema("User", "Error")
+	s.ParseOptions.Strictness = LenientParsing
+
+	tt := []struct {
+		Expr string
+		Want string
+	}{
+		{"map<string,int,>", "map<string,int>"},
+		{"map<string, map<int64,User,> ,>", "map<string,map<int64,User>>"},
+		{"result<User,Error,>", "result<User,Error>"},
+		{"union<User|Error|>", "union<User|Error>"},
+		{"[]union<User|Error|>?", "[]union<User|Error>?"},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.Want, vt.Expr)
+		}
+	}
+
+	// a trailing separator is only ignored before the closing '>'
+	for _, expr := range []string{"map<string,int,,>", "union<User|Error||>", "map<string,>", "union<User|>"} {
+		assert.Error(t, (&VarType{Expr: expr}).Parse(s), expr)
+	}
+
+	// rejected at the strict level, and by lenient parsing with
+	// StrictSeparators
+	for _, opts := range []ParseOptions{{}, {Strictness: StrictParsing}, {Strictness: LenientParsing, StrictSeparators: true}} {
+		s.ParseOptions = opts
+		for _, tc := range tt {
+			err := (&VarType{Expr: tc.Expr}).Parse(s)
+			if assert.Error(t, err, tc.Expr) {
+				assert.Equal(t, ErrInvalidSyntax, err.(*ParseError).Code)
+			}
+		}
+	}
+}
+
+func TestParseVarTypeExprStrictness(t *testing.T) {
+	exprs := []struct {
+		Expr string
+		Want string
+	}{
+		{"String", "string"},
+		{"map<UINT64,[]Int32>", "map<uint64,[]int32>"},
+		{"string??", "string?"},
+		{"optional<string?>", "string?"},
+		{"map<string,int,>", "map<string,int>"},
+	}
+
+	strict := newTestSchema("User")
+	strict.ParseOptions.Strictness = StrictParsing
+	lenient := newTestSchema("User")
+	lenient.ParseOptions.Strictness = LenientParsing
+
+	for _, tc := range exprs {
+		assert.Error(t, (&VarType{Expr: tc.Expr}).Parse(strict), tc.Expr)
+
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(lenient), tc.Expr) {
+			assert.Equal(t, tc.Want, buildVarTypeExpr(vt, "", lenient.ParseOptions), tc.Expr)
+		}
+	}
+
+	// the zero value is fully strict
+	s := newTestSchema("User")
+	for _, tc := range exprs {
+		assert.Error(t, (&VarType{Expr: tc.Expr}).Parse(s), tc.Expr)
+	}
+
+	// messages and aliases of the exact name aren't shadowed by primitives
+	lenient = newTestSchema("Date")
+	lenient.ParseOptions.Strictness = LenientParsing
+	vt := &VarType{Expr: "Date"}
+	assert.NoError(t, vt.Parse(lenient))
+	assert.Equal(t, T_Struct, vt.Type)
+
+	// StrictSeparators only takes trailing separators out of lenient parsing
+	lenient.ParseOptions.StrictSeparators = true
+	assert.NoError(t, lenient.ParseOptions.validate())
+	assert.NoError(t, (&VarType{Expr: "map<String,int>"}).Parse(lenient))
+	assert.Error(t, (&VarType{Expr: "map<string,int,>"}).Parse(lenient))
+
+	lenient.ParseOptions.LegacyParsing = true
+	assert.EqualError(t, lenient.ParseOptions.validate(), "schema error: lenient parsing conflicts with the RejectOptionalContainers and LegacyParsing options")
+	assert.EqualError(t, ParseOptions{Strictness: "loose"}.validate(), "schema error: invalid parse strictness 'loose', must be one of strict, lenient")
+}
+
+func TestParseVarTypeExprNullable(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr      string
+		Canonical string
+		Optional  bool
+		Nullable  bool
+	}{
+		{"User", "User", false, false},
+		{"User?", "User?", true, false},
+		{"nullable<User>", "nullable<User>", false, true},
+		{"nullable<User>?", "nullable<User>?", true, true},
+		{"nullable<User?>", "nullable<User>?", true, true},
+		{"optional<nullable<[]string>>", "optional<nullable<[]string>>", true, true},
+		{"[]nullable<uint32>", "[]nullable<uint32>", false, false},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if !assert.NoError(t, vt.Parse(s), tc.Expr) {
+			continue
+		}
+		assert.Equal(t, tc.Canonical, vt.Expr, tc.Expr)
+		assert.Equal(t, tc.Optional, vt.IsOptional(), tc.Expr)
+		assert.Equal(t, tc.Nullable, vt.IsNullable(), tc.Expr)
+
+		// both flags round-trip independently
+		again := &VarType{Expr: vt.Expr}
+		assert.NoError(t, again.Parse(s), tc.Expr)
+		assert.True(t, again.Equal(vt), tc.Expr)
+	}
+
+	optional, nullable := &VarType{Expr: "User?"}, &VarType{Expr: "nullable<User>"}
+	assert.NoError(t, optional.Parse(s))
+	assert.NoError(t, nullable.Parse(s))
+	assert.False(t, optional.Equal(nullable))
+	assert.Equal(t, "*User", nullable.GoType())
+	assert.Equal(t, "{User} | null", nullable.WireShape())
+	assert.NoError(t, nullable.ValidateValue(nil))
+
+	for _, expr := range []string{"nullable<nullable<User>>", "nullable<>", "nullable<User", "map<nullable<string>,User>", "nullable<void>"} {
+		assert.Error(t, (&VarType{Expr: expr}).Parse(s), expr)
+	}
+}
+
+func TestParseVarTypeWithMessages(t *testing.T) {
+	vt, err := ParseVarTypeWithMessages("map<string, []uint64?>", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "map<string,[]uint64?>", vt.Expr)
+		assert.Equal(t, T_Uint64, vt.Map.Value.List.Elem.Type)
+	}
+
+	_, err = ParseVarTypeWithMessages("[]User", nil)
+	assert.EqualError(t, err, "schema error: invalid struct/message type 'User'")
+
+	user := &Message{Name: "User", Type: "struct"}
+	vt, err = ParseVarTypeWithMessages("map<uint64,[]User?>", []*Message{user})
+	if assert.NoError(t, err) {
+		assert.Equal(t, user, vt.Map.Value.List.Elem.Struct.Message)
+		assert.True(t, vt.IsFullyResolved())
+	}
+
+	_, err = ParseVarTypeWithMessages("", nil)
+	assert.EqualError(t, err, "schema error: type expr cannot be empty")
+}
+
+func TestParseVarTypeExprStripComments(t *testing.T) {
+	s := newTestSchema("User", "a//b")
+	s.ParseOptions = ParseOptions{StripComments: true}
+
+	tt := []struct {
+		Expr      string
+		Canonical string
+	}{
+		{"[]User // the users", "[]User"},
+		{"map<string,User?>// by name", "map<string,User?>"},
+		{"[]`a//b` // escaped", "[]`a//b`"},
+		{"uint64", "uint64"},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		if assert.NoError(t, vt.Parse(s), tc.Expr) {
+			assert.Equal(t, tc.Canonical, vt.Expr, tc.Expr)
+		}
+	}
+
+	vt := &VarType{Expr: "[]`a//b` // escaped"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "a//b", vt.List.Elem.Struct.Name)
+
+	// comments are only stripped with the option
+	err := (&VarType{Expr: "[]User // the users"}).Parse(newTestSchema("User"))
+	assert.Error(t, err)
+}
+
+func TestParseErrorMinimalExpr(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr    string
+		Minimal string
+	}{
+		// nested two levels deep, in a list in a map
+		{"map<string,[]map<float64,int>>", "map<float64,int>"},
+		{"map<string, []map<float64, int>?>", "map<float64, int>?"},
+		{"result<User,map<string,[]Missing>>", "Missing"},
+		{"[]map<string,[]void>", "[]void"},
+		{"union<User|[]decimal<2,4>>", "decimal<2,4>"},
+		{"[]map<string,uint32", "map<string,uint32"},
+		{"map<float64,int>", "map<float64,int>"},
+	}
+	for _, tc := range tt {
+		err := (&VarType{Expr: tc.Expr}).Parse(s)
+		perr, ok := err.(*ParseError)
+		if !assert.True(t, ok, tc.Expr) {
+			continue
+		}
+		assert.Equal(t, tc.Minimal, perr.MinimalExpr(), tc.Expr)
+
+		// the minimal expr reproduces the failure on its own
+		assert.Error(t, (&VarType{Expr: perr.MinimalExpr()}).Parse(s), tc.Minimal)
+	}
+
+	assert.Equal(t, "map<", newParseError(ErrInvalidSyntax, "map<", "schema error").MinimalExpr())
+}
@@ -0,0 +1,984 @@
+package schema
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSchema(messageNames ...string) *WebRPCSchema {
+	s := &WebRPCSchema{WebrpcVersion: VERSION}
+	for _, name := range messageNames {
+		s.Messages = append(s.Messages, &Message{Name: VarName(name), Type: "struct"})
+	}
+	return s
+}
+
+func TestVarTypeEnumReferences(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Color", "type": "enum", "fields": [{ "name": "RED", "type": "uint8" }, { "name": "BLUE", "type": "uint8" }] },
+			{ "name": "Status", "type": "enum", "fields": [{ "name": "ACTIVE", "type": "uint32" }] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "id", "type": "uint64" }] }
+		]
+	}`
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	color, status := s.GetMessageByName("Color"), s.GetMessageByName("Status")
+
+	vt := &VarType{Expr: "[]Color"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, color, vt.List.Elem.Enum())
+	assert.Nil(t, vt.Enum())
+	assert.Equal(t, "[]Color", vt.String())
+
+	vt = &VarType{Expr: "map<string, Status?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, status, vt.Map.Value.Enum())
+	assert.Equal(t, "map<string,Status?>", vt.String())
+
+	vt = &VarType{Expr: "map<uint64,[]Color>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, color, vt.Map.Value.List.Elem.Enum())
+	assert.Equal(t, "map<uint64,[]Color>", vt.String())
+
+	vt = &VarType{Expr: "[]User"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Nil(t, vt.List.Elem.Enum())
+	assert.Nil(t, (&VarType{Expr: "[]Color"}).Enum())
+
+	// enums are valid map values, but not map keys
+	err = (&VarType{Expr: "map<Color,Status>"}).Parse(s)
+	assert.EqualError(t, err, "schema error: enum 'Color' is not a valid map key for 'map<Color,Status>', as enums aren't valid map keys")
+	assert.Equal(t, ErrInvalidMapKey, err.(*ParseError).Code)
+}
+
+func TestVarTypeResult(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	vt := &VarType{Expr: "result<User,Error>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Result, vt.Type)
+	assert.Equal(t, T_Struct, vt.Result.Ok.Type)
+	assert.Equal(t, "User", vt.Result.Ok.Struct.Name)
+	assert.Equal(t, T_Struct, vt.Result.Err.Type)
+	assert.Equal(t, "Error", vt.Result.Err.Struct.Name)
+	assert.Equal(t, "result<User,Error>", vt.Expr)
+
+	vt = &VarType{Expr: "result<map<string,[]User>,result<string,Error>>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Map, vt.Result.Ok.Type)
+	assert.Equal(t, T_Result, vt.Result.Err.Type)
+	assert.Equal(t, "result<map<string,[]User>,result<string,Error>>", vt.Expr)
+
+	for _, expr := range []string{"result<User>", "result<,Error>", "result<User,>", "result<User,Error,string>", "result"} {
+		vt := &VarType{Expr: expr}
+		assert.Error(t, vt.Parse(s), expr)
+	}
+}
+
+func TestVarTypeOptional(t *testing.T) {
+	s := newTestSchema("User")
+
+	parse := func(expr string) *VarType {
+		vt := &VarType{Expr: expr}
+		assert.NoError(t, vt.Parse(s), expr)
+		return vt
+	}
+
+	suffix, keyword := parse("User?"), parse("optional<User>")
+	assert.True(t, suffix.Optional)
+	assert.Equal(t, T_Struct, suffix.Type)
+	assert.True(t, suffix.Equal(keyword))
+	assert.Equal(t, "User?", suffix.Expr)
+	assert.Equal(t, "User?", keyword.Expr)
+	assert.False(t, suffix.Equal(parse("User")))
+
+	assert.True(t, parse("map<string,uint32?>").Equal(parse("map<string,optional<uint32>>")))
+	assert.True(t, parse("map<string,User>?").Equal(parse("optional<map<string,User>>")))
+
+	// the list prefix binds looser than the ? suffix
+	list := parse("[]User?")
+	assert.False(t, list.Optional)
+	assert.True(t, list.List.Elem.Optional)
+	assert.Equal(t, "[]User?", list.Expr)
+
+	list = parse("optional<[]User>")
+	assert.True(t, list.Optional)
+	assert.False(t, list.List.Elem.Optional)
+	assert.Equal(t, "optional<[]User>", list.Expr)
+
+	// canonical keyword form
+	s.ParseOptions.OptionalKeyword = true
+	assert.Equal(t, "optional<User>", parse("User?").Expr)
+	assert.Equal(t, "map<string,optional<uint32>>", parse("map<string,uint32?>").Expr)
+	s.ParseOptions.OptionalKeyword = false
+
+	for _, expr := range []string{"optional<optional<User>>", "optional<User?>", "optional<User>?", "User??"} {
+		vt := &VarType{Expr: expr}
+		assert.Error(t, vt.Parse(s), expr)
+	}
+}
+
+func TestVarTypeBaseEqual(t *testing.T) {
+	s := newTestSchema("User", "Error")
+
+	tt := []struct {
+		A, B      string
+		BaseEqual bool
+	}{
+		{"User", "User?", true},
+		{"User", "nullable<User>?", true},
+		{"map<string,[]User>", "optional<map<string,[]User>>", true},
+		{"[]User", "[]User?", false},
+		{"User", "Error?", false},
+		{"uint64", "uint32?", false},
+	}
+
+	for _, tc := range tt {
+		a, b := &VarType{Expr: tc.A}, &VarType{Expr: tc.B}
+		if assert.NoError(t, a.Parse(s), tc.A) && assert.NoError(t, b.Parse(s), tc.B) {
+			assert.Equal(t, tc.BaseEqual, a.BaseEqual(b), tc.A+" "+tc.B)
+			assert.Equal(t, tc.BaseEqual, b.BaseEqual(a), tc.B+" "+tc.A)
+			assert.False(t, a.Equal(b), tc.A+" "+tc.B)
+		}
+	}
+}
+
+func TestVarTypeWireShape(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr  string
+		Shape string
+	}{
+		{"map<string,[]User>", `{"<string>": [ {User} ]}`},
+		{"[]map<uint64,string>", `[ {"<uint64>": "<string>"} ]`},
+		{"map<string,map<string,[]timestamp?>>", `{"<string>": {"<string>": [ "<timestamp>" | null ]}}`},
+		{"User?", `{User} | null`},
+		{"bool", `<bool>`},
+	}
+
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s))
+		assert.Equal(t, tc.Shape, vt.WireShape(), tc.Expr)
+	}
+}
+
+func TestVarTypeBytesWireForm(t *testing.T) {
+	s := newTestSchema()
+	s.ParseOptions = ParseOptions{PreserveByte: true}
+
+	// every generator agrees on base64 strings for lists of bytes
+	for _, expr := range []string{"[]byte", "[]uint8", "uniquelist<uint8>"} {
+		vt := &VarType{Expr: expr}
+		if !assert.NoError(t, vt.Parse(s), expr) {
+			continue
+		}
+		assert.True(t, vt.IsBytes(), expr)
+		assert.Equal(t, `"<base64>"`, vt.WireShape(), expr)
+		assert.Equal(t, "string", vt.TSType(), expr)
+		assert.Contains(t, []string{"[]byte", "[]uint8"}, vt.GoType(), expr)
+
+		openAPI, err := vt.openAPISchema()
+		assert.NoError(t, err)
+		assert.Equal(t, openAPIType("string", "byte"), openAPI, expr)
+
+		avroType, err := (&avroConverter{defined: map[string]bool{}}).varType(vt)
+		assert.NoError(t, err)
+		assert.Equal(t, "bytes", avroType, expr)
+
+		sqlType, err := vt.SQLType("postgres")
+		assert.NoError(t, err)
+		assert.Equal(t, "BYTEA", sqlType, expr)
+
+		assert.NoError(t, vt.ValidateValue("aGVsbG8="), expr)
+		assert.Error(t, vt.ValidateValue([]interface{}{104.0, 105.0}), expr)
+		coerced, err := vt.Coerce([]interface{}{104.0, "105"})
+		assert.NoError(t, err)
+		assert.Equal(t, "aGk=", coerced, expr)
+	}
+
+	// lists of optional bytes are plain lists of numbers
+	vt := &VarType{Expr: "[]uint8?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.False(t, vt.IsBytes())
+	assert.Equal(t, "Array<number | null>", vt.TSType())
+	assert.NoError(t, vt.ValidateValue([]interface{}{1.0, nil}))
+}
+
+func TestVarTypeUnwrap(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "uint32?"}
+	assert.NoError(t, vt.Parse(s))
+	base := vt.Unwrap()
+	assert.False(t, base.Optional)
+	assert.Equal(t, T_Uint32, base.Type)
+	assert.Equal(t, "uint32", base.Expr)
+	assert.True(t, vt.Optional, "receiver must not be mutated")
+	assert.Equal(t, "uint32?", vt.Expr)
+
+	vt = &VarType{Expr: "optional<User>"}
+	assert.NoError(t, vt.Parse(s))
+	base = vt.Unwrap()
+	assert.False(t, base.Optional)
+	assert.Equal(t, "User", base.Struct.Name)
+	assert.Equal(t, "User", base.Expr)
+	assert.True(t, vt.Optional)
+
+	// element optionality is kept
+	vt = &VarType{Expr: "optional<[]User?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "[]User?", vt.Unwrap().Expr)
+
+	vt = &VarType{Expr: "string"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, vt, vt.Unwrap())
+
+	// rebuilt exprs keep the schema's syntax
+	s.ParseOptions = ParseOptions{ListKeyword: "array", MapKeyword: "dict", OptionalKeyword: true}
+	vt = &VarType{Expr: "optional<dict<string,array<User?>>>"}
+	assert.NoError(t, vt.Parse(s))
+	base = vt.Unwrap()
+	assert.Equal(t, "dict<string,array<optional<User>>>", base.Expr)
+	assert.Equal(t, "array<optional<User>>", BuildExpr(base.Map.Value))
+
+	reparsed := &VarType{Expr: base.Expr}
+	assert.NoError(t, reparsed.Parse(s))
+	assert.Equal(t, base.Expr, reparsed.Expr)
+}
+
+func TestValidateMapKey(t *testing.T) {
+	assert.NoError(t, ValidateMapKey(T_String))
+	assert.NoError(t, ValidateMapKey(T_Uint64))
+
+	err := ValidateMapKey(T_Bool)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid map key type 'bool'")
+		assert.Contains(t, err.Error(), "must be one of string, uint, uint8")
+	}
+	assert.Error(t, ValidateMapKey(T_Float64))
+	assert.Error(t, ValidateMapKey(T_Struct))
+}
+
+func TestVarStructTypeDescription(t *testing.T) {
+	s := newTestSchema("User")
+	s.Messages[0].Description = "User account"
+
+	vt := &VarType{Expr: "[]User"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "User account", vt.List.Elem.Struct.Description())
+
+	// unresolved
+	assert.Equal(t, "", (&VarStructType{Name: "User"}).Description())
+	assert.Equal(t, "", (*VarStructType)(nil).Description())
+}
+
+func TestVarTypeIsFullyResolved(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<string,[]User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.True(t, vt.IsFullyResolved())
+
+	vt.Map.Value.List.Elem.Struct.Message = nil
+	assert.False(t, vt.IsFullyResolved())
+
+	assert.False(t, (&VarType{Expr: "User"}).IsFullyResolved())
+	assert.True(t, (&VarType{Expr: "string", Type: T_String}).IsFullyResolved())
+}
+
+func TestVarTypeMapChain(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<string,User>"}
+	assert.NoError(t, vt.Parse(s))
+	keys, value := vt.MapChain()
+	assert.Equal(t, []DataType{T_String}, keys)
+	assert.Equal(t, "User", value.Struct.Name)
+
+	vt = &VarType{Expr: "map<string,map<int,[]User>>"}
+	assert.NoError(t, vt.Parse(s))
+	keys, value = vt.MapChain()
+	assert.Equal(t, []DataType{T_String, T_Int}, keys)
+	assert.Equal(t, T_List, value.Type)
+	assert.Equal(t, "[]User", value.Expr)
+
+	vt = &VarType{Expr: "[]User"}
+	assert.NoError(t, vt.Parse(s))
+	keys, value = vt.MapChain()
+	assert.Empty(t, keys)
+	assert.Same(t, vt, value)
+}
+
+func TestVarTypeIsSupersetOf(t *testing.T) {
+	parse := func(userFields, profileFields string) *VarType {
+		input := `{
+			"webrpc": "v1",
+			"messages": [
+				{ "name": "Profile", "type": "struct", "fields": [` + profileFields + `] },
+				{ "name": "User", "type": "struct", "fields": [` + userFields + `] }
+			]
+		}`
+		s, err := ParseSchemaJSON([]byte(input))
+		assert.NoError(t, err)
+		vt := &VarType{Expr: "[]User"}
+		assert.NoError(t, vt.Parse(s))
+		return vt
+	}
+
+	base := parse(
+		`{ "name": "id", "type": "uint64" }, { "name": "profile", "type": "Profile" }`,
+		`{ "name": "bio", "type": "string" }`,
+	)
+
+	// added optional field
+	added := parse(
+		`{ "name": "id", "type": "uint64" }, { "name": "profile", "type": "Profile" }, { "name": "email", "type": "string", "optional": true }`,
+		`{ "name": "bio", "type": "string" }`,
+	)
+	assert.True(t, added.IsSupersetOf(base))
+	assert.False(t, base.IsSupersetOf(added))
+	assert.True(t, base.IsSupersetOf(base))
+
+	// added optional field on a nested struct
+	nested := parse(
+		`{ "name": "id", "type": "uint64" }, { "name": "profile", "type": "Profile" }`,
+		`{ "name": "bio", "type": "string" }, { "name": "avatar", "type": "string?" }`,
+	)
+	assert.True(t, nested.IsSupersetOf(base))
+
+	// removed field
+	removed := parse(
+		`{ "name": "profile", "type": "Profile" }`,
+		`{ "name": "bio", "type": "string" }`,
+	)
+	assert.False(t, removed.IsSupersetOf(base))
+
+	// added required field
+	required := parse(
+		`{ "name": "id", "type": "uint64" }, { "name": "profile", "type": "Profile" }, { "name": "email", "type": "string" }`,
+		`{ "name": "bio", "type": "string" }`,
+	)
+	assert.False(t, required.IsSupersetOf(base))
+
+	// changed field type
+	changed := parse(
+		`{ "name": "id", "type": "string" }, { "name": "profile", "type": "Profile" }`,
+		`{ "name": "bio", "type": "string" }`,
+	)
+	assert.False(t, changed.IsSupersetOf(base))
+}
+
+func TestVarTypeValidate(t *testing.T) {
+	s := newTestSchema("User")
+	for _, expr := range []string{"string", "[]User", "map<string,[]uint32?>", "result<User,string>", "optional<User>"} {
+		vt := &VarType{Expr: expr}
+		assert.NoError(t, vt.Parse(s))
+		assert.NoError(t, vt.Validate(), expr)
+	}
+
+	user := &VarType{Expr: "User", Type: T_Struct, Struct: &VarStructType{Name: "User"}}
+	assert.NoError(t, user.Validate())
+
+	tt := []struct {
+		Name  string
+		Type  *VarType
+		Error string
+	}{
+		{"nil", nil, "invalid type: type is nil"},
+		{"unknown", &VarType{Expr: "x"}, "invalid type 'x': unknown data type"},
+		{"list without list", &VarType{Expr: "[]User", Type: T_List}, "invalid type '[]User': list is missing its element type"},
+		{"list without elem", &VarType{Expr: "[]User", Type: T_List, List: &VarListType{}}, "invalid type '[]User': list is missing its element type"},
+		{"list with bad elem", &VarType{Expr: "[]x", Type: T_List, List: &VarListType{Elem: &VarType{Expr: "x"}}}, "invalid type 'x': unknown data type"},
+		{"map without value", &VarType{Expr: "map<string,User>", Type: T_Map, Map: &VarMapType{Key: T_String}}, "invalid type 'map<string,User>': map is missing its value type"},
+		{"map with bad key", &VarType{Expr: "map<User,User>", Type: T_Map, Map: &VarMapType{Key: T_Struct, Value: user}}, "invalid type 'map<User,User>': " + ValidateMapKey(T_Struct).Error()},
+		{"result without err", &VarType{Expr: "result<User,string>", Type: T_Result, Result: &VarResultType{Ok: user}}, "invalid type 'result<User,string>': result is missing its ok or error type"},
+		{"struct without name", &VarType{Expr: "User", Type: T_Struct, Struct: &VarStructType{}}, "invalid type 'User': struct is missing its name"},
+		{"struct without struct", &VarType{Expr: "User", Type: T_Struct}, "invalid type 'User': struct is missing its name"},
+		{"primitive with sub-type", &VarType{Expr: "string", Type: T_String, Struct: &VarStructType{Name: "User"}}, "invalid type 'string': string type has a struct sub-type"},
+		{"conflicting sub-types", &VarType{Expr: "[]User", Type: T_List, List: &VarListType{Elem: user}, Struct: &VarStructType{Name: "User"}}, "invalid type '[]User': has conflicting list and struct sub-types"},
+	}
+	for _, tc := range tt {
+		err := tc.Type.Validate()
+		if assert.Error(t, err, tc.Name) {
+			assert.Equal(t, tc.Error, err.Error(), tc.Name)
+		}
+	}
+}
+
+func TestVarTypeDateTime(t *testing.T) {
+	s := newTestSchema()
+
+	tt := []struct {
+		Expr   string
+		Type   DataType
+		GoType string
+	}{
+		{"date", T_Date, "string"},
+		{"time", T_Time, "string"},
+		{"datetime", T_DateTime, "time.Time"},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s))
+		assert.Equal(t, tc.Type, vt.Type)
+		assert.Equal(t, tc.Expr, vt.String())
+		assert.Equal(t, tc.GoType, vt.GoType())
+		assert.Equal(t, `"<`+tc.Expr+`>"`, vt.WireShape())
+	}
+
+	vt := &VarType{Expr: "map<string,datetime>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_DateTime, vt.Map.Value.Type)
+	assert.Equal(t, "map<string,datetime>", vt.String())
+	assert.Equal(t, []string{"time"}, vt.RequiredImports("go"))
+
+	vt = &VarType{Expr: "map<date,[]time>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Date, vt.Map.Key)
+	assert.Equal(t, "map<date,[]time>", vt.String())
+	assert.Empty(t, vt.RequiredImports("go"))
+
+	assert.Error(t, (&VarType{Expr: "map<datetime,string>"}).Parse(s))
+}
+
+func TestVarTypeMapKeyWireStrategy(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<uint64,User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, MapKeysAsStrings, vt.MapKeyWireStrategy())
+	assert.Equal(t, `{"<uint64>": {User}}`, vt.WireShape())
+
+	s.ParseOptions.MapKeyWireStrategy = MapKeysAsPairs
+	vt = &VarType{Expr: "map<uint64,User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, MapKeysAsPairs, vt.MapKeyWireStrategy())
+	assert.Equal(t, "[ [<uint64>, {User}] ]", vt.WireShape())
+
+	// keys that are strings on the wire are always object keys
+	vt = &VarType{Expr: "map<string,map<uuid,User>>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, MapKeysAsStrings, vt.MapKeyWireStrategy())
+	assert.Equal(t, MapKeysAsStrings, vt.Map.Value.MapKeyWireStrategy())
+
+	vt = &VarType{Expr: "[]User"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, MapKeyWireStrategy(""), vt.MapKeyWireStrategy())
+
+	s.ParseOptions.MapKeyWireStrategy = "tuples"
+	assert.EqualError(t, s.Validate(), "schema error: invalid map key wire strategy 'tuples', must be one of string, pairs")
+}
+
+func TestVarTypeNeedsCustomJSON(t *testing.T) {
+	s := newTestSchema("User")
+
+	tt := []struct {
+		Expr  string
+		Needs bool
+	}{
+		{"string", false},
+		{"[]User", false},
+		{"map<string,User>", false},
+		{"map<uuid,[]string>", false},
+		{"map<uint64,User>", true},
+		{"map<string,map<int32,string>>", true},
+		{"map<bigint,string>", true},
+		{"bigint", true},
+		{"[]bigint?", true},
+		{"timestamp", true},
+		{"map<string,datetime>", true},
+		{"result<timestamp,string>", true},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s))
+		assert.Equal(t, tc.Needs, vt.NeedsCustomJSON(), tc.Expr)
+	}
+}
+
+func TestMessageNeedsCustomJSON(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{ "name": "Profile", "type": "struct", "fields": [{ "name": "createdAt", "type": "timestamp" }] },
+			{ "name": "User", "type": "struct", "fields": [{ "name": "kind", "type": "Kind" }, { "name": "profile", "type": "Profile" }] },
+			{ "name": "Account", "type": "struct", "fields": [{ "name": "balances", "type": "map<uint32,bigint>" }] }
+		]
+	}`
+
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.False(t, s.GetMessageByName("Kind").NeedsCustomJSON())
+	assert.True(t, s.GetMessageByName("Profile").NeedsCustomJSON())
+	assert.False(t, s.GetMessageByName("User").NeedsCustomJSON())
+	assert.True(t, s.GetMessageByName("Account").NeedsCustomJSON())
+}
+
+func TestCompareVarType(t *testing.T) {
+	s := newTestSchema("Account", "User")
+
+	// in expected order
+	exprs := []string{
+		"bool",
+		"uint32",
+		"uint32?",
+		"string",
+		"[]uint32",
+		"[]string",
+		"[]Account",
+		"[]User",
+		"map<uint64,uint32>",
+		"map<string,uint32>",
+		"map<string,User>",
+		"result<User,string>",
+		"result<User,Account>",
+		"Account",
+		"User",
+		"User?",
+	}
+	types := []*VarType{}
+	for _, expr := range exprs {
+		vt := &VarType{Expr: expr}
+		assert.NoError(t, vt.Parse(s))
+		types = append(types, vt)
+	}
+
+	for i, a := range types {
+		for j, b := range types {
+			want := compareInts(i, j)
+			assert.Equal(t, want, CompareVarType(a, b), "%s vs %s", a, b)
+		}
+	}
+
+	// sorting a shuffled list restores the order
+	shuffled := []*VarType{}
+	for i := range types {
+		shuffled = append(shuffled, types[(i*7)%len(types)])
+	}
+	sort.Slice(shuffled, func(i, j int) bool { return CompareVarType(shuffled[i], shuffled[j]) < 0 })
+	assert.Equal(t, types, shuffled)
+
+	// equal types compare as 0
+	a, b := &VarType{Expr: "optional<map<string,[]User>>"}, &VarType{Expr: "map<string,[]User>?"}
+	assert.NoError(t, a.Parse(s))
+	assert.NoError(t, b.Parse(s))
+	assert.True(t, a.Equal(b))
+	assert.Equal(t, 0, CompareVarType(a, b))
+
+	assert.Equal(t, 0, CompareVarType(nil, nil))
+	assert.Equal(t, -1, CompareVarType(nil, a))
+	assert.Equal(t, 1, CompareVarType(a, nil))
+}
+
+func TestVarTypeTypeAtPath(t *testing.T) {
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Address", "type": "struct", "fields": [{ "name": "zip", "type": "string" }] },
+			{ "name": "Profile", "type": "struct", "fields": [{ "name": "name", "type": "string?" }] },
+			{
+				"name": "User",
+				"type": "struct",
+				"fields": [
+					{ "name": "addresses", "type": "[]Address" },
+					{ "name": "profile", "type": "Profile?" },
+					{ "name": "labels", "type": "map<string,[]Address>" }
+				]
+			}
+		]
+	}`
+	s, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+
+	user := &VarType{Expr: "User"}
+	assert.NoError(t, user.Parse(s))
+
+	tt := []struct {
+		Path string
+		Expr string
+	}{
+		{"", "User"},
+		{"addresses", "[]Address"},
+		{"addresses[]", "Address"},
+		{"addresses[].zip", "string"},
+		{"profile.name", "string?"},
+		{"labels{}[].zip", "string"},
+	}
+	for _, tc := range tt {
+		vt, err := user.TypeAtPath(tc.Path)
+		if assert.NoError(t, err, tc.Path) {
+			assert.Equal(t, tc.Expr, vt.String(), tc.Path)
+		}
+	}
+
+	list := &VarType{Expr: "[]User"}
+	assert.NoError(t, list.Parse(s))
+	vt, err := list.TypeAtPath("[].addresses[].zip")
+	assert.NoError(t, err)
+	assert.Equal(t, T_String, vt.Type)
+
+	errs := []struct {
+		Path  string
+		Error string
+	}{
+		{"email", "invalid path 'email': struct 'User' has no field 'email'"},
+		{"addresses[].street", "invalid path 'addresses[].street': struct 'Address' has no field 'street'"},
+		{"addresses.zip", "invalid path 'addresses.zip': '[]Address' has no field 'zip'"},
+		{"profile[]", "invalid path 'profile[]': 'Profile?' is not a list"},
+		{"addresses{}", "invalid path 'addresses{}': '[]Address' is not a map"},
+		{"profile..name", "invalid path 'profile..name': empty field name after 'profile'"},
+		{"addresses[]zip", "invalid path 'addresses[]zip': expecting '.', '[]' or '{}' after 'addresses[]'"},
+	}
+	for _, tc := range errs {
+		_, err := user.TypeAtPath(tc.Path)
+		if assert.Error(t, err, tc.Path) {
+			assert.Equal(t, tc.Error, err.Error())
+		}
+	}
+}
+
+func TestVarTypeMoney(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "money<USD>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Money, vt.Type)
+	assert.Equal(t, "USD", vt.Money.Currency)
+	assert.Equal(t, "money<USD>", vt.String())
+	assert.Equal(t, "Money", vt.GoType())
+	assert.Equal(t, `{"amount": "<decimal>", "currency": "USD"}`, vt.WireShape())
+	assert.NoError(t, vt.Validate())
+
+	vt = &VarType{Expr: "map<string, []money<EUR>?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map<string,[]money<EUR>?>", vt.String())
+	assert.Equal(t, "EUR", vt.Map.Value.List.Elem.Money.Currency)
+
+	usd, eur := &VarType{Expr: "money<USD>"}, &VarType{Expr: "money<EUR>"}
+	assert.NoError(t, usd.Parse(s))
+	assert.NoError(t, eur.Parse(s))
+	assert.False(t, usd.Equal(eur))
+	assert.Equal(t, 1, CompareVarType(usd, eur))
+
+	tt := []struct {
+		Expr  string
+		Error string
+	}{
+		{"money<dollars>", "schema error: invalid currency 'dollars' for 'money<dollars>', expecting a 3-letter ISO 4217 code, ie. money<USD>"},
+		{"money<usd>", "schema error: invalid currency 'usd' for 'money<usd>', expecting a 3-letter ISO 4217 code, ie. money<USD>"},
+		{"money<USD,EUR>", "schema error: invalid money syntax for 'money<USD,EUR>', expecting money<CURRENCY>"},
+		{"money<>", "schema error: invalid money syntax for 'money<>', expecting money<CURRENCY>"},
+		{"money", "schema error: invalid money expr for 'money'"},
+		{"map<money<USD>,string>", "schema error: invalid map key 'money<USD>' for 'map<money<USD>,string>'"},
+	}
+	for _, tc := range tt {
+		err := (&VarType{Expr: tc.Expr}).Parse(s)
+		if assert.Error(t, err, tc.Expr) {
+			assert.Equal(t, tc.Error, err.Error())
+		}
+	}
+
+	assert.Error(t, (&VarType{Expr: "money<USD>", Type: T_Money, Money: &VarMoneyType{Currency: "us"}}).Validate())
+}
+
+func TestVarTypeRational(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "[]rational"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Rational, vt.List.Elem.Type)
+	assert.Equal(t, "[]rational", vt.String())
+	assert.Equal(t, "[]*big.Rat", vt.GoType())
+	assert.Equal(t, []string{"math/big"}, vt.RequiredImports("go"))
+	assert.Equal(t, `[ "<rational>" ]`, vt.WireShape())
+
+	vt = &VarType{Expr: "map<string,rational>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Rational, vt.Map.Value.Type)
+	assert.Equal(t, "map<string,rational>", vt.String())
+	assert.Equal(t, "map[string]*big.Rat", vt.GoType())
+
+	err := (&VarType{Expr: "map<rational,string>"}).Parse(s)
+	assert.EqualError(t, err, "schema error: invalid map key 'rational' for 'map<rational,string>'")
+}
+
+func TestVarTypeDecimal(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "[]decimal<10, 2>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Decimal, vt.List.Elem.Type)
+	assert.Equal(t, &VarDecimalType{Precision: 10, Scale: 2}, vt.List.Elem.Decimal)
+	assert.Equal(t, "[]decimal<10,2>", vt.String())
+	assert.Equal(t, "[]string", vt.GoType())
+	assert.Equal(t, `[ "<decimal>" ]`, vt.WireShape())
+
+	vt = &VarType{Expr: "decimal"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Nil(t, vt.Decimal)
+	assert.Equal(t, "decimal", vt.String())
+
+	a, b := &VarType{Expr: "decimal<10,2>"}, &VarType{Expr: "decimal<12,2>"}
+	assert.NoError(t, a.Parse(s))
+	assert.NoError(t, b.Parse(s))
+	assert.False(t, a.Equal(b))
+	assert.True(t, vt.isSupersetOf(a, map[[2]*Message]bool{}))
+
+	tt := []struct {
+		Expr string
+		Err  string
+	}{
+		{"decimal<10>", "schema error: invalid decimal syntax for 'decimal<10>', expecting decimal<PRECISION,SCALE>"},
+		{"decimal<a,b>", "schema error: invalid decimal syntax for 'decimal<a,b>', expecting decimal<PRECISION,SCALE>"},
+		{"decimal<2,4>", "schema error: invalid decimal 'decimal<2,4>': decimal scale must be between 0 and the precision"},
+		{"decimal<0,0>", "schema error: invalid decimal 'decimal<0,0>': decimal precision must be between 1 and 1000"},
+		{"map<decimal,string>", "schema error: invalid map key 'decimal' for 'map<decimal,string>'"},
+	}
+	for _, tc := range tt {
+		err := (&VarType{Expr: tc.Expr}).Parse(s)
+		assert.EqualError(t, err, tc.Err, tc.Expr)
+	}
+}
+
+func TestVarTypeRatio(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "[]ratio<0, 1>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Ratio, vt.List.Elem.Type)
+	assert.Equal(t, &VarRangeType{Min: 0, Max: 1}, vt.List.Elem.Range)
+	assert.Equal(t, "[]ratio<0,1>", vt.String())
+	assert.Equal(t, "[]float64", vt.GoType())
+	assert.Equal(t, "[ <ratio> ]", vt.WireShape())
+
+	elem := vt.List.Elem
+	assert.NoError(t, elem.ValidateValue(0.25))
+	assert.NoError(t, elem.ValidateValue(1.0))
+	assert.EqualError(t, elem.ValidateValue(1.5), "value 1.5 is out of range 0..1")
+	assert.Error(t, elem.ValidateValue("0.5"))
+
+	percent := &VarType{Expr: "ratio<0,100>"}
+	assert.NoError(t, percent.Parse(s))
+	assert.True(t, percent.isSupersetOf(elem, map[[2]*Message]bool{}))
+	assert.False(t, elem.isSupersetOf(percent, map[[2]*Message]bool{}))
+	assert.False(t, elem.Equal(percent))
+
+	plain := &VarType{Expr: "ratio"}
+	assert.NoError(t, plain.Parse(s))
+	assert.Nil(t, plain.Range)
+	assert.True(t, plain.Equal(elem))
+	assert.Error(t, plain.ValidateValue(-0.5))
+
+	tt := []struct {
+		Expr string
+		Err  string
+	}{
+		{"ratio<1,0>", "schema error: invalid ratio 'ratio<1,0>': ratio minimum 1 must be less than the maximum 0"},
+		{"ratio<0.5,0.5>", "schema error: invalid ratio 'ratio<0.5,0.5>': ratio minimum 0.5 must be less than the maximum 0.5"},
+		{"ratio<0,Inf>", "schema error: invalid ratio 'ratio<0,Inf>': ratio bounds must be finite numbers"},
+		{"ratio<0>", "schema error: invalid ratio syntax for 'ratio<0>', expecting ratio<MIN,MAX>"},
+		{"ratio<a,b>", "schema error: invalid ratio syntax for 'ratio<a,b>', expecting ratio<MIN,MAX>"},
+	}
+	for _, tc := range tt {
+		err := (&VarType{Expr: tc.Expr}).Parse(s)
+		assert.EqualError(t, err, tc.Err, tc.Expr)
+	}
+}
+
+func TestVarTypeGeoPoint(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "[]geopoint"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_GeoPoint, vt.List.Elem.Type)
+	assert.Equal(t, "[]geopoint", vt.String())
+	assert.Equal(t, "[]GeoPoint", vt.GoType())
+	assert.Equal(t, `[ {"lat": <float64>, "lng": <float64>} ]`, vt.WireShape())
+
+	vt = &VarType{Expr: "map<string,geopoint?>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_GeoPoint, vt.Map.Value.Type)
+	assert.Equal(t, "map<string,geopoint?>", vt.String())
+	assert.Equal(t, "map[string]*GeoPoint", vt.GoType())
+
+	err := (&VarType{Expr: "map<geopoint,string>"}).Parse(s)
+	assert.EqualError(t, err, "schema error: invalid map key 'geopoint' for 'map<geopoint,string>'")
+}
+
+func TestVarTypeIPAddr(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<ipaddr,User>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_IPAddr, vt.Map.Key)
+	assert.Equal(t, "map<ipaddr,User>", vt.String())
+	assert.Equal(t, "map[string]*User", vt.GoType())
+	assert.Equal(t, `{"<ipaddr>": {User}}`, vt.WireShape())
+	assert.Equal(t, MapKeysAsStrings, vt.MapKeyWireStrategy())
+	assert.NoError(t, vt.ValidateValue(map[string]interface{}{"10.0.0.1": map[string]interface{}{}}))
+	assert.EqualError(t, vt.ValidateValue(map[string]interface{}{"10.0.0": map[string]interface{}{}}), "map key '10.0.0' is not a valid ipaddr")
+
+	vt = &VarType{Expr: "[]cidr"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_CIDR, vt.List.Elem.Type)
+	assert.Equal(t, "[]net.IPNet", vt.GoType())
+	assert.Equal(t, []string{"net"}, vt.RequiredImports("go"))
+	assert.Equal(t, `[ "<cidr>" ]`, vt.WireShape())
+	assert.True(t, vt.NeedsCustomJSON())
+	assert.NoError(t, vt.ValidateValue([]interface{}{"10.0.0.0/8", "2001:db8::/32"}))
+	assert.Error(t, vt.ValidateValue([]interface{}{"10.0.0.1"}))
+
+	vt = &VarType{Expr: "ipaddr?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "net.IP", vt.GoType())
+
+	vt = &VarType{Expr: "map<cidr,string>"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "map[string]string", vt.GoType())
+}
+
+func TestVarTypeBlob(t *testing.T) {
+	s := newTestSchema()
+
+	vt := &VarType{Expr: "[]blob"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, T_Blob, vt.List.Elem.Type)
+	assert.Equal(t, "[]blob", vt.String())
+	assert.Equal(t, "[]io.ReadCloser", vt.GoType())
+	assert.Equal(t, []string{"io"}, vt.RequiredImports("go"))
+	assert.Equal(t, `[ "<blob reference>" ]`, vt.WireShape())
+	assert.False(t, vt.Equal(&VarType{Expr: "[]byte", Type: T_List, List: &VarListType{Elem: &VarType{Expr: "byte", Type: T_Byte}}}))
+
+	vt = &VarType{Expr: "blob?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "io.ReadCloser", vt.GoType())
+
+	err := (&VarType{Expr: "map<blob,int>"}).Parse(s)
+	assert.EqualError(t, err, "schema error: invalid map key 'blob' for 'map<blob,int>'")
+}
+
+func TestVarTypeHasEmptyValue(t *testing.T) {
+	s, err := ParseSchemaJSON([]byte(`{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "Kind", "type": "enum", "fields": [{ "name": "USER", "type": "uint32", "value": "0" }] },
+			{ "name": "User", "type": "struct", "fields": [] }
+		]
+	}`))
+	assert.NoError(t, err)
+
+	tt := []struct {
+		Expr  string
+		Empty bool
+	}{
+		{"string", true},
+		{"uint64", true},
+		{"float32", true},
+		{"bool", true},
+		{"uuid", true},
+		{"bigint", true},
+		{"any", true},
+		{"[]User", true},
+		{"map<string,User>", true},
+		{"Kind", true},
+		{"User", false},
+		{"User?", true},
+		{"timestamp", false},
+		{"timestamp?", true},
+		{"money<USD>", false},
+		{"geopoint", false},
+	}
+	for _, tc := range tt {
+		vt := &VarType{Expr: tc.Expr}
+		assert.NoError(t, vt.Parse(s))
+		assert.Equal(t, tc.Empty, vt.HasEmptyValue(), tc.Expr)
+	}
+}
+
+func TestBuildExpr(t *testing.T) {
+	s := newTestSchema("User", "Cat", "Dog")
+
+	user := &VarType{Type: T_Struct, Struct: &VarStructType{Name: "User"}}
+	tt := []struct {
+		Type *VarType
+		Expr string
+	}{
+		{&VarType{Type: T_Uint64}, "uint64"},
+		{&VarType{Type: T_String, Optional: true}, "string?"},
+		{&VarType{Type: T_List, List: &VarListType{Elem: user}}, "[]User"},
+		{&VarType{Type: T_Map, Map: &VarMapType{Key: T_String, Value: &VarType{Type: T_List, List: &VarListType{Elem: user}}}}, "map<string,[]User>"},
+		{&VarType{Type: T_List, Optional: true, List: &VarListType{Elem: &VarType{Type: T_Timestamp}}}, "optional<[]timestamp>"},
+		{&VarType{Type: T_Result, Result: &VarResultType{Ok: user, Err: &VarType{Type: T_String}}}, "result<User,string>"},
+		{&VarType{Type: T_Union, Union: &VarUnionType{Discriminator: "type", Variants: []*VarType{
+			{Type: T_Struct, Struct: &VarStructType{Name: "Cat"}},
+			{Type: T_Struct, Struct: &VarStructType{Name: "Dog"}},
+		}}}, "union<type:Cat|Dog>"},
+		{&VarType{Type: T_Money, Money: &VarMoneyType{Currency: "EUR"}}, "money<EUR>"},
+		{&VarType{Type: T_Map, Map: &VarMapType{Key: T_Uint32, Value: &VarType{Type: T_Rational}}}, "map<uint32,rational>"},
+	}
+
+	for _, tc := range tt {
+		expr := BuildExpr(tc.Type)
+		assert.Equal(t, tc.Expr, expr)
+
+		// the built expr parses back to the same tree
+		parsed := &VarType{Expr: expr}
+		if assert.NoError(t, parsed.Parse(s), expr) {
+			assert.Equal(t, parsed.Expr, expr)
+			tc.Type.Expr = expr
+			assert.True(t, parsed.Equal(tc.Type), expr)
+		}
+	}
+}
+
+func TestVarTypeUniqueList(t *testing.T) {
+	s := newTestSchema("User")
+
+	vt := &VarType{Expr: "map<string, uniquelist<User>>"}
+	assert.NoError(t, vt.Parse(s))
+	list := vt.Map.Value
+	assert.Equal(t, T_List, list.Type)
+	assert.True(t, list.List.Unique)
+	assert.Equal(t, "map<string,uniquelist<User>>", vt.Expr)
+	assert.Equal(t, "map[string][]*User", vt.GoType())
+	assert.Equal(t, "a map from string to a list of unique User objects", vt.Describe())
+
+	plain := &VarType{Expr: "[]User"}
+	assert.NoError(t, plain.Parse(s))
+	assert.False(t, plain.Equal(list))
+	assert.True(t, plain.IsSupersetOf(list))
+	assert.False(t, list.IsSupersetOf(plain))
+
+	vt = &VarType{Expr: "uniquelist<string?>?"}
+	assert.NoError(t, vt.Parse(s))
+	assert.Equal(t, "uniquelist<string?>?", vt.Expr)
+	assert.True(t, vt.Optional)
+	assert.True(t, vt.List.Elem.Optional)
+
+	// the constraint round-trips through the schema JSON
+	input := `{
+		"webrpc": "v1",
+		"messages": [
+			{ "name": "User", "type": "struct", "fields": [{ "name": "tags", "type": "uniquelist<string>" }] }
+		]
+	}`
+	schema, err := ParseSchemaJSON([]byte(input))
+	assert.NoError(t, err)
+	out, err := schema.ToJSON()
+	assert.NoError(t, err)
+	schema, err = ParseSchemaJSON([]byte(out))
+	assert.NoError(t, err)
+	assert.True(t, schema.Messages[0].Fields[0].Type.List.Unique)
+
+	components, err := schema.ToOpenAPIComponents()
+	assert.NoError(t, err)
+	tags := components["User"].(map[string]interface{})["properties"].(map[string]interface{})["tags"]
+	assert.Equal(t, true, tags.(map[string]interface{})["uniqueItems"])
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is a parsed webrpc schema version, ie. v1.2.0
+type schemaVersion struct {
+	major, minor, patch int
+
+	// latest marks a version given as its major alone, ie. v1, which
+	// allows every feature of that major version
+	latest bool
+}
+
+// parseSchemaVersion parses a "v"-prefixed version of one to three
+// numbers, ie. v1, v1.2 or v1.2.0
+func parseSchemaVersion(v string) (schemaVersion, bool) {
+	if !strings.HasPrefix(v, "v") {
+		return schemaVersion{}, false
+	}
+	parts := strings.Split(v[1:], ".")
+	if len(parts) > 3 {
+		return schemaVersion{}, false
+	}
+	nums := [3]int{}
+	for i, part := range parts {
+		if !isDecimalDigits(part) || part[0] == '-' {
+			return schemaVersion{}, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return schemaVersion{}, false
+		}
+		nums[i] = n
+	}
+	return schemaVersion{major: nums[0], minor: nums[1], patch: nums[2], latest: len(parts) == 1}, true
+}
+
+// atLeast reports whether the version is min or newer
+func (v schemaVersion) atLeast(min schemaVersion) bool {
+	if v.major != min.major {
+		return v.major > min.major
+	}
+	if v.latest {
+		return true
+	}
+	if v.minor != min.minor {
+		return v.minor > min.minor
+	}
+	return v.patch >= min.patch
+}
+
+// typeFeatureVersions are the minimum schema versions of newer type syntax.
+// Schemas declaring an older version, ie. "webrpc": "v1.0.0", can't use
+// them, so generators for that version never see types they don't know.
+var typeFeatureVersions = []struct {
+	feature string
+	version string
+	uses    func(t *VarType) bool
+}{
+	{"optional type", "v1.1.0", func(t *VarType) bool { return t.Optional }},
+	{"union type", "v1.2.0", func(t *VarType) bool { return t.Type == T_Union }},
+}
+
+// RequireVersion returns an error if the webrpc version declared by the
+// schema is older than min, ie. "v1.2.0". A version declared as its major
+// alone, ie. "v1", satisfies any min of the same major version.
+func (s *WebRPCSchema) RequireVersion(min string) error {
+	required, ok := parseSchemaVersion(min)
+	if !ok {
+		return fmt.Errorf("schema error: invalid version '%s', expecting ie. v1.2.0", min)
+	}
+	declared, ok := parseSchemaVersion(s.WebrpcVersion)
+	if !ok || !declared.atLeast(required) {
+		return fmt.Errorf("schema error: schema declares webrpc %s, but %s is required", s.WebrpcVersion, min)
+	}
+	return nil
+}
+
+// checkTypeFeatures ensures the types of the schema only use syntax allowed
+// by its declared webrpc version, see typeFeatureVersions
+func (s *WebRPCSchema) checkTypeFeatures() error {
+	var err error
+	s.lintTypes(func(location string, t *VarType) {
+		t.Walk(func(vt *VarType) bool {
+			for _, f := range typeFeatureVersions {
+				if err == nil && f.uses(vt) && s.RequireVersion(f.version) != nil {
+					err = fmt.Errorf("schema error: %s '%s' in %s requires webrpc %s, but the schema declares %s", f.feature, buildVarTypeExpr(vt, "", s.parseOptions()), location, f.version, s.WebrpcVersion)
+				}
+			}
+			return err == nil
+		})
+	})
+	return err
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireVersion(t *testing.T) {
+	tt := []struct {
+		Declared string
+		Min      string
+		Ok       bool
+	}{
+		{"v1", "v1.2.0", true},
+		{"v1.2.0", "v1.2.0", true},
+		{"v1.3", "v1.2.5", true},
+		{"v1.2.4", "v1.2.5", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"v1", "v2", false},
+		{"1.2.0", "v1.0.0", false},
+	}
+
+	for _, tc := range tt {
+		s := &WebRPCSchema{WebrpcVersion: tc.Declared}
+		err := s.RequireVersion(tc.Min)
+		if tc.Ok {
+			assert.NoError(t, err, tc.Declared)
+		} else {
+			assert.EqualError(t, err, "schema error: schema declares webrpc "+tc.Declared+", but "+tc.Min+" is required", tc.Declared)
+		}
+	}
+
+	err := (&WebRPCSchema{WebrpcVersion: "v1"}).RequireVersion("latest")
+	assert.EqualError(t, err, "schema error: invalid version 'latest', expecting ie. v1.2.0")
+}
+
+func TestTypeFeatureVersions(t *testing.T) {
+	parse := func(version, fieldType string) error {
+		input := `{
+			"webrpc": "` + version + `",
+			"messages": [{ "name": "Pet", "type": "struct", "fields": [
+				{ "name": "id", "type": "uint64" },
+				{ "name": "shape", "type": "` + fieldType + `" }
+			] }]
+		}`
+		_, err := ParseSchemaJSON([]byte(input))
+		return err
+	}
+
+	assert.NoError(t, parse("v1", "map<string,union<string|uint64>>"))
+	assert.NoError(t, parse("v1.2.0", "map<string,union<string|uint64>>"))
+	assert.NoError(t, parse("v1.0.0", "map<string,[]uint64>"))
+
+	err := parse("v1.1.0", "map<string,union<string|uint64>>")
+	assert.EqualError(t, err, "schema error: union type 'union<string|uint64>' in message 'Pet' field 'shape' requires webrpc v1.2.0, but the schema declares v1.1.0")
+
+	err = parse("v1.0.0", "[]string?")
+	assert.EqualError(t, err, "schema error: optional type 'string?' in message 'Pet' field 'shape' requires webrpc v1.1.0, but the schema declares v1.0.0")
+
+	err = parse("v2.0.0", "string")
+	assert.EqualError(t, err, "webrpc schema version, 'v2.0.0' is invalid, try 'v1'")
+}